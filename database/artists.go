@@ -0,0 +1,130 @@
+package database
+
+import (
+	"fmt"
+	"music-go/musictag"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// artistSplitter splits an artist tag holding several artists, e.g.
+// "Artist A / Artist B", it is only a fallback for the tags which have a
+// single value. The exceptions are artist names which contain a separator
+// (e.g. "AC/DC") and are never split, they only match whole artists: between
+// separators or at the ends of the tag.
+type artistSplitter struct {
+	separators *regexp.Regexp
+	exceptions []*regexp.Regexp // anchored at the start, the longest names first
+}
+
+// exceptions are replaced by a placeholder before splitting
+var artistPlaceholder = regexp.MustCompile("\x00([0-9]+)\x00")
+
+func newArtistSplitter(separators string, exceptions []string) (*artistSplitter, error) {
+	s := &artistSplitter{}
+
+	var err error
+	s.separators, err = regexp.Compile(separators)
+	if err != nil {
+		return nil, fmt.Errorf("invalid artist separators %q: %v", separators, err)
+	}
+
+	var names []string
+	for _, e := range exceptions {
+		if e = strings.TrimSpace(e); e != "" {
+			names = append(names, e)
+		}
+	}
+
+	// the longest names first so an exception containing another one wins
+	sort.SliceStable(names, func(i, j int) bool { return len(names[i]) > len(names[j]) })
+	for _, name := range names {
+		s.exceptions = append(s.exceptions, regexp.MustCompile(`^(?i)`+regexp.QuoteMeta(name)))
+	}
+	return s, nil
+}
+
+// Split returns the artists of the artist tag, the empty names are dropped.
+func (s *artistSplitter) Split(artist string) []string {
+	var kept []string
+	if len(s.exceptions) > 0 {
+		artist = s.replaceExceptions(artist, func(name string) string {
+			kept = append(kept, name)
+			return "\x00" + strconv.Itoa(len(kept)-1) + "\x00"
+		})
+	}
+
+	var artists []string
+	for _, name := range s.separators.Split(artist, -1) {
+		name = artistPlaceholder.ReplaceAllStringFunc(name, func(p string) string {
+			i, _ := strconv.Atoi(strings.Trim(p, "\x00"))
+			return kept[i]
+		})
+
+		if name = strings.TrimSpace(name); name != "" {
+			artists = append(artists, name)
+		}
+	}
+	return artists
+}
+
+// replaceExceptions replaces the exceptions starting after a separator (or
+// at the start of artist) and ending before a separator (or at the end),
+// the spaces around the separators are ignored.
+func (s *artistSplitter) replaceExceptions(artist string, repl func(name string) string) string {
+	starts := []int{len(artist) - len(strings.TrimLeftFunc(artist, unicode.IsSpace))}
+	ends := map[int]bool{len(strings.TrimRightFunc(artist, unicode.IsSpace)): true}
+	for _, m := range s.separators.FindAllStringIndex(artist, -1) {
+		starts = append(starts, len(artist)-len(strings.TrimLeftFunc(artist[m[1]:], unicode.IsSpace)))
+		ends[len(strings.TrimRightFunc(artist[:m[0]], unicode.IsSpace))] = true
+	}
+
+	var sb strings.Builder
+	copied := 0
+	for _, start := range starts {
+		if start < copied {
+			continue // inside the previous exception
+		}
+		for _, e := range s.exceptions {
+			m := e.FindStringIndex(artist[start:])
+			if m == nil || !ends[start+m[1]] {
+				continue
+			}
+			sb.WriteString(artist[copied:start])
+			sb.WriteString(repl(artist[start : start+m[1]]))
+			copied = start + m[1]
+			break
+		}
+	}
+	sb.WriteString(artist[copied:])
+	return sb.String()
+}
+
+// artistNames returns the artists of the tag, the values of a multi-valued
+// artist field are trusted and a single value is split with the configured
+// separators.
+func (d *DataBase) artistNames(tag musictag.Metadata) []string {
+	values := tag.GetArtists()
+	if len(values) == 1 {
+		values = d.artistSplitter.Split(values[0])
+	}
+
+	var artists []string
+	seen := make(map[string]bool)
+	for _, name := range values {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		artists = append(artists, name)
+	}
+
+	if len(artists) == 0 {
+		return []string{"Unknown"}
+	}
+	return artists
+}
@@ -0,0 +1,69 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestArtistSplitter(t *testing.T) {
+	s, err := newArtistSplitter(`\s*(?:/|&|,|;)\s*`, []string{"AC/DC", "Simon & Garfunkel", "Earth, Wind & Fire", "Wind & Fire", " "})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		artist string
+		want   []string
+	}{
+		// separators
+		{"Artist", []string{"Artist"}},
+		{"Artist A / Artist B", []string{"Artist A", "Artist B"}},
+		{"Artist A/Artist B&Artist C", []string{"Artist A", "Artist B", "Artist C"}},
+		{"Artist A, Artist B ; Artist C", []string{"Artist A", "Artist B", "Artist C"}},
+		{"Artist A - Artist B", []string{"Artist A - Artist B"}},
+
+		// exceptions
+		{"AC/DC", []string{"AC/DC"}},
+		{"ac/dc", []string{"ac/dc"}},
+		{"AC/DC / Artist", []string{"AC/DC", "Artist"}},
+		{"Artist & AC/DC", []string{"Artist", "AC/DC"}},
+		{"Artist, Simon & Garfunkel, AC/DC", []string{"Artist", "Simon & Garfunkel", "AC/DC"}},
+		{"Earth, Wind & Fire", []string{"Earth, Wind & Fire"}},
+		{"Wind & Fire / Earth", []string{"Wind & Fire", "Earth"}},
+
+		// the exceptions only match whole artists
+		{"MAC/DC", []string{"MAC", "DC"}},
+		{"AC/DCX", []string{"AC", "DCX"}},
+		{"Paul Simon & Garfunkel", []string{"Paul Simon", "Garfunkel"}},
+		{"Simon & Garfunkel Tribute", []string{"Simon", "Garfunkel Tribute"}},
+		{"Earth, Wind & Fire Tribute", []string{"Earth", "Wind", "Fire Tribute"}},
+
+		// whitespace
+		{"", nil},
+		{"   ", nil},
+		{"  Artist A  /  Artist B  ", []string{"Artist A", "Artist B"}},
+		{"Artist A //  & Artist B", []string{"Artist A", "Artist B"}},
+		{"  AC/DC  ", []string{"AC/DC"}},
+		{"Artist A  /  AC/DC  &  Artist B", []string{"Artist A", "AC/DC", "Artist B"}},
+		{"\tSimon & Garfunkel\n", []string{"Simon & Garfunkel"}},
+	}
+	for _, tt := range tests {
+		if got := s.Split(tt.artist); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Split(%q) = %q, want %q", tt.artist, got, tt.want)
+		}
+	}
+}
+
+func TestArtistSplitterWithoutExceptions(t *testing.T) {
+	s, err := newArtistSplitter(`\s*/\s*`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := s.Split("AC/DC"), []string{"AC", "DC"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Split(%q) = %q, want %q", "AC/DC", got, want)
+	}
+
+	if _, err := newArtistSplitter(`(`, nil); err == nil {
+		t.Error("no error for invalid separators")
+	}
+}
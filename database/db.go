@@ -9,19 +9,20 @@ import (
 	"os"
 	"path"
 	"path/filepath"
-	"regexp"
 	"strings"
+	"time"
 
 	_ "github.com/tursodatabase/go-libsql"
 )
 
-var artistSpLitter = regexp.MustCompile(`\s*(?:/|&|,)\s*`)
-
 type DataBase struct {
 	config   utils.Config
 	DB       *sql.DB
 	Location string
 	logger   utils.CLogger
+
+	artistSplitter *artistSplitter
+	textDecoder    musictag.TextDecoder // ISO-8859-1 text of the ID3 tags
 }
 
 // open connection with the given database name.
@@ -34,6 +35,16 @@ func OpenConnection(config utils.Config, logger utils.CLogger) (*DataBase, error
 	}
 
 	var err error
+	d.artistSplitter, err = newArtistSplitter(config.Artists.Separators, config.Artists.Exceptions)
+	if err != nil {
+		return nil, err
+	}
+
+	d.textDecoder, err = musictag.NewTextDecoder(config.TextEncoding.CodePage, config.TextEncoding.Detect)
+	if err != nil {
+		return nil, err
+	}
+
 	d.DB, err = sql.Open("libsql", d.Location)
 	if err != nil {
 		return nil, err
@@ -89,6 +100,30 @@ func (d *DataBase) CreatMusicsTable() error {
             year INT NOT NULL DEFAULT 0,
             genre TEXT DEFAULT 'Unknown',
             music_location TEXT NOT NULL UNIQUE,
+            duration INT NOT NULL DEFAULT 0,
+            track INT NOT NULL DEFAULT 0,
+            track_total INT NOT NULL DEFAULT 0,
+            disc INT NOT NULL DEFAULT 0,
+            disc_total INT NOT NULL DEFAULT 0,
+            composer TEXT NOT NULL DEFAULT '',
+            bpm INT NOT NULL DEFAULT 0,
+            lyrics TEXT NOT NULL DEFAULT '',
+            synced_lyrics TEXT NOT NULL DEFAULT '',
+            mb_track_id TEXT NOT NULL DEFAULT '',
+            mb_album_id TEXT NOT NULL DEFAULT '',
+            mb_artist_id TEXT NOT NULL DEFAULT '',
+            rg_track_gain REAL,
+            rg_track_peak REAL NOT NULL DEFAULT 0,
+            rg_album_gain REAL,
+            rg_album_peak REAL NOT NULL DEFAULT 0,
+            r128_track_gain REAL,
+            r128_track_peak REAL,
+            r128_album_gain REAL,
+            r128_album_peak REAL,
+            rating INT NOT NULL DEFAULT 0,
+            play_count INT NOT NULL DEFAULT 0,
+            text_encoding TEXT NOT NULL DEFAULT '',
+            audio_hash TEXT NOT NULL DEFAULT '',
             UNIQUE(title, artist, album)
         );`,
 		`CREATE TABLE IF NOT EXISTS artists (
@@ -102,6 +137,31 @@ func (d *DataBase) CreatMusicsTable() error {
 			FOREIGN KEY (music_id) REFERENCES musics(id) ON DELETE CASCADE,
 			FOREIGN KEY (artist_id) REFERENCES artists(id) ON DELETE CASCADE
 		);`,
+		`CREATE TABLE IF NOT EXISTS chapters (
+			music_id INTEGER NOT NULL,
+			position INT NOT NULL,
+			title TEXT NOT NULL DEFAULT '',
+			start_ms INT NOT NULL,
+			end_ms INT NOT NULL DEFAULT 0,
+			PRIMARY KEY (music_id, position),
+			FOREIGN KEY (music_id) REFERENCES musics(id) ON DELETE CASCADE
+		);`,
+		`CREATE TABLE IF NOT EXISTS fingerprints (
+			music_id INTEGER PRIMARY KEY,
+			fingerprint BLOB NOT NULL,
+			duration_ms INT NOT NULL DEFAULT 0,
+			lossless INT NOT NULL DEFAULT 0,
+			bitrate INT NOT NULL DEFAULT 0,
+			FOREIGN KEY (music_id) REFERENCES musics(id) ON DELETE CASCADE
+		);`,
+		`CREATE TABLE IF NOT EXISTS near_duplicates (
+			best_id INTEGER NOT NULL,
+			copy_id INTEGER NOT NULL,
+			score REAL NOT NULL,
+			PRIMARY KEY (best_id, copy_id),
+			FOREIGN KEY (best_id) REFERENCES musics(id) ON DELETE CASCADE,
+			FOREIGN KEY (copy_id) REFERENCES musics(id) ON DELETE CASCADE
+		);`,
 	}
 
 	for _, query := range querys {
@@ -111,9 +171,45 @@ func (d *DataBase) CreatMusicsTable() error {
 		}
 	}
 
+	// add the new columns to the databases created by older versions
+	for _, query := range musicsMigrations {
+		_, err = d.DB.Exec(query)
+		if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+			return err
+		}
+	}
+
 	return nil
 }
 
+// columns added to musics table after it's creation
+var musicsMigrations = []string{
+	`ALTER TABLE musics ADD COLUMN duration INT NOT NULL DEFAULT 0`,
+	`ALTER TABLE musics ADD COLUMN track INT NOT NULL DEFAULT 0`,
+	`ALTER TABLE musics ADD COLUMN track_total INT NOT NULL DEFAULT 0`,
+	`ALTER TABLE musics ADD COLUMN disc INT NOT NULL DEFAULT 0`,
+	`ALTER TABLE musics ADD COLUMN disc_total INT NOT NULL DEFAULT 0`,
+	`ALTER TABLE musics ADD COLUMN composer TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE musics ADD COLUMN bpm INT NOT NULL DEFAULT 0`,
+	`ALTER TABLE musics ADD COLUMN lyrics TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE musics ADD COLUMN synced_lyrics TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE musics ADD COLUMN mb_track_id TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE musics ADD COLUMN mb_album_id TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE musics ADD COLUMN mb_artist_id TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE musics ADD COLUMN rg_track_gain REAL`,
+	`ALTER TABLE musics ADD COLUMN rg_track_peak REAL NOT NULL DEFAULT 0`,
+	`ALTER TABLE musics ADD COLUMN rg_album_gain REAL`,
+	`ALTER TABLE musics ADD COLUMN rg_album_peak REAL NOT NULL DEFAULT 0`,
+	`ALTER TABLE musics ADD COLUMN r128_track_gain REAL`,
+	`ALTER TABLE musics ADD COLUMN r128_track_peak REAL`,
+	`ALTER TABLE musics ADD COLUMN r128_album_gain REAL`,
+	`ALTER TABLE musics ADD COLUMN r128_album_peak REAL`,
+	`ALTER TABLE musics ADD COLUMN rating INT NOT NULL DEFAULT 0`,
+	`ALTER TABLE musics ADD COLUMN play_count INT NOT NULL DEFAULT 0`,
+	`ALTER TABLE musics ADD COLUMN text_encoding TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE musics ADD COLUMN audio_hash TEXT NOT NULL DEFAULT ''`,
+}
+
 func defaultIfEmptyString(value string, defaultValue string) string {
 	if value == "" {
 		return defaultValue
@@ -121,8 +217,9 @@ func defaultIfEmptyString(value string, defaultValue string) string {
 	return value
 }
 
-// extract details from music
-func (d *DataBase) extractMusicTag(musicPath string) (map[string]any, error) {
+// extract details from music, the ISO-8859-1 text of the ID3 tags is decoded
+// with decoder
+func (d *DataBase) extractMusicTag(musicPath string, decoder musictag.TextDecoder) (map[string]any, error) {
 	file, err := os.Open(musicPath)
 	if err != nil {
 		d.logger.Printf("ERROR: %s -> %s\n", err.Error(), musicPath)
@@ -130,23 +227,101 @@ func (d *DataBase) extractMusicTag(musicPath string) (map[string]any, error) {
 	}
 	defer file.Close()
 
-	tag, err := musictag.ReadFrom(file)
+	tag, err := musictag.ReadFrom(file, musictag.WithTextDecoder(decoder), musictag.WithoutPictures())
 	if err != nil {
 		d.logger.Printf("ERROR: failed to read the tag from %s: %v", musicPath, err)
 		return nil, err
 	}
+	track, trackTotal := tag.GetTrack()
+	disc, discTotal := tag.GetDisc()
+
 	var musicDetails = map[string]any{
 		"title":       defaultIfEmptyString(tag.GetTitle(), filepath.Base(musicPath)),
 		"album":       defaultIfEmptyString(tag.GetAlbum(), "Unknown"),
 		"artistRaw":   defaultIfEmptyString(tag.GetArtist(), "Unknown"),
+		"artists":     d.artistNames(tag),
 		"albumArtist": defaultIfEmptyString(tag.GetAlbumArtist(), "Unknown"),
 		"year":        tag.GetYear(),
 		"genre":       defaultIfEmptyString(tag.GetGenre(), "Unknown"),
+		"duration":    int64(0),
+		"track":       track,
+		"trackTotal":  trackTotal,
+		"disc":        disc,
+		"discTotal":   discTotal,
+		"composer":    tag.GetComposer(),
+		"bpm":         tag.GetBPM(),
+		"lyrics":      tag.GetLyrics(),
+		"mbTrackID":   tag.GetCustom(musictag.MusicBrainzTrackID),
+		"mbAlbumID":   tag.GetCustom(musictag.MusicBrainzAlbumID),
+		"mbArtistID":  tag.GetCustom(musictag.MusicBrainzArtistID),
+		"chapters":    tag.GetChapters(),
+		"rating":      tag.GetRating(),
+		"playCount":   tag.GetPlayCount(),
+		"audioHash":   "",
+	}
+
+	// gains are NULL when the tag has no ReplayGain
+	rg := musictag.ReadReplayGain(tag)
+	musicDetails["rgTrackGain"] = nil
+	musicDetails["rgTrackPeak"] = rg.TrackPeak
+	musicDetails["rgAlbumGain"] = nil
+	musicDetails["rgAlbumPeak"] = rg.AlbumPeak
+	if rg.HasTrack {
+		musicDetails["rgTrackGain"] = rg.TrackGain
+	}
+	if rg.HasAlbum {
+		musicDetails["rgAlbumGain"] = rg.AlbumGain
+	}
+
+	// a sidecar .lrc file is preferred over the lyrics embedded in the tag,
+	// synchronised lyrics are stored in the LRC format
+	syncedLyrics, err := musictag.ReadLRCFile(musicPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			d.logger.Printf("ERROR: failed to read the lrc file of %s: %v", musicPath, err)
+		}
+		syncedLyrics = tag.GetSyncedLyrics()
+	}
+	musicDetails["syncedLyrics"] = musictag.FormatLRC(syncedLyrics)
+
+	// audio properties are only available for MP3 for now
+	fileType := tag.GetFileType()
+	if fileType == musictag.MP3 {
+		if props, err := musictag.ReadAudioProperties(file); err == nil {
+			musicDetails["duration"] = props.Duration.Milliseconds()
+		}
+	}
+
+	// the hash of the audio data finds the copies of the song tagged
+	// differently, the audio of an unknown container can't be told from its
+	// metadata
+	musicDetails["audioHash"] = noAudioHash
+	if fileType != musictag.UnknownFileType {
+		if hash, err := musictag.AudioHash(file); err == nil {
+			musicDetails["audioHash"] = hash
+		} else {
+			d.logger.Printf("ERROR: failed to hash the audio of %s: %v", musicPath, err)
+		}
 	}
 
 	return musicDetails, nil
 }
 
+const insertMusicQuery = `INSERT INTO musics(title, artist, album, album_artist, year, genre, music_location, duration, track, track_total, disc, disc_total, composer, bpm, lyrics, synced_lyrics, mb_track_id, mb_album_id, mb_artist_id,
+	rg_track_gain, rg_track_peak, rg_album_gain, rg_album_peak, rating, play_count, audio_hash)
+	VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+// arguments of insertMusicQuery from the details returned by extractMusicTag
+func insertMusicArgs(tag map[string]any, musicPath string) []any {
+	return []any{
+		tag["title"], tag["artistRaw"], tag["album"], tag["albumArtist"], tag["year"], tag["genre"], musicPath, tag["duration"],
+		tag["track"], tag["trackTotal"], tag["disc"], tag["discTotal"], tag["composer"], tag["bpm"],
+		tag["lyrics"], tag["syncedLyrics"], tag["mbTrackID"], tag["mbAlbumID"], tag["mbArtistID"],
+		tag["rgTrackGain"], tag["rgTrackPeak"], tag["rgAlbumGain"], tag["rgAlbumPeak"],
+		tag["rating"], tag["playCount"], tag["audioHash"],
+	}
+}
+
 // can be *sql.db or *sql.Tx
 type Queryer interface {
 	QueryRow(query string, args ...any) *sql.Row
@@ -178,6 +353,18 @@ func (d *DataBase) insertOrGetArtistID(db Queryer, artist string) (int64, error)
 	return artistID, nil
 }
 
+// insert the chapters of the music, position is the index of the chapter
+func (d *DataBase) insertChapters(db Queryer, musicID int64, chapters []musictag.Chapter) error {
+	for i, c := range chapters {
+		_, err := db.Exec(`INSERT OR REPLACE INTO chapters (music_id, position, title, start_ms, end_ms) VALUES (?, ?, ?, ?, ?)`,
+			musicID, i, c.Title, c.Start.Milliseconds(), c.End.Milliseconds())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Read and store to database single music
 func (d *DataBase) PushSingleMusicsToTable(musicPath string) error {
 	err := d.DB.Ping()
@@ -187,15 +374,13 @@ func (d *DataBase) PushSingleMusicsToTable(musicPath string) error {
 		}
 	}
 
-	spLitter := regexp.MustCompile(`\s*(?:/|&|,)\s*`)
-
-	tag, err := d.extractMusicTag(musicPath)
+	tag, err := d.extractMusicTag(musicPath, d.textDecoder)
 	if err != nil {
 		d.logger.Printf("ERROR: failed to read the tag from %s: %v", musicPath, err)
 		return err
 	}
 
-	result, err := d.DB.Exec("INSERT INTO musics(title, artist, album, album_artist, year, genre, music_location) VALUES( ?, ?, ?, ?, ?, ?, ? )", tag["title"], tag["artistRaw"], tag["album"], tag["albumArtist"], tag["year"], tag["genre"], musicPath)
+	result, err := d.DB.Exec(insertMusicQuery, insertMusicArgs(tag, musicPath)...)
 	if err != nil {
 		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
 			d.logger.Printf("INFO: music \"%s\" alrady exists :)\n", tag["title"].(string))
@@ -214,13 +399,7 @@ func (d *DataBase) PushSingleMusicsToTable(musicPath string) error {
 		return errors.New("Music may be exit or some other error have to check")
 	}
 
-	artistNames := spLitter.Split(tag["artistRaw"].(string), -1)
-	for _, artist := range artistNames {
-		artist = strings.TrimSpace(artist)
-		if artist == "" {
-			continue
-		}
-
+	for _, artist := range tag["artists"].([]string) {
 		artistID, err := d.insertOrGetArtistID(d.DB, artist)
 		if err != nil || artistID == 0 {
 			continue
@@ -233,6 +412,11 @@ func (d *DataBase) PushSingleMusicsToTable(musicPath string) error {
 		}
 	}
 
+	if err := d.insertChapters(d.DB, musicID, tag["chapters"].([]musictag.Chapter)); err != nil {
+		d.logger.Println("ERROR: insert into chapters:", err)
+		return err
+	}
+
 	return nil
 }
 
@@ -260,7 +444,7 @@ func (d *DataBase) PushMusicsTOmusicsTable(musicPaths []string) error {
 	}()
 
 	//TODOO: handel propery
-	stmt, err := tx.Prepare("INSERT INTO musics(title, artist, album, album_artist, year, genre, music_location) VALUES( ?, ?, ?, ?, ?, ?, ? )")
+	stmt, err := tx.Prepare(insertMusicQuery)
 	if err != nil {
 		return err
 	}
@@ -271,16 +455,15 @@ func (d *DataBase) PushMusicsTOmusicsTable(musicPaths []string) error {
 		}
 	}()
 
-	spLitter := regexp.MustCompile(`\s*(?:/|&|,)\s*`)
 	for _, mPath := range musicPaths {
-		tag, err := d.extractMusicTag(mPath)
+		tag, err := d.extractMusicTag(mPath, d.textDecoder)
 		if err != nil {
 			d.logger.Printf("ERROR: failed to read the tag from %s: %v", mPath, err)
 			continue
 		}
 
 		//TODOO: handel error
-		result, err := stmt.Exec(tag["title"], tag["artistRaw"], tag["album"], tag["albumArtist"], tag["year"].(int), tag["genre"], mPath)
+		result, err := stmt.Exec(insertMusicArgs(tag, mPath)...)
 		if err != nil {
 			if strings.Contains(err.Error(), "UNIQUE constraint failed") {
 				d.logger.Printf("INFO: music \"%s\" alrady exists :)\n", tag["title"].(string))
@@ -303,15 +486,8 @@ func (d *DataBase) PushMusicsTOmusicsTable(musicPaths []string) error {
 		}
 
 		// Normalize artist(s) immediately
-		artistNames := spLitter.Split(tag["artistRaw"].(string), -1)
-
-		for _, artist := range artistNames {
-			artist = strings.TrimSpace(artist)
-			if artist == "" {
-				continue
-			}
-
-			artistID, err := d.insertOrGetArtistID(d.DB, artist)
+		for _, artist := range tag["artists"].([]string) {
+			artistID, err := d.insertOrGetArtistID(tx, artist)
 			if err != nil || artistID == 0 {
 				continue
 			}
@@ -321,6 +497,10 @@ func (d *DataBase) PushMusicsTOmusicsTable(musicPaths []string) error {
 				d.logger.Println("ERROR: insert into music_artists:", err)
 			}
 		}
+
+		if err := d.insertChapters(tx, musicID, tag["chapters"].([]musictag.Chapter)); err != nil {
+			d.logger.Println("ERROR: insert into chapters:", err)
+		}
 	}
 
 	//TODO: handel error properly
@@ -342,6 +522,96 @@ type Music struct {
 	Genre       string
 	Year        int
 	Path        string
+	Duration    time.Duration
+	Track       int
+	TrackTotal  int
+	Disc        int
+	DiscTotal   int
+	Composer    string
+	BPM         int
+
+	// MusicBrainz identifiers
+	MBTrackID  string
+	MBAlbumID  string
+	MBArtistID string
+
+	ReplayGain musictag.ReplayGain
+
+	Rating    int // 0 to 5 stars, 0 if not rated
+	PlayCount int
+
+	AudioHash string // hash of the audio data (see musictag.AudioHash), empty if unknown
+}
+
+// DurationString returns the duration formatted as m:ss
+func (m Music) DurationString() string {
+	seconds := int(m.Duration.Seconds())
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
+
+// the artists of the music in music_artists, separated by char(31)
+const musicArtistsColumn = `(SELECT GROUP_CONCAT(a.name, char(31) ORDER BY ma.rowid)
+	FROM music_artists ma JOIN artists a ON a.id = ma.artist_id WHERE ma.music_id = musics.id)`
+
+// Star is a star of the rating displayed by the templates
+type Star struct {
+	Value  int
+	Filled bool
+}
+
+// Stars returns the 5 stars of the rating
+func (m Music) Stars() []Star {
+	stars := make([]Star, 5)
+	for i := range stars {
+		stars[i] = Star{Value: i + 1, Filled: i < m.Rating}
+	}
+	return stars
+}
+
+// columns of musics table in the order scanned by scanMusic
+const musicColumns = `id, title, artist, ` + musicArtistsColumn + `, album, album_artist, year, genre, music_location, duration, track, track_total, disc, disc_total, composer, bpm, mb_track_id, mb_album_id, mb_artist_id, rg_track_gain, rg_track_peak, rg_album_gain, rg_album_peak,
+	r128_track_gain, r128_track_peak, r128_album_gain, r128_album_peak, rating, play_count, audio_hash`
+
+// can be *sql.Row or *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanMusic(row rowScanner) (*Music, error) {
+	var m = new(Music)
+	var artistRaw string
+	var artists sql.NullString
+	var duration int64
+	var trackGain, albumGain sql.NullFloat64
+	var analyzed [4]sql.NullFloat64
+	err := row.Scan(&m.Id, &m.Title, &artistRaw, &artists, &m.Album, &m.AlbumArtist, &m.Year, &m.Genre, &m.Path, &duration,
+		&m.Track, &m.TrackTotal, &m.Disc, &m.DiscTotal, &m.Composer, &m.BPM,
+		&m.MBTrackID, &m.MBAlbumID, &m.MBArtistID,
+		&trackGain, &m.ReplayGain.TrackPeak, &albumGain, &m.ReplayGain.AlbumPeak,
+		&analyzed[0], &analyzed[1], &analyzed[2], &analyzed[3], &m.Rating, &m.PlayCount, &m.AudioHash)
+	if err != nil {
+		return nil, err
+	}
+	if m.AudioHash == noAudioHash {
+		m.AudioHash = ""
+	}
+	m.ReplayGain.TrackGain, m.ReplayGain.HasTrack = trackGain.Float64, trackGain.Valid
+	m.ReplayGain.AlbumGain, m.ReplayGain.HasAlbum = albumGain.Float64, albumGain.Valid
+
+	// the values of the tags are preferred over the analyzed ones
+	if !m.ReplayGain.HasTrack && analyzed[0].Valid {
+		m.ReplayGain.TrackGain, m.ReplayGain.TrackPeak, m.ReplayGain.HasTrack = analyzed[0].Float64, analyzed[1].Float64, true
+	}
+	if !m.ReplayGain.HasAlbum && analyzed[2].Valid {
+		m.ReplayGain.AlbumGain, m.ReplayGain.AlbumPeak, m.ReplayGain.HasAlbum = analyzed[2].Float64, analyzed[3].Float64, true
+	}
+	// musics without artists in music_artists show the artist tag as is
+	m.Artists = []string{artistRaw}
+	if artists.Valid {
+		m.Artists = strings.Split(artists.String, "\x1f")
+	}
+	m.Duration = time.Duration(duration) * time.Millisecond
+	return m, nil
 }
 
 // random music quary
@@ -353,31 +623,171 @@ func (d *DataBase) GetRandomMusic() (*Music, error) {
 			return nil, err
 		}
 	}
-	var m = new(Music)
-	var artistRaw string
-	err = d.DB.QueryRow("SELECT * FROM musics ORDER BY RANDOM() LIMIT 1").Scan(&m.Id, &m.Title, &artistRaw, &m.Album, &m.AlbumArtist, &m.Year, &m.Genre, &m.Path)
+	return scanMusic(d.DB.QueryRow("SELECT " + musicColumns + " FROM musics ORDER BY RANDOM() LIMIT 1"))
+}
+
+func (d *DataBase) GetMusicBYID(songId int64) (*Music, error) {
+	err := d.DB.Ping()
 	if err != nil {
-		return nil, err
+		if err := d.ReConnect(); err != nil {
+			return nil, err
+		}
 	}
-	m.Artists = artistSpLitter.Split(artistRaw, -1)
-	return m, nil
+	return scanMusic(d.DB.QueryRow("SELECT "+musicColumns+" FROM musics WHERE id = ?", songId))
 }
 
-func (d *DataBase) GetMusicBYID(songId int64) (*Music, error) {
+// GetLyricsByID returns the unsynchronised and synchronised lyrics of the song,
+// the lyrics are not part of Music to keep the listings small.
+func (d *DataBase) GetLyricsByID(songId int64) (string, []musictag.LyricLine, error) {
+	err := d.DB.Ping()
+	if err != nil {
+		if err := d.ReConnect(); err != nil {
+			return "", nil, err
+		}
+	}
+
+	var lyrics, syncedLyrics string
+	err = d.DB.QueryRow("SELECT lyrics, synced_lyrics FROM musics WHERE id = ?", songId).Scan(&lyrics, &syncedLyrics)
+	if err != nil {
+		return "", nil, err
+	}
+
+	lines, err := musictag.ParseLRC(strings.NewReader(syncedLyrics))
+	if err != nil {
+		return "", nil, err
+	}
+	return lyrics, lines, nil
+}
+
+// SetRating sets the rating (0 to 5 stars) of the music, sql.ErrNoRows if
+// the music does not exist.
+func (d *DataBase) SetRating(songId int64, rating int) error {
+	err := d.DB.Ping()
+	if err != nil {
+		if err := d.ReConnect(); err != nil {
+			return err
+		}
+	}
+
+	if rating < 0 || rating > 5 {
+		return fmt.Errorf("invalid rating %d, expected 0 to 5", rating)
+	}
+
+	result, err := d.DB.Exec("UPDATE musics SET rating = ? WHERE id = ?", rating, songId)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SetTextEncoding decodes the ISO-8859-1 text of the ID3 tags of the song
+// with the code page and updates the text columns, the code page is kept in
+// text_encoding. "" restores the configured decoding. sql.ErrNoRows is
+// returned if the song does not exist.
+func (d *DataBase) SetTextEncoding(songId int64, codePage string) (err error) {
+	err = d.DB.Ping()
+	if err != nil {
+		if err := d.ReConnect(); err != nil {
+			return err
+		}
+	}
+
+	decoder := d.textDecoder
+	if codePage != "" {
+		decoder, err = musictag.NewTextDecoder(codePage, false)
+		if err != nil {
+			return err
+		}
+	}
+
+	var musicPath string
+	err = d.DB.QueryRow("SELECT music_location FROM musics WHERE id = ?", songId).Scan(&musicPath)
+	if err != nil {
+		return err
+	}
+
+	tag, err := d.extractMusicTag(musicPath, decoder)
+	if err != nil {
+		return err
+	}
+
+	tx, err := d.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	_, err = tx.Exec(`UPDATE musics SET title = ?, artist = ?, album = ?, album_artist = ?, genre = ?, composer = ?, lyrics = ?, synced_lyrics = ?, text_encoding = ?
+		WHERE id = ?`,
+		tag["title"], tag["artistRaw"], tag["album"], tag["albumArtist"], tag["genre"], tag["composer"], tag["lyrics"], tag["syncedLyrics"], codePage, songId)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM music_artists WHERE music_id = ?`, songId)
+	if err != nil {
+		return err
+	}
+
+	for _, artist := range tag["artists"].([]string) {
+		artistID, err := d.insertOrGetArtistID(tx, artist)
+		if err != nil || artistID == 0 {
+			continue
+		}
+
+		_, err = tx.Exec(`INSERT OR IGNORE INTO music_artists (music_id, artist_id) VALUES (?, ?)`, songId, artistID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetChaptersByID returns the chapters of the music, sql.ErrNoRows if the
+// music does not exist.
+func (d *DataBase) GetChaptersByID(songId int64) ([]musictag.Chapter, error) {
 	err := d.DB.Ping()
 	if err != nil {
 		if err := d.ReConnect(); err != nil {
 			return nil, err
 		}
 	}
-	var m = new(Music)
-	var artistRaw string
-	err = d.DB.QueryRow("SELECT * FROM musics WHERE id = ?", songId).Scan(&m.Id, &m.Title, &artistRaw, &m.Album, &m.AlbumArtist, &m.Year, &m.Genre, &m.Path)
+
+	if err = d.DB.QueryRow("SELECT id FROM musics WHERE id = ?", songId).Scan(&songId); err != nil {
+		return nil, err
+	}
+
+	rows, err := d.DB.Query("SELECT title, start_ms, end_ms FROM chapters WHERE music_id = ? ORDER BY position ASC", songId)
 	if err != nil {
 		return nil, err
 	}
-	m.Artists = artistSpLitter.Split(artistRaw, -1)
-	return m, nil
+	defer rows.Close()
+
+	chapters := make([]musictag.Chapter, 0)
+	for rows.Next() {
+		var c musictag.Chapter
+		var start, end int64
+		if err := rows.Scan(&c.Title, &start, &end); err != nil {
+			return nil, err
+		}
+		c.Start = time.Duration(start) * time.Millisecond
+		c.End = time.Duration(end) * time.Millisecond
+		chapters = append(chapters, c)
+	}
+	return chapters, rows.Err()
 }
 
 func (d *DataBase) GetAllMusics() ([]Music, error) {
@@ -389,22 +799,19 @@ func (d *DataBase) GetAllMusics() ([]Music, error) {
 	}
 
 	songs := make([]Music, 0)
-	rows, err := d.DB.Query(`SELECT * FROM musics ORDER BY id ASC`)
+	rows, err := d.DB.Query(`SELECT ` + musicColumns + ` FROM musics ORDER BY id ASC`)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var m Music
-		var artistRaw string
-		err = rows.Scan(&m.Id, &m.Title, &artistRaw, &m.Album, &m.AlbumArtist, &m.Year, &m.Genre, &m.Path)
+		m, err := scanMusic(rows)
 		if err != nil {
 			d.logger.Printf("ERROR: could not scan row: %v\n", err)
 			continue
 		}
-		m.Artists = artistSpLitter.Split(artistRaw, -1)
-		songs = append(songs, m)
+		songs = append(songs, *m)
 	}
 
 	if len(songs) == 0 {
@@ -424,12 +831,10 @@ func (d *DataBase) GetAllMusicsByArtistID(artistID int64) ([]Music, error) {
 
 	songs := make([]Music, 0)
 	query := `
-	SELECT m.*
-	FROM musics m
-	JOIN music_artists ma ON m.id = ma.music_id
-	JOIN artists a ON ma.artist_id = a.id
-	WHERE a.id = ?
-	ORDER BY m.id ASC`
+	SELECT ` + musicColumns + `
+	FROM musics
+	WHERE id IN (SELECT music_id FROM music_artists WHERE artist_id = ?)
+	ORDER BY id ASC`
 
 	rows, err := d.DB.Query(query, artistID)
 	if err != nil {
@@ -438,15 +843,12 @@ func (d *DataBase) GetAllMusicsByArtistID(artistID int64) ([]Music, error) {
 	defer rows.Close()
 
 	for rows.Next() {
-		var m Music
-		var rawArtists string
-		err = rows.Scan(&m.Id, &m.Title, &rawArtists, &m.Album, &m.AlbumArtist, &m.Year, &m.Genre, &m.Path)
+		m, err := scanMusic(rows)
 		if err != nil {
 			d.logger.Printf("ERROR: could not scan row: %v\n", err)
 			continue
 		}
-		m.Artists = artistSpLitter.Split(rawArtists, -1)
-		songs = append(songs, m)
+		songs = append(songs, *m)
 	}
 
 	if len(songs) == 0 {
@@ -464,8 +866,9 @@ func (d *DataBase) GetMusicsByAlbumName(albumName string) ([]Music, error) {
 		}
 	}
 
+	// the songs without disc or track number (0) come last
 	songs := make([]Music, 0)
-	query := `SELECT * FROM musics WHERE album = ? ORDER BY id ASC`
+	query := `SELECT ` + musicColumns + ` FROM musics WHERE album = ? ORDER BY disc = 0, disc, track = 0, track, id`
 
 	rows, err := d.DB.Query(query, albumName)
 	if err != nil {
@@ -474,15 +877,47 @@ func (d *DataBase) GetMusicsByAlbumName(albumName string) ([]Music, error) {
 	defer rows.Close()
 
 	for rows.Next() {
-		var m Music
-		var rawArtists string
-		err = rows.Scan(&m.Id, &m.Title, &rawArtists, &m.Album, &m.AlbumArtist, &m.Year, &m.Genre, &m.Path)
+		m, err := scanMusic(rows)
+		if err != nil {
+			d.logger.Printf("ERROR: could not scan row: %v\n", err)
+			continue
+		}
+		songs = append(songs, *m)
+	}
+
+	if len(songs) == 0 {
+		return nil, err
+	}
+
+	return songs, err
+}
+
+// GetMusicsByAlbumID returns the songs of the album with the given MusicBrainz
+// album id, unlike GetMusicsByAlbumName albums with the same name aren't mixed.
+func (d *DataBase) GetMusicsByAlbumID(albumID string) ([]Music, error) {
+	err := d.DB.Ping()
+	if err != nil {
+		if err := d.ReConnect(); err != nil {
+			return nil, err
+		}
+	}
+
+	songs := make([]Music, 0)
+	query := `SELECT ` + musicColumns + ` FROM musics WHERE mb_album_id = ? ORDER BY disc = 0, disc, track = 0, track, id`
+
+	rows, err := d.DB.Query(query, albumID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		m, err := scanMusic(rows)
 		if err != nil {
 			d.logger.Printf("ERROR: could not scan row: %v\n", err)
 			continue
 		}
-		m.Artists = artistSpLitter.Split(rawArtists, -1)
-		songs = append(songs, m)
+		songs = append(songs, *m)
 	}
 
 	if len(songs) == 0 {
@@ -497,6 +932,7 @@ type Album struct {
 	Name       string
 	Artist     string
 	SongsCount int
+	MBAlbumID  string // empty if the songs are not tagged with a MusicBrainz album id
 }
 
 // extract all the albums
@@ -510,9 +946,9 @@ func (d *DataBase) GetAllAlbums() ([]Album, error) {
 
 	var albums = make([]Album, 0)
 	rows, err := d.DB.Query(`
-		SELECT album, album_artist, COUNT(*) as songs_count
+		SELECT album, album_artist, COUNT(*) as songs_count, mb_album_id
 		FROM musics
-		GROUP BY album
+		GROUP BY album, mb_album_id
 		ORDER BY songs_count DESC, album`)
 	if err != nil {
 		return nil, err
@@ -521,7 +957,7 @@ func (d *DataBase) GetAllAlbums() ([]Album, error) {
 
 	for rows.Next() {
 		var a Album
-		err = rows.Scan(&a.Name, &a.Artist, &a.SongsCount)
+		err = rows.Scan(&a.Name, &a.Artist, &a.SongsCount, &a.MBAlbumID)
 		if err != nil {
 			d.logger.Printf("ERROR: could not scan row: %v\n", err)
 			continue
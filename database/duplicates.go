@@ -0,0 +1,131 @@
+package database
+
+import (
+	"errors"
+	"music-go/musictag"
+	"os"
+)
+
+// noAudioHash is stored as the audio hash of the songs which can't be hashed
+// (unknown container, invalid audio data), they are not hashed again.
+const noAudioHash = "-"
+
+// DuplicateGroup is a set of songs with the same audio data, the files only
+// differ by their tags or their container metadata.
+type DuplicateGroup struct {
+	AudioHash string
+	Songs     []Music
+}
+
+// GetDuplicates returns the songs whose audio is stored more than once,
+// grouped by audio hash. The songs without hash (see UpdateAudioHashes) are
+// ignored.
+func (d *DataBase) GetDuplicates() ([]DuplicateGroup, error) {
+	err := d.DB.Ping()
+	if err != nil {
+		if err := d.ReConnect(); err != nil {
+			return nil, err
+		}
+	}
+
+	query := `SELECT ` + musicColumns + ` FROM musics
+	WHERE audio_hash IN (SELECT audio_hash FROM musics WHERE audio_hash NOT IN ('', '` + noAudioHash + `') GROUP BY audio_hash HAVING COUNT(*) > 1)
+	ORDER BY audio_hash ASC, id ASC`
+
+	rows, err := d.DB.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []DuplicateGroup
+	for rows.Next() {
+		m, err := scanMusic(rows)
+		if err != nil {
+			d.logger.Printf("ERROR: could not scan row: %v\n", err)
+			continue
+		}
+
+		if len(groups) == 0 || groups[len(groups)-1].AudioHash != m.AudioHash {
+			groups = append(groups, DuplicateGroup{AudioHash: m.AudioHash})
+		}
+		last := &groups[len(groups)-1]
+		last.Songs = append(last.Songs, *m)
+	}
+	return groups, rows.Err()
+}
+
+// UpdateAudioHashes computes the audio hash of the songs which don't have
+// one, the songs scanned before the hash was stored. It returns the number
+// of songs hashed, the files which can't be hashed are logged and marked
+// with noAudioHash so they aren't tried again by the next calls.
+func (d *DataBase) UpdateAudioHashes() (int, error) {
+	err := d.DB.Ping()
+	if err != nil {
+		if err := d.ReConnect(); err != nil {
+			return 0, err
+		}
+	}
+
+	songs, err := d.getSongsWithoutAudioHash()
+	if err != nil {
+		return 0, err
+	}
+
+	updated := 0
+	for id, path := range songs {
+		hash, err := audioHash(path)
+		if err != nil {
+			d.logger.Printf("ERROR: failed to hash the audio of %s: %v", path, err)
+			hash = noAudioHash
+		}
+
+		if _, err := d.DB.Exec(`UPDATE musics SET audio_hash = ? WHERE id = ?`, hash, id); err != nil {
+			return updated, err
+		}
+		if hash != noAudioHash {
+			updated++
+		}
+	}
+	return updated, nil
+}
+
+// the path of the songs without audio hash by id
+func (d *DataBase) getSongsWithoutAudioHash() (map[int64]string, error) {
+	rows, err := d.DB.Query(`SELECT id, music_location FROM musics WHERE audio_hash = ''`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	songs := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var path string
+		if err := rows.Scan(&id, &path); err != nil {
+			return nil, err
+		}
+		songs[id] = path
+	}
+	return songs, rows.Err()
+}
+
+// audioHash hashes the audio of the file, the audio of an unknown container
+// can't be told from its metadata (see extractMusicTag).
+func audioHash(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	fileType, err := musictag.DetectFileType(file)
+	if err != nil {
+		return "", err
+	}
+	if fileType == musictag.UnknownFileType {
+		return "", errors.New("unknown file type")
+	}
+
+	return musictag.AudioHash(file)
+}
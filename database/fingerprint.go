@@ -0,0 +1,459 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"music-go/fingerprint"
+	"music-go/musictag"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrFingerprintRunning = errors.New("fingerprinting is already running")
+	ErrCannotFingerprint  = errors.New("the audio format can't be decoded")
+	ErrThresholdTooLow    = errors.New("the threshold is lower than the fingerprinting threshold")
+)
+
+// FingerprintProgress is the progress of a fingerprinting job, the songs
+// which can't be decoded (see fingerprintSong) are counted as Unsupported
+// rather than Failed.
+type FingerprintProgress struct {
+	Running     bool   `json:"running"`
+	Total       int    `json:"total"`
+	Done        int    `json:"done"`
+	Failed      int    `json:"failed"`
+	Unsupported int    `json:"unsupported"`
+	Current     string `json:"current"`
+}
+
+// FingerprintJob computes in the background the acoustic fingerprint of the
+// songs which don't have one, then compares the fingerprints to store the
+// copies of a song encoded differently (see GetNearDuplicates). The pairs of
+// songs with a similarity of at least the configured threshold are stored.
+type FingerprintJob struct {
+	db        *DataBase
+	threshold float64
+	mu        sync.Mutex
+	progress  FingerprintProgress
+}
+
+func (d *DataBase) NewFingerprintJob() *FingerprintJob {
+	return &FingerprintJob{db: d, threshold: d.config.Fingerprint.Threshold}
+}
+
+// Progress returns the progress of the current (or last) job
+func (j *FingerprintJob) Progress() FingerprintProgress {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.progress
+}
+
+func (j *FingerprintJob) update(f func(p *FingerprintProgress)) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	f(&j.progress)
+}
+
+// Start the job in the background, returns ErrFingerprintRunning if the job
+// is already running.
+func (j *FingerprintJob) Start() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.progress.Running {
+		return ErrFingerprintRunning
+	}
+	j.progress = FingerprintProgress{Running: true}
+
+	go j.run()
+	return nil
+}
+
+func (j *FingerprintJob) run() {
+	defer j.update(func(p *FingerprintProgress) {
+		p.Running = false
+		p.Current = ""
+	})
+
+	songs, err := j.db.getSongsToFingerprint()
+	if err != nil {
+		j.db.logger.Printf("ERROR: fingerprinting: could not get the songs to fingerprint: %v", err)
+		return
+	}
+	j.update(func(p *FingerprintProgress) { p.Total = len(songs) })
+	j.db.logger.Printf("INFO: fingerprinting of %d songs started", len(songs))
+
+	for _, song := range songs {
+		j.update(func(p *FingerprintProgress) { p.Current = song.path })
+
+		err := j.db.fingerprintSong(song.id, song.path)
+		unsupported := errors.Is(err, ErrCannotFingerprint)
+		if unsupported {
+			j.db.logger.Printf("WARNING: fingerprinting of %s: %v", song.path, err)
+		} else if err != nil {
+			j.db.logger.Printf("ERROR: fingerprinting of %s: %v", song.path, err)
+		}
+		j.update(func(p *FingerprintProgress) {
+			p.Done++
+			if unsupported {
+				p.Unsupported++
+			} else if err != nil {
+				p.Failed++
+			}
+		})
+	}
+
+	p := j.Progress()
+	j.db.logger.Printf("INFO: fingerprinting finished: %d songs fingerprinted, %d failed, %d in an unsupported format",
+		p.Done-p.Failed-p.Unsupported, p.Failed, p.Unsupported)
+
+	// the songs fingerprinted by the previous runs are compared too
+	pairs, err := j.db.storeNearDuplicates(j.threshold)
+	if err != nil {
+		j.db.logger.Printf("ERROR: fingerprinting: could not store the near duplicates: %v", err)
+		return
+	}
+	j.db.logger.Printf("INFO: %d pairs of near duplicates found", pairs)
+}
+
+type songPath struct {
+	id   int64
+	path string
+}
+
+// the format is detected from the content of the files by fingerprintSong
+func (d *DataBase) getSongsToFingerprint() ([]songPath, error) {
+	err := d.DB.Ping()
+	if err != nil {
+		if err := d.ReConnect(); err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err := d.DB.Query(`
+	SELECT id, music_location
+	FROM musics
+	WHERE id NOT IN (SELECT music_id FROM fingerprints)
+	ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var songs []songPath
+	for rows.Next() {
+		var s songPath
+		if err := rows.Scan(&s.id, &s.path); err != nil {
+			d.logger.Printf("ERROR: could not scan row: %v\n", err)
+			continue
+		}
+		songs = append(songs, s)
+	}
+	return songs, rows.Err()
+}
+
+// fingerprintSong stores the fingerprint of the song with its quality: the
+// lossless files are preferred, then the highest bitrate. The decoder is
+// chosen from the detected container: MP3, FLAC, WAV and AIFF (PCM) can be
+// decoded, ErrCannotFingerprint is returned for the other formats (MP4, Ogg,
+// APE, WavPack, DSF...) which are neither fingerprinted nor compared.
+func (d *DataBase) fingerprintSong(id int64, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	fileType, err := musictag.DetectFileType(file)
+	if err != nil {
+		return err
+	}
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	var fp fingerprint.Fingerprint
+	var format fingerprint.Format
+	switch fileType {
+	case musictag.MP3:
+		fp, format, err = fingerprint.FromMP3(file)
+	case musictag.FLAC:
+		fp, format, err = fingerprint.FromFLAC(file)
+	case musictag.WAV:
+		fp, format, err = fingerprint.FromWAV(file)
+	case musictag.AIFF:
+		fp, format, err = fingerprint.FromAIFF(file)
+	case musictag.UnknownFileType:
+		return fmt.Errorf("%w: unknown format", ErrCannotFingerprint)
+	default:
+		return fmt.Errorf("%w: %s", ErrCannotFingerprint, fileType)
+	}
+	if errors.Is(err, fingerprint.ErrUnsupportedEncoding) {
+		// compressed WAV or AIFF
+		return fmt.Errorf("%w: %v", ErrCannotFingerprint, err)
+	} else if err != nil {
+		return err
+	}
+
+	// the PCM bitrate tells the resolution of the lossless copies
+	lossless := format.BitsPerSample > 0
+	bitrate := format.Bitrate()
+	if !lossless {
+		if _, err = file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		if props, err := musictag.ReadAudioProperties(file); err == nil {
+			bitrate = props.Bitrate
+		}
+	}
+
+	_, err = d.DB.Exec(`INSERT OR REPLACE INTO fingerprints (music_id, fingerprint, duration_ms, lossless, bitrate) VALUES (?, ?, ?, ?, ?)`,
+		id, fp.Bytes(), format.Duration.Milliseconds(), lossless, bitrate)
+	return err
+}
+
+// songFingerprint is a fingerprint stored by fingerprintSong
+type songFingerprint struct {
+	id          int64
+	fingerprint fingerprint.Fingerprint
+	duration    time.Duration
+	lossless    bool
+	bitrate     int
+}
+
+// better reports whether the copy s has a better quality than o, the
+// oldest song wins a tie.
+func (s *songFingerprint) better(o *songFingerprint) bool {
+	if s.lossless != o.lossless {
+		return s.lossless
+	}
+	if s.bitrate != o.bitrate {
+		return s.bitrate > o.bitrate
+	}
+	return s.id < o.id
+}
+
+// the copies of a song have almost the same duration, the encoders add
+// some padding
+const maxDurationDiff = 3 * time.Second
+
+// getFingerprints returns the fingerprints of all the songs sorted by
+// duration, the unknown durations first.
+func (d *DataBase) getFingerprints() ([]*songFingerprint, error) {
+	rows, err := d.DB.Query(`SELECT music_id, fingerprint, duration_ms, lossless, bitrate FROM fingerprints`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fps []*songFingerprint
+	for rows.Next() {
+		var s songFingerprint
+		var b []byte
+		var duration int64
+		if err := rows.Scan(&s.id, &b, &duration, &s.lossless, &s.bitrate); err != nil {
+			d.logger.Printf("ERROR: could not scan row: %v\n", err)
+			continue
+		}
+		s.duration = time.Duration(duration) * time.Millisecond
+
+		s.fingerprint, err = fingerprint.Parse(b)
+		if err != nil {
+			d.logger.Printf("ERROR: fingerprint of song %d: %v\n", s.id, err)
+			continue
+		}
+		fps = append(fps, &s)
+	}
+
+	sort.Slice(fps, func(i, j int) bool { return fps[i].duration < fps[j].duration })
+	return fps, rows.Err()
+}
+
+// compareFingerprints calls fn with the pairs of songs with a similarity of
+// at least threshold, fps is sorted by duration.
+func compareFingerprints(fps []*songFingerprint, threshold float64, fn func(a, b *songFingerprint, score float64)) {
+	for i, a := range fps {
+		for _, b := range fps[i+1:] {
+			if a.duration > 0 && b.duration-a.duration > maxDurationDiff {
+				break
+			}
+
+			if score := fingerprint.Similarity(a.fingerprint, b.fingerprint); score >= threshold {
+				fn(a, b, score)
+			}
+		}
+	}
+}
+
+// storeNearDuplicates compares the fingerprints of all the songs and
+// replaces the stored pairs with the ones with a similarity of at least
+// threshold, it returns the number of pairs.
+func (d *DataBase) storeNearDuplicates(threshold float64) (pairs int, err error) {
+	fps, err := d.getFingerprints()
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := d.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.Exec(`DELETE FROM near_duplicates`); err != nil {
+		return 0, err
+	}
+
+	compareFingerprints(fps, threshold, func(a, b *songFingerprint, score float64) {
+		if err != nil {
+			return
+		}
+		if b.better(a) {
+			a, b = b, a
+		}
+		_, err = tx.Exec(`INSERT INTO near_duplicates (best_id, copy_id, score) VALUES (?, ?, ?)`, a.id, b.id, score)
+		if err == nil {
+			pairs++
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return pairs, tx.Commit()
+}
+
+// NearDuplicate is a pair of songs with the same audio encoded differently,
+// Songs[0] is the copy with the best quality. Score is the similarity of
+// their fingerprints, from 0 to 1.
+type NearDuplicate struct {
+	Songs [2]Music
+	Score float64
+}
+
+// GetNearDuplicates returns the pairs of songs with a similarity of at least
+// threshold, the most similar first. The pairs are found by the last
+// FingerprintJob which only stores the pairs above the configured threshold,
+// so a lower threshold is refused with ErrThresholdTooLow.
+func (d *DataBase) GetNearDuplicates(threshold float64) ([]NearDuplicate, error) {
+	if threshold < d.config.Fingerprint.Threshold {
+		return nil, fmt.Errorf("%w: %v < %v", ErrThresholdTooLow, threshold, d.config.Fingerprint.Threshold)
+	}
+
+	err := d.DB.Ping()
+	if err != nil {
+		if err := d.ReConnect(); err != nil {
+			return nil, err
+		}
+	}
+
+	// the songs of all the pairs are read at once
+	rows, err := d.DB.Query(`SELECT `+musicColumns+` FROM musics
+	WHERE id IN (SELECT best_id FROM near_duplicates WHERE score >= ? UNION SELECT copy_id FROM near_duplicates WHERE score >= ?)`,
+		threshold, threshold)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	songs := make(map[int64]*Music)
+	for rows.Next() {
+		m, err := scanMusic(rows)
+		if err != nil {
+			d.logger.Printf("ERROR: could not scan row: %v\n", err)
+			continue
+		}
+		songs[m.Id] = m
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = d.DB.Query(`SELECT best_id, copy_id, score FROM near_duplicates WHERE score >= ? ORDER BY score DESC, best_id ASC, copy_id ASC`, threshold)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var duplicates []NearDuplicate
+	for rows.Next() {
+		var bestID, copyID int64
+		var score float64
+		if err := rows.Scan(&bestID, &copyID, &score); err != nil {
+			d.logger.Printf("ERROR: could not scan row: %v\n", err)
+			continue
+		}
+
+		// the songs removed since the job ran are skipped
+		best, other := songs[bestID], songs[copyID]
+		if best == nil || other == nil {
+			continue
+		}
+		duplicates = append(duplicates, NearDuplicate{Songs: [2]Music{*best, *other}, Score: score})
+	}
+	return duplicates, rows.Err()
+}
+
+// PreferBestCopies removes from songs the copies of a song with a better
+// quality copy in songs, the near duplicates (see GetNearDuplicates) with a
+// similarity of at least threshold are copies. The songs without fingerprint
+// are kept.
+func (d *DataBase) PreferBestCopies(songs []Music, threshold float64) ([]Music, error) {
+	if len(songs) < 2 {
+		return songs, nil
+	}
+
+	err := d.DB.Ping()
+	if err != nil {
+		if err := d.ReConnect(); err != nil {
+			return nil, err
+		}
+	}
+
+	ids := make([]any, len(songs))
+	for i, song := range songs {
+		ids[i] = song.Id
+	}
+	in := `(?` + strings.Repeat(`, ?`, len(ids)-1) + `)`
+
+	args := append([]any{threshold}, ids...)
+	args = append(args, ids...)
+	rows, err := d.DB.Query(`SELECT copy_id FROM near_duplicates WHERE score >= ? AND best_id IN `+in+` AND copy_id IN `+in, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	worse := make(map[int64]bool)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			d.logger.Printf("ERROR: could not scan row: %v\n", err)
+			continue
+		}
+		worse[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(worse) == 0 {
+		return songs, nil
+	}
+
+	best := make([]Music, 0, len(songs)-len(worse))
+	for _, song := range songs {
+		if !worse[song.Id] {
+			best = append(best, song)
+		}
+	}
+	return best, nil
+}
@@ -0,0 +1,224 @@
+package database
+
+import (
+	"errors"
+	"math"
+	"music-go/loudness"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+var ErrJobRunning = errors.New("loudness analysis is already running")
+
+// LoudnessProgress is the progress of a loudness analysis, Skipped is the
+// number of songs without computed gain which can't be analyzed (not MP3).
+type LoudnessProgress struct {
+	Running bool   `json:"running"`
+	Total   int    `json:"total"`
+	Done    int    `json:"done"`
+	Failed  int    `json:"failed"`
+	Skipped int    `json:"skipped"`
+	Current string `json:"current"`
+}
+
+// LoudnessJob analyses the loudness of the songs without computed gain in
+// the background and stores the track and album gain (ReplayGain 2.0).
+// Only the MP3 files are decoded (see loudness.AnalyzeMP3), the songs in the
+// other formats are counted as skipped.
+type LoudnessJob struct {
+	db       *DataBase
+	mu       sync.Mutex
+	progress LoudnessProgress
+}
+
+func (d *DataBase) NewLoudnessJob() *LoudnessJob {
+	return &LoudnessJob{db: d}
+}
+
+// Progress returns the progress of the current (or last) analysis
+func (j *LoudnessJob) Progress() LoudnessProgress {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.progress
+}
+
+func (j *LoudnessJob) update(f func(p *LoudnessProgress)) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	f(&j.progress)
+}
+
+// Start the analysis in the background, returns ErrJobRunning if an analysis
+// is already running.
+func (j *LoudnessJob) Start() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.progress.Running {
+		return ErrJobRunning
+	}
+	j.progress = LoudnessProgress{Running: true}
+
+	go j.run()
+	return nil
+}
+
+type loudnessSong struct {
+	id      int64
+	path    string
+	album   string
+	albumID string
+}
+
+func (j *LoudnessJob) run() {
+	defer j.update(func(p *LoudnessProgress) {
+		p.Running = false
+		p.Current = ""
+	})
+
+	albums, total, err := j.db.getAlbumsToAnalyze()
+	if err != nil {
+		j.db.logger.Printf("ERROR: loudness analysis: could not get the songs to analyze: %v", err)
+		return
+	}
+	skipped, err := j.db.countSongsNotAnalyzable()
+	if err != nil {
+		j.db.logger.Printf("ERROR: loudness analysis: could not count the songs which can't be analyzed: %v", err)
+	}
+	j.update(func(p *LoudnessProgress) {
+		p.Total = total
+		p.Skipped = skipped
+	})
+	j.db.logger.Printf("INFO: loudness analysis of %d songs started, %d songs skipped (only MP3 files can be analyzed)", total, skipped)
+
+	for _, songs := range albums {
+		j.analyzeAlbum(songs)
+	}
+
+	p := j.Progress()
+	j.db.logger.Printf("INFO: loudness analysis finished: %d songs analyzed, %d failed", p.Done-p.Failed, p.Failed)
+}
+
+// analyzeAlbum stores the gain of every song, the album gain is only stored
+// if all the songs of the album could be analyzed.
+func (j *LoudnessJob) analyzeAlbum(songs []loudnessSong) {
+	var meters []*loudness.Meter
+	for _, song := range songs {
+		j.update(func(p *LoudnessProgress) { p.Current = song.path })
+
+		m, err := j.db.analyzeSong(song)
+		if err != nil {
+			j.db.logger.Printf("ERROR: loudness analysis of %s: %v", song.path, err)
+			j.update(func(p *LoudnessProgress) {
+				p.Done++
+				p.Failed++
+			})
+			continue
+		}
+
+		meters = append(meters, m)
+		j.update(func(p *LoudnessProgress) { p.Done++ })
+	}
+
+	// songs without album are not analyzed as an album
+	if len(meters) != len(songs) || songs[0].album == "Unknown" {
+		return
+	}
+
+	var peak float64
+	for _, m := range meters {
+		peak = max(peak, m.TruePeak())
+	}
+
+	_, err := j.db.DB.Exec(`UPDATE musics SET r128_album_gain = ?, r128_album_peak = ? WHERE album = ? AND mb_album_id = ?`,
+		gainOrZero(loudness.IntegratedLoudness(meters...)), peak, songs[0].album, songs[0].albumID)
+	if err != nil {
+		j.db.logger.Printf("ERROR: could not store the album gain of %s: %v", songs[0].album, err)
+	}
+}
+
+// only MP3 files can be decoded
+func (d *DataBase) analyzeSong(song loudnessSong) (*loudness.Meter, error) {
+	if !strings.EqualFold(filepath.Ext(song.path), ".mp3") {
+		return nil, errors.New("only MP3 files can be analyzed")
+	}
+
+	file, err := os.Open(song.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	m, err := loudness.AnalyzeMP3(file)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = d.DB.Exec(`UPDATE musics SET r128_track_gain = ?, r128_track_peak = ? WHERE id = ?`,
+		gainOrZero(m.Loudness()), m.TruePeak(), song.id)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// countSongsNotAnalyzable returns the number of songs without computed gain
+// which are not MP3 files
+func (d *DataBase) countSongsNotAnalyzable() (int, error) {
+	var n int
+	err := d.DB.QueryRow(`SELECT COUNT(*) FROM musics WHERE r128_track_gain IS NULL AND music_location NOT LIKE '%.mp3'`).Scan(&n)
+	return n, err
+}
+
+// silent songs get no gain
+func gainOrZero(l float64) float64 {
+	if math.IsInf(l, -1) {
+		return 0
+	}
+	return loudness.Gain(l)
+}
+
+// getAlbumsToAnalyze returns the MP3 songs without computed gain grouped by
+// album, with the other songs of their album as the album gain is computed
+// from all of them.
+func (d *DataBase) getAlbumsToAnalyze() ([][]loudnessSong, int, error) {
+	err := d.DB.Ping()
+	if err != nil {
+		if err := d.ReConnect(); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	rows, err := d.DB.Query(`
+	SELECT id, music_location, album, mb_album_id
+	FROM musics
+	WHERE (r128_track_gain IS NULL AND music_location LIKE '%.mp3')
+		OR (album != 'Unknown' AND (album, mb_album_id) IN (
+			SELECT album, mb_album_id FROM musics WHERE r128_track_gain IS NULL AND music_location LIKE '%.mp3'))
+	ORDER BY album, mb_album_id, disc, track, id`)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var albums [][]loudnessSong
+	var total int
+	for rows.Next() {
+		var s loudnessSong
+		if err := rows.Scan(&s.id, &s.path, &s.album, &s.albumID); err != nil {
+			d.logger.Printf("ERROR: could not scan row: %v\n", err)
+			continue
+		}
+
+		// songs without album are analyzed one by one
+		if n := len(albums); n > 0 && s.album != "Unknown" && albums[n-1][0].album == s.album && albums[n-1][0].albumID == s.albumID {
+			albums[n-1] = append(albums[n-1], s)
+		} else {
+			albums = append(albums, []loudnessSong{s})
+		}
+		total++
+	}
+	return albums, total, rows.Err()
+}
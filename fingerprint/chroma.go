@@ -0,0 +1,177 @@
+package fingerprint
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+const (
+	sampleRate = 11025 // the audio is resampled to 11025 Hz mono
+	frameSize  = 4096  // samples per FFT frame
+	frameHop   = frameSize / 3
+
+	minFreq = 28   // Hz, lowest frequency of the chroma features
+	maxFreq = 3520 // Hz, highest frequency of the chroma features
+
+	chromaBands = 12 // pitch classes
+)
+
+// resampler converts the input to sampleRate by averaging the input samples
+// falling in every output sample, which also filters out the frequencies
+// the lower rate can't represent.
+type resampler struct {
+	step  float64 // input samples per output sample
+	phase float64
+	sum   float64
+	n     int
+}
+
+func newResampler(inputRate int) *resampler {
+	return &resampler{step: float64(inputRate) / sampleRate}
+}
+
+// write adds an input sample and calls emit with the output samples it
+// completes, none or one when downsampling and several when upsampling.
+func (r *resampler) write(x float64, emit func(y float64)) {
+	r.sum += x
+	r.n++
+	r.phase++
+	if r.phase < r.step {
+		return
+	}
+
+	y := r.sum / float64(r.n)
+	for ; r.phase >= r.step; r.phase -= r.step {
+		emit(y)
+	}
+	r.sum, r.n = 0, 0
+}
+
+// chromaExtractor computes the energy of every pitch class of the overlapping
+// FFT frames.
+type chromaExtractor struct {
+	window []float64 // Hamming window
+	notes  []int     // pitch class of every FFT bin, -1 if out of range
+	buf    []float64 // last frameSize samples
+	filled int
+	fft    []complex128
+}
+
+func newChromaExtractor() *chromaExtractor {
+	c := &chromaExtractor{
+		window: make([]float64, frameSize),
+		notes:  make([]int, frameSize/2),
+		buf:    make([]float64, frameSize),
+		fft:    make([]complex128, frameSize),
+	}
+
+	for i := range c.window {
+		c.window[i] = 0.54 - 0.46*math.Cos(2*math.Pi*float64(i)/(frameSize-1))
+	}
+
+	// the pitch classes start at A0 (27.5 Hz), one octave is 12 bands
+	for i := range c.notes {
+		c.notes[i] = -1
+		freq := float64(i) * sampleRate / frameSize
+		if freq < minFreq || freq > maxFreq {
+			continue
+		}
+		octave := math.Log2(freq / 27.5)
+		c.notes[i] = int(chromaBands*(octave-math.Floor(octave))) % chromaBands
+	}
+	return c
+}
+
+// write adds a sample, ok is set when a frame is complete and chroma holds
+// its energy by pitch class.
+func (c *chromaExtractor) write(x float64) (chroma [chromaBands]float64, ok bool) {
+	c.buf[c.filled] = x
+	c.filled++
+	if c.filled < frameSize {
+		return chroma, false
+	}
+
+	for i, x := range c.buf {
+		c.fft[i] = complex(x*c.window[i], 0)
+	}
+	fft(c.fft)
+
+	for i, note := range c.notes {
+		if note >= 0 {
+			v := c.fft[i]
+			chroma[note] += real(v)*real(v) + imag(v)*imag(v)
+		}
+	}
+
+	// keep the overlapping part for the next frame
+	copy(c.buf, c.buf[frameHop:])
+	c.filled = frameSize - frameHop
+	return chroma, true
+}
+
+// fft computes the discrete Fourier transform in place, len(x) must be a
+// power of 2 (iterative radix-2 Cooley-Tukey).
+func fft(x []complex128) {
+	n := len(x)
+
+	// bit reversal permutation
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j |= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+
+	for size := 2; size <= n; size <<= 1 {
+		w := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			wk := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				a, b := x[start+k], wk*x[start+k+size/2]
+				x[start+k], x[start+k+size/2] = a+b, a-b
+				wk *= w
+			}
+		}
+	}
+}
+
+// smoothing of the chroma vectors over time
+var chromaFilter = [...]float64{0.25, 0.75, 1.0, 0.75, 0.25}
+
+// filterChroma smooths the chroma vectors over time and normalizes them,
+// the silent vectors are zeroed.
+func filterChroma(frames [][chromaBands]float64) [][chromaBands]float64 {
+	if len(frames) < len(chromaFilter) {
+		return nil
+	}
+
+	filtered := make([][chromaBands]float64, len(frames)-len(chromaFilter)+1)
+	for i := range filtered {
+		var v [chromaBands]float64
+		for k, coef := range chromaFilter {
+			for b := range v {
+				v[b] += coef * frames[i+k][b]
+			}
+		}
+
+		var norm float64
+		for _, x := range v {
+			norm += x * x
+		}
+		norm = math.Sqrt(norm)
+
+		if norm >= 0.01 {
+			for b := range v {
+				v[b] /= norm
+			}
+		} else {
+			v = [chromaBands]float64{}
+		}
+		filtered[i] = v
+	}
+	return filtered
+}
@@ -0,0 +1,327 @@
+package fingerprint
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/hajimehoshi/go-mp3"
+)
+
+var (
+	ErrNotWAV  = errors.New("invalid WAV file")
+	ErrNotAIFF = errors.New("invalid AIFF file")
+
+	// ErrUnsupportedEncoding is returned for the valid files with compressed
+	// or unusual samples
+	ErrUnsupportedEncoding = errors.New("unsupported encoding")
+)
+
+// number of frames decoded at once
+const decodeFrames = 4096
+
+// Format is the format of a decoded stream.
+type Format struct {
+	SampleRate int
+	Channels   int
+
+	// BitsPerSample is the resolution of the lossless formats, 0 for the
+	// lossy ones.
+	BitsPerSample int
+
+	// Duration is the duration of the whole stream, 0 if unknown.
+	Duration time.Duration
+}
+
+// Bitrate returns the bitrate of the uncompressed samples in kbit/s, 0 for
+// the lossy formats.
+func (f Format) Bitrate() int {
+	return f.SampleRate * f.Channels * f.BitsPerSample / 1000
+}
+
+// FromMP3 decodes the start of the MP3 stream and returns its fingerprint
+// with the format of the stream, the duration is 0 if r is not an io.Seeker.
+func FromMP3(r io.Reader) (Fingerprint, Format, error) {
+	d, err := mp3.NewDecoder(r)
+	if err != nil {
+		return nil, Format{}, err
+	}
+
+	// the decoder always returns 2 channels of 16 bit samples
+	format := Format{SampleRate: d.SampleRate(), Channels: 2}
+	if d.Length() > 0 {
+		format.Duration = time.Duration(d.Length()/4) * time.Second / time.Duration(d.SampleRate())
+	}
+
+	f := NewFingerprinter(d.SampleRate(), 2)
+	if err := decodePCM(f, d, 2, 2, pcmInt); err != nil {
+		return nil, Format{}, err
+	}
+	return f.Fingerprint(), format, nil
+}
+
+// FromWAV decodes the start of a PCM (8, 16, 24 or 32 bit integers or 32
+// bit floats) RIFF/WAVE file and returns its fingerprint with the format of
+// the file, the other encodings are refused with ErrUnsupportedEncoding.
+//
+// Chunk ID            "RIFF"
+// Chunk size          [uint32 little endian]
+// Form type           "WAVE"
+// Chunks              [chunk ID, uint32 little endian size, data padded to even size]
+//
+// fmt chunk
+// Format              [uint16] (1: integer PCM, 3: float, $FFFE: extensible)
+// Channels            [uint16]
+// Sample rate         [uint32]
+// Byte rate           [uint32]
+// Block align         [uint16]
+// Bits per sample     [uint16]
+// Extension size      [uint16] (extensible only)
+// Valid bits          [uint16]
+// Channel mask        [uint32]
+// Sub format          [GUID, starts with the format]
+func FromWAV(r io.ReadSeeker) (Fingerprint, Format, error) {
+	var header [12]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, Format{}, err
+	}
+	if string(header[0:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
+		return nil, Format{}, ErrNotWAV
+	}
+
+	var format, channels, bitsPerSample int
+	var rate int
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(r, chunk[:]); err != nil {
+			return nil, Format{}, fmt.Errorf("%w: no data chunk", ErrNotWAV)
+		}
+		size := int64(binary.LittleEndian.Uint32(chunk[4:8]))
+
+		switch string(chunk[0:4]) {
+		case "fmt ":
+			if size < 16 || size > 1024 {
+				return nil, Format{}, fmt.Errorf("%w: invalid fmt chunk size %d", ErrNotWAV, size)
+			}
+			b := make([]byte, size+size%2)
+			if _, err := io.ReadFull(r, b); err != nil {
+				return nil, Format{}, err
+			}
+			format = int(binary.LittleEndian.Uint16(b[0:2]))
+			channels = int(binary.LittleEndian.Uint16(b[2:4]))
+			rate = int(binary.LittleEndian.Uint32(b[4:8]))
+			bitsPerSample = int(binary.LittleEndian.Uint16(b[14:16]))
+			if format == 0xFFFE && size >= 26 {
+				format = int(binary.LittleEndian.Uint16(b[24:26]))
+			}
+
+		case "data":
+			if channels == 0 || rate == 0 {
+				return nil, Format{}, fmt.Errorf("%w: data chunk before fmt chunk", ErrNotWAV)
+			}
+
+			var kind sampleKind
+			switch {
+			case format == 1 && bitsPerSample >= 8 && bitsPerSample <= 32 && bitsPerSample%8 == 0:
+				kind = pcmInt
+			case format == 3 && bitsPerSample == 32:
+				kind = pcmFloat
+			default:
+				return nil, Format{}, fmt.Errorf("%w: WAV format %d with %d bits", ErrUnsupportedEncoding, format, bitsPerSample)
+			}
+
+			frameSize := int64(channels * bitsPerSample / 8)
+			format := Format{
+				SampleRate:    rate,
+				Channels:      channels,
+				BitsPerSample: bitsPerSample,
+				Duration:      time.Duration(size/frameSize) * time.Second / time.Duration(rate),
+			}
+
+			f := NewFingerprinter(rate, channels)
+			if err := decodePCM(f, io.LimitReader(r, size), channels, bitsPerSample/8, kind); err != nil {
+				return nil, Format{}, err
+			}
+			return f.Fingerprint(), format, nil
+
+		default:
+			if _, err := r.Seek(size+size%2, io.SeekCurrent); err != nil {
+				return nil, Format{}, err
+			}
+		}
+	}
+}
+
+// FromAIFF decodes the start of a PCM (integers or 32 bit floats) AIFF or
+// AIFF-C file and returns its fingerprint with the format of the file, the
+// compressed encodings are refused with ErrUnsupportedEncoding.
+//
+// Chunk ID            "FORM"
+// Chunk size          [uint32 big endian]
+// Form type           "AIFF" or "AIFC"
+// Chunks              [chunk ID, uint32 big endian size, data padded to even size]
+//
+// COMM chunk
+// Channels            [uint16]
+// Sample frames       [uint32]
+// Bits per sample     [uint16]
+// Sample rate         [80 bit IEEE 754 extended]
+// Compression type    [4 bytes] (AIFF-C only: "NONE", "twos", "sowt", "fl32")
+//
+// SSND chunk
+// Offset              [uint32]
+// Block size          [uint32]
+// Samples             [big endian, "sowt" is little endian]
+func FromAIFF(r io.ReadSeeker) (Fingerprint, Format, error) {
+	var header [12]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, Format{}, err
+	}
+	aifc := string(header[8:12]) == "AIFC"
+	if string(header[0:4]) != "FORM" || !aifc && string(header[8:12]) != "AIFF" {
+		return nil, Format{}, ErrNotAIFF
+	}
+
+	var format Format
+	kind := pcmIntBE
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(r, chunk[:]); err != nil {
+			return nil, Format{}, fmt.Errorf("%w: no SSND chunk", ErrNotAIFF)
+		}
+		size := int64(binary.BigEndian.Uint32(chunk[4:8]))
+
+		switch string(chunk[0:4]) {
+		case "COMM":
+			if size < 18 || size > 1024 || aifc && size < 22 {
+				return nil, Format{}, fmt.Errorf("%w: invalid COMM chunk size %d", ErrNotAIFF, size)
+			}
+			b := make([]byte, size+size%2)
+			if _, err := io.ReadFull(r, b); err != nil {
+				return nil, Format{}, err
+			}
+			format.Channels = int(binary.BigEndian.Uint16(b[0:2]))
+			frames := int64(binary.BigEndian.Uint32(b[2:6]))
+			format.BitsPerSample = int(binary.BigEndian.Uint16(b[6:8]))
+			format.SampleRate = int(extendedFloat(b[8:18]))
+			if format.SampleRate > 0 {
+				format.Duration = time.Duration(frames) * time.Second / time.Duration(format.SampleRate)
+			}
+
+			if aifc {
+				switch compression := string(b[18:22]); {
+				case compression == "NONE" || compression == "twos":
+				case compression == "sowt" && format.BitsPerSample > 8:
+					kind = pcmInt
+				case (compression == "fl32" || compression == "FL32") && format.BitsPerSample == 32:
+					kind = pcmFloatBE
+				default:
+					return nil, Format{}, fmt.Errorf("%w: AIFF compression %q", ErrUnsupportedEncoding, compression)
+				}
+			}
+
+		case "SSND":
+			if format.Channels == 0 || format.SampleRate <= 0 {
+				return nil, Format{}, fmt.Errorf("%w: SSND chunk before COMM chunk", ErrNotAIFF)
+			}
+			if format.BitsPerSample < 1 || format.BitsPerSample > 32 {
+				return nil, Format{}, fmt.Errorf("%w: AIFF with %d bit samples", ErrUnsupportedEncoding, format.BitsPerSample)
+			}
+
+			var b [8]byte
+			if _, err := io.ReadFull(r, b[:]); err != nil {
+				return nil, Format{}, err
+			}
+			offset := int64(binary.BigEndian.Uint32(b[0:4]))
+			if _, err := r.Seek(offset, io.SeekCurrent); err != nil {
+				return nil, Format{}, err
+			}
+
+			// the samples are left justified in whole bytes
+			f := NewFingerprinter(format.SampleRate, format.Channels)
+			sampleSize := (format.BitsPerSample + 7) / 8
+			if err := decodePCM(f, io.LimitReader(r, size-8-offset), format.Channels, sampleSize, kind); err != nil {
+				return nil, Format{}, err
+			}
+			return f.Fingerprint(), format, nil
+
+		default:
+			if _, err := r.Seek(size+size%2, io.SeekCurrent); err != nil {
+				return nil, Format{}, err
+			}
+		}
+	}
+}
+
+// extendedFloat converts a 80 bit IEEE 754 extended precision float: sign
+// and 15 bits exponent, 64 bits mantissa with an explicit integer bit.
+func extendedFloat(b []byte) float64 {
+	exponent := int(binary.BigEndian.Uint16(b[0:2]) & 0x7FFF)
+	v := math.Ldexp(float64(binary.BigEndian.Uint64(b[2:10])), exponent-16383-63)
+	if b[0]&0x80 != 0 {
+		return -v
+	}
+	return v
+}
+
+type sampleKind int
+
+const (
+	pcmInt     sampleKind = iota // signed little endian integers, unsigned for 8 bit
+	pcmFloat                     // 32 bit little endian floats
+	pcmIntBE                     // signed big endian integers
+	pcmFloatBE                   // 32 bit big endian floats
+)
+
+// decodePCM writes the interleaved samples of r to f until the end of the
+// stream or MaxDuration.
+func decodePCM(f *Fingerprinter, r io.Reader, channels, sampleSize int, kind sampleKind) error {
+	buf := make([]byte, decodeFrames*channels*sampleSize)
+	samples := make([]float64, 0, decodeFrames*channels)
+	for !f.Full() {
+		n, err := io.ReadFull(r, buf)
+
+		samples = samples[:0]
+		for i := 0; i+sampleSize <= n; i += sampleSize {
+			samples = append(samples, decodeSample(buf[i:i+sampleSize], kind))
+		}
+		f.Write(samples)
+
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeSample returns the sample in the range [-1, 1]
+func decodeSample(b []byte, kind sampleKind) float64 {
+	switch {
+	case kind == pcmFloat:
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+	case kind == pcmFloatBE:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b)))
+	case kind == pcmInt && len(b) == 1:
+		return (float64(b[0]) - 128) / 128
+	}
+
+	// sign extend the integer
+	var v int64
+	if kind == pcmIntBE {
+		for _, x := range b {
+			v = v<<8 | int64(x)
+		}
+	} else {
+		for i := len(b) - 1; i >= 0; i-- {
+			v = v<<8 | int64(b[i])
+		}
+	}
+	shift := 64 - 8*len(b)
+	v = v << shift >> shift
+	return float64(v) / float64(int64(1)<<(8*len(b)-1))
+}
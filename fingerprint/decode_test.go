@@ -0,0 +1,183 @@
+package fingerprint
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+// makeWAV returns a stereo RIFF/WAVE file of the samples with the format
+// tag, the left and right channels are the same.
+func makeWAV(samples []float64, rate, bits, formatTag int) []byte {
+	var data bytes.Buffer
+	for _, x := range samples {
+		for range 2 {
+			switch {
+			case formatTag == 3:
+				binary.Write(&data, binary.LittleEndian, float32(x))
+			case bits == 8:
+				data.WriteByte(byte(int(x*127) + 128))
+			default:
+				v := uint32(int32(x * float64(int64(1)<<(bits-1)-1)))
+				for i := 0; i < bits/8; i++ {
+					data.WriteByte(byte(v >> (8 * i)))
+				}
+			}
+		}
+	}
+
+	var b bytes.Buffer
+	b.WriteString("RIFF")
+	binary.Write(&b, binary.LittleEndian, uint32(4+8+16+8+data.Len()))
+	b.WriteString("WAVE")
+
+	// an unknown chunk is skipped
+	b.WriteString("junk")
+	binary.Write(&b, binary.LittleEndian, uint32(3))
+	b.Write([]byte{1, 2, 3, 0})
+
+	b.WriteString("fmt ")
+	binary.Write(&b, binary.LittleEndian, []uint32{16})
+	binary.Write(&b, binary.LittleEndian, []uint16{uint16(formatTag), 2})
+	binary.Write(&b, binary.LittleEndian, []uint32{uint32(rate), uint32(rate * 2 * bits / 8)})
+	binary.Write(&b, binary.LittleEndian, []uint16{uint16(2 * bits / 8), uint16(bits)})
+
+	b.WriteString("data")
+	binary.Write(&b, binary.LittleEndian, uint32(data.Len()))
+	b.Write(data.Bytes())
+	return b.Bytes()
+}
+
+func TestFromWAV(t *testing.T) {
+	const rate = 11025
+	song := melody(1, rate, 20)
+	want := fingerprintOf(song, rate)
+
+	tests := []struct {
+		name      string
+		bits      int
+		formatTag int
+	}{
+		{"8 bit", 8, 1},
+		{"16 bit", 16, 1},
+		{"24 bit", 24, 1},
+		{"32 bit", 32, 1},
+		{"float", 32, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fp, format, err := FromWAV(bytes.NewReader(makeWAV(song, rate, tt.bits, tt.formatTag)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			wantFormat := Format{SampleRate: rate, Channels: 2, BitsPerSample: tt.bits, Duration: 20 * time.Second}
+			if format != wantFormat {
+				t.Errorf("format = %+v, want %+v", format, wantFormat)
+			}
+			if got := format.Bitrate(); got != rate*2*tt.bits/1000 {
+				t.Errorf("Bitrate() = %d, want %d", got, rate*2*tt.bits/1000)
+			}
+			if got := Similarity(fp, want); got < 0.95 {
+				t.Errorf("Similarity with the samples = %v", got)
+			}
+		})
+	}
+}
+
+func TestFromWAVErrors(t *testing.T) {
+	song := melody(1, 8000, 1)
+
+	// 2 is Microsoft ADPCM
+	_, _, err := FromWAV(bytes.NewReader(makeWAV(song, 8000, 4, 2)))
+	if !errors.Is(err, ErrUnsupportedEncoding) {
+		t.Errorf("FromWAV(ADPCM) error = %v, want %v", err, ErrUnsupportedEncoding)
+	}
+
+	b := makeWAV(song, 8000, 16, 1)
+	copy(b[8:12], "AVI ")
+	if _, _, err := FromWAV(bytes.NewReader(b)); !errors.Is(err, ErrNotWAV) {
+		t.Errorf("FromWAV(AVI) error = %v, want %v", err, ErrNotWAV)
+	}
+
+	// the data chunk is missing
+	b = makeWAV(song, 8000, 16, 1)
+	if _, _, err := FromWAV(bytes.NewReader(b[:12+12+24])); !errors.Is(err, ErrNotWAV) {
+		t.Errorf("FromWAV(no data) error = %v, want %v", err, ErrNotWAV)
+	}
+}
+
+// makeAIFF returns a mono AIFF-C file of the samples with the compression
+// type, 16 bit integers or 32 bit floats for "fl32".
+func makeAIFF(samples []float64, rate int, compression string) []byte {
+	order := binary.ByteOrder(binary.BigEndian)
+	if compression == "sowt" {
+		order = binary.LittleEndian
+	}
+
+	var data bytes.Buffer
+	for _, x := range samples {
+		if compression == "fl32" {
+			binary.Write(&data, order, float32(x))
+		} else {
+			binary.Write(&data, order, int16(x*math.MaxInt16))
+		}
+	}
+	bits := data.Len() / len(samples) * 8
+
+	// 80 bit extended sample rate
+	exponent, mantissa := 16383+63, uint64(rate)
+	for mantissa&(1<<63) == 0 {
+		mantissa <<= 1
+		exponent--
+	}
+
+	var b bytes.Buffer
+	b.WriteString("FORM")
+	binary.Write(&b, binary.BigEndian, uint32(4+8+24+8+8+data.Len()))
+	b.WriteString("AIFC")
+
+	b.WriteString("COMM")
+	binary.Write(&b, binary.BigEndian, uint32(24))
+	binary.Write(&b, binary.BigEndian, uint16(1))
+	binary.Write(&b, binary.BigEndian, uint32(len(samples)))
+	binary.Write(&b, binary.BigEndian, []uint16{uint16(bits), uint16(exponent)})
+	binary.Write(&b, binary.BigEndian, mantissa)
+	b.WriteString(compression)
+	b.Write([]byte{0, 0}) // empty compression name, padded
+
+	b.WriteString("SSND")
+	binary.Write(&b, binary.BigEndian, uint32(8+data.Len()))
+	binary.Write(&b, binary.BigEndian, []uint32{0, 0})
+	b.Write(data.Bytes())
+	return b.Bytes()
+}
+
+func TestFromAIFF(t *testing.T) {
+	const rate = 22050
+	song := melody(1, rate, 15)
+	want := fingerprintOf(song, rate)
+
+	for _, compression := range []string{"NONE", "sowt", "fl32"} {
+		t.Run(compression, func(t *testing.T) {
+			fp, format, err := FromAIFF(bytes.NewReader(makeAIFF(song, rate, compression)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if format.SampleRate != rate || format.Channels != 1 || format.Duration != 15*time.Second {
+				t.Errorf("format = %+v", format)
+			}
+			if got := Similarity(fp, want); got < 0.95 {
+				t.Errorf("Similarity with the samples = %v", got)
+			}
+		})
+	}
+
+	_, _, err := FromAIFF(bytes.NewReader(makeAIFF(song[:100], rate, "ima4")))
+	if !errors.Is(err, ErrUnsupportedEncoding) {
+		t.Errorf("FromAIFF(ima4) error = %v, want %v", err, ErrUnsupportedEncoding)
+	}
+}
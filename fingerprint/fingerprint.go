@@ -0,0 +1,249 @@
+// Package fingerprint computes acoustic fingerprints in the style of
+// Chromaprint: the audio is resampled to 11025 Hz, the energy of the 12
+// pitch classes (chroma) is computed for overlapping FFT frames and every
+// frame gives a 32 bit item from 16 classifiers applied to the chroma image.
+// The fingerprints of the same recording encoded differently (bitrate,
+// codec) are close, see Similarity.
+package fingerprint
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/bits"
+	"time"
+)
+
+// MaxDuration is the length of audio fingerprinted from the start of the song
+const MaxDuration = 120 * time.Second
+
+// Fingerprint is a 32 bit item every frameHop samples (about 124ms)
+type Fingerprint []uint32
+
+// Fingerprinter computes the fingerprint of an audio stream.
+type Fingerprinter struct {
+	channels  int
+	resampler *resampler
+	chroma    *chromaExtractor
+	frames    [][chromaBands]float64
+	samples   int // samples at sampleRate
+}
+
+// NewFingerprinter returns a fingerprinter for interleaved samples with the
+// given sample rate and number of channels, the channels are mixed down.
+func NewFingerprinter(sampleRate, channels int) *Fingerprinter {
+	return &Fingerprinter{
+		channels:  max(channels, 1),
+		resampler: newResampler(sampleRate),
+		chroma:    newChromaExtractor(),
+	}
+}
+
+// Write adds interleaved samples in the range [-1, 1], the samples after
+// MaxDuration are ignored.
+func (f *Fingerprinter) Write(samples []float64) {
+	for i := 0; i+f.channels <= len(samples) && !f.Full(); i += f.channels {
+		var x float64
+		for c := 0; c < f.channels; c++ {
+			x += samples[i+c]
+		}
+
+		f.resampler.write(x/float64(f.channels), func(y float64) {
+			f.samples++
+			if chroma, ok := f.chroma.write(y); ok {
+				f.frames = append(f.frames, chroma)
+			}
+		})
+	}
+}
+
+// Full reports whether MaxDuration of audio was written, the decoding can stop.
+func (f *Fingerprinter) Full() bool {
+	return f.samples >= int(MaxDuration.Seconds())*sampleRate
+}
+
+// Fingerprint returns the fingerprint of the audio written so far, it is
+// empty for less than about 3 seconds of audio.
+func (f *Fingerprinter) Fingerprint() Fingerprint {
+	image := newIntegralImage(filterChroma(f.frames))
+
+	var fp Fingerprint
+	for x := 0; x+classifiersWidth <= image.rows; x++ {
+		var item uint32
+		for _, c := range classifiers {
+			item = item<<2 | grayCode[c.quantize(c.filter.apply(image, x))]
+		}
+		fp = append(fp, item)
+	}
+	return fp
+}
+
+// filter compares areas of the chroma image starting at the frame x:
+// width frames and height pitch classes from the class y.
+type filter struct {
+	kind          int
+	y             int
+	height, width int
+}
+
+type classifier struct {
+	filter     filter
+	thresholds [3]float64
+}
+
+// quantize returns 0 to 3 from the filter value
+func (c classifier) quantize(v float64) int {
+	for i, t := range c.thresholds {
+		if v < t {
+			return i
+		}
+	}
+	return 3
+}
+
+// the 2 bit values are gray coded, close values differ by one bit
+var grayCode = [4]uint32{0, 1, 3, 2}
+
+// classifiers of Chromaprint's default algorithm
+var classifiers = [16]classifier{
+	{filter{0, 4, 3, 15}, [3]float64{1.98215, 2.35817, 2.63523}},
+	{filter{4, 4, 6, 15}, [3]float64{-1.03809, -0.651211, -0.282167}},
+	{filter{1, 0, 4, 16}, [3]float64{-0.298702, 0.119262, 0.558497}},
+	{filter{3, 8, 2, 12}, [3]float64{-0.105439, 0.0153946, 0.135898}},
+	{filter{3, 4, 4, 8}, [3]float64{-0.142891, 0.0258736, 0.200632}},
+	{filter{4, 0, 3, 5}, [3]float64{-0.826319, -0.590612, -0.368214}},
+	{filter{1, 2, 2, 9}, [3]float64{-0.557409, -0.233035, 0.0534525}},
+	{filter{2, 7, 3, 4}, [3]float64{-0.0646826, 0.00620476, 0.0784847}},
+	{filter{2, 6, 2, 16}, [3]float64{-0.192387, -0.029699, 0.215855}},
+	{filter{2, 1, 3, 2}, [3]float64{-0.0397818, -0.00568076, 0.0292026}},
+	{filter{5, 10, 1, 15}, [3]float64{-0.53823, -0.369934, -0.190235}},
+	{filter{3, 6, 2, 10}, [3]float64{-0.124877, 0.0296483, 0.139239}},
+	{filter{2, 1, 1, 14}, [3]float64{-0.101475, 0.0225617, 0.231971}},
+	{filter{3, 5, 6, 4}, [3]float64{-0.0799915, -0.00729616, 0.063262}},
+	{filter{1, 9, 2, 12}, [3]float64{-0.272556, 0.019424, 0.302559}},
+	{filter{3, 4, 2, 14}, [3]float64{-0.164292, -0.0321188, 0.0846339}},
+}
+
+// frames needed by the widest classifier
+const classifiersWidth = 16
+
+// apply returns log(1+a) - log(1+b) for the areas a and b compared by the
+// filter kind:
+// 0: the whole area
+// 1: the upper and lower halves of the pitch classes
+// 2: the second and first halves of the frames
+// 3: the quadrants, as a checkerboard
+// 4: the middle third of the pitch classes and the other thirds
+// 5: the middle third of the frames and the other thirds
+func (f filter) apply(image *integralImage, x int) float64 {
+	y, w, h := f.y, f.width, f.height
+	area := func(x0, y0, x1, y1 int) float64 { return image.area(x+x0, y+y0, x+x1, y+y1) }
+
+	var a, b float64
+	switch f.kind {
+	case 0:
+		a = area(0, 0, w, h)
+	case 1:
+		a = area(0, h/2, w, h)
+		b = area(0, 0, w, h/2)
+	case 2:
+		a = area(w/2, 0, w, h)
+		b = area(0, 0, w/2, h)
+	case 3:
+		a = area(0, h/2, w/2, h) + area(w/2, 0, w, h/2)
+		b = area(0, 0, w/2, h/2) + area(w/2, h/2, w, h)
+	case 4:
+		a = area(0, h/3, w, 2*h/3)
+		b = area(0, 0, w, h/3) + area(0, 2*h/3, w, h)
+	case 5:
+		a = area(w/3, 0, 2*w/3, h)
+		b = area(0, 0, w/3, h) + area(2*w/3, 0, w, h)
+	}
+	return math.Log1p(a) - math.Log1p(b)
+}
+
+// integralImage holds the sums of the chroma image, sums[x][y] is the sum
+// of the values of the frames before x and the pitch classes before y.
+type integralImage struct {
+	rows int
+	sums [][chromaBands + 1]float64
+}
+
+func newIntegralImage(frames [][chromaBands]float64) *integralImage {
+	image := &integralImage{
+		rows: len(frames),
+		sums: make([][chromaBands + 1]float64, len(frames)+1),
+	}
+	for x, v := range frames {
+		var row float64
+		for y := range v {
+			row += v[y]
+			image.sums[x+1][y+1] = image.sums[x][y+1] + row
+		}
+	}
+	return image
+}
+
+// area returns the sum of the frames x0 to x1 (excluded) and the pitch
+// classes y0 to y1 (excluded), the pitch classes wrap around.
+func (image *integralImage) area(x0, y0, x1, y1 int) float64 {
+	if y1 > chromaBands {
+		return image.area(x0, y0, x1, chromaBands) + image.area(x0, 0, x1, y1-chromaBands)
+	}
+	s := image.sums
+	return s[x1][y1] - s[x0][y1] - s[x1][y0] + s[x0][y0]
+}
+
+// Bytes returns the fingerprint as little endian uint32
+func (fp Fingerprint) Bytes() []byte {
+	b := make([]byte, 4*len(fp))
+	for i, item := range fp {
+		binary.LittleEndian.PutUint32(b[4*i:], item)
+	}
+	return b
+}
+
+// Parse reads a fingerprint returned by Bytes
+func Parse(b []byte) (Fingerprint, error) {
+	if len(b)%4 != 0 {
+		return nil, errors.New("invalid fingerprint size")
+	}
+
+	fp := make(Fingerprint, len(b)/4)
+	for i := range fp {
+		fp[i] = binary.LittleEndian.Uint32(b[4*i:])
+	}
+	return fp, nil
+}
+
+// the fingerprints are aligned up to 10 seconds apart
+const maxOffset = 80
+
+// Similarity returns a score between 0 (unrelated audio) and 1 (same audio)
+// from the share of bits which are equal in the two fingerprints, once
+// aligned. The fingerprints shorter than 10 seconds are not compared.
+func Similarity(a, b Fingerprint) float64 {
+	minOverlap := min(len(a), len(b)) / 2
+	if minOverlap < maxOffset/2 {
+		return 0
+	}
+
+	var best float64
+	for offset := -maxOffset; offset <= maxOffset; offset++ {
+		// b[i] is aligned with a[i+offset]
+		start, end := max(0, -offset), min(len(b), len(a)-offset)
+		if end-start < minOverlap {
+			continue
+		}
+
+		var diff int
+		for i := start; i < end; i++ {
+			diff += bits.OnesCount32(a[i+offset] ^ b[i])
+		}
+
+		// unrelated fingerprints have about half of their bits equal
+		equal := 1 - float64(diff)/float64(32*(end-start))
+		best = max(best, 2*equal-1)
+	}
+	return best
+}
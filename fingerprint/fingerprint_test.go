@@ -0,0 +1,126 @@
+package fingerprint
+
+import (
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"testing"
+)
+
+// the default threshold of the configuration
+const threshold = 0.6
+
+func TestFFT(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, n := range []int{1, 2, 8, 64} {
+		x := make([]complex128, n)
+		for i := range x {
+			x[i] = complex(rng.Float64()*2-1, rng.Float64()*2-1)
+		}
+
+		// X[k] = sum x[i] e^(-2 pi i k / n)
+		want := make([]complex128, n)
+		for k := range want {
+			for i, v := range x {
+				want[k] += v * cmplx.Exp(complex(0, -2*math.Pi*float64(i*k)/float64(n)))
+			}
+		}
+
+		got := append([]complex128(nil), x...)
+		fft(got)
+		for k := range got {
+			if cmplx.Abs(got[k]-want[k]) > 1e-9 {
+				t.Errorf("n = %d: X[%d] = %v, want %v", n, k, got[k], want[k])
+			}
+		}
+	}
+}
+
+// melody returns seconds of mono audio at rate: a note of a random pitch
+// every quarter of a second.
+func melody(seed int64, rate int, seconds float64) []float64 {
+	rng := rand.New(rand.NewSource(seed))
+	samples := make([]float64, int(seconds*float64(rate)))
+	var freq, phase float64
+	for i := range samples {
+		if i%(rate/4) == 0 {
+			freq = 110 * math.Pow(2, float64(rng.Intn(36))/12)
+		}
+		phase += 2 * math.Pi * freq / float64(rate)
+		samples[i] = 0.5 * math.Sin(phase)
+	}
+	return samples
+}
+
+func fingerprintOf(samples []float64, rate int) Fingerprint {
+	f := NewFingerprinter(rate, 1)
+	f.Write(samples)
+	return f.Fingerprint()
+}
+
+func TestSimilarity(t *testing.T) {
+	const rate = 22050
+	song := melody(1, rate, 30)
+	fp := fingerprintOf(song, rate)
+	if len(fp) == 0 {
+		t.Fatal("empty fingerprint")
+	}
+
+	if got := Similarity(fp, fp); got != 1 {
+		t.Errorf("Similarity(fp, fp) = %v, want 1", got)
+	}
+
+	// the copy starts 1.3 seconds later, between two frames
+	shifted := fingerprintOf(song[rate*13/10:], rate)
+	if got := Similarity(fp, shifted); got < threshold {
+		t.Errorf("Similarity of a shifted copy = %v, want at least %v", got, threshold)
+	}
+
+	// the same melody resampled and quieter
+	quiet := make([]float64, 0, len(song)/2)
+	for i := 0; i+1 < len(song); i += 2 {
+		quiet = append(quiet, (song[i]+song[i+1])/4)
+	}
+	if got := Similarity(fp, fingerprintOf(quiet, rate/2)); got < threshold {
+		t.Errorf("Similarity of a resampled copy = %v, want at least %v", got, threshold)
+	}
+
+	rng := rand.New(rand.NewSource(2))
+	noise := make([]float64, len(song))
+	for i := range noise {
+		noise[i] = rng.Float64() - 0.5
+	}
+	for name, other := range map[string][]float64{"noise": noise, "other melody": melody(3, rate, 30)} {
+		if got := Similarity(fp, fingerprintOf(other, rate)); got >= threshold {
+			t.Errorf("Similarity with %s = %v, want less than %v", name, got, threshold)
+		}
+	}
+}
+
+func TestSimilarityShort(t *testing.T) {
+	const rate = 11025
+	fp := fingerprintOf(melody(1, rate, 5), rate)
+	if got := Similarity(fp, fp); got != 0 {
+		t.Errorf("Similarity of 5 seconds = %v, want 0", got)
+	}
+}
+
+func TestParse(t *testing.T) {
+	fp := Fingerprint{0, 1, 0xDEADBEEF, math.MaxUint32}
+	got, err := Parse(fp.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(fp) {
+		t.Fatalf("Parse(Bytes()) = %v, want %v", got, fp)
+	}
+	for i := range fp {
+		if got[i] != fp[i] {
+			t.Errorf("Parse(Bytes()) = %v, want %v", got, fp)
+		}
+	}
+
+	if _, err := Parse([]byte{1, 2, 3}); err == nil {
+		t.Error("Parse of 3 bytes returned no error")
+	}
+}
@@ -0,0 +1,535 @@
+package fingerprint
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+var ErrNotFLAC = errors.New("invalid FLAC file")
+
+// FromFLAC decodes the start of a native FLAC stream and returns its
+// fingerprint with the format of the stream, a leading ID3v2 tag is skipped.
+// (see https://www.rfc-editor.org/rfc/rfc9639)
+func FromFLAC(r io.Reader) (Fingerprint, Format, error) {
+	d, err := newFLACDecoder(r)
+	if err != nil {
+		return nil, Format{}, err
+	}
+
+	f := NewFingerprinter(d.format.SampleRate, d.format.Channels)
+	samples := make([]float64, 0, 4096*d.format.Channels)
+	for !f.Full() {
+		block, err := d.next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, Format{}, err
+		}
+
+		samples = samples[:0]
+		for i := range block[0] {
+			for _, channel := range block {
+				samples = append(samples, float64(channel[i])/d.scale)
+			}
+		}
+		f.Write(samples)
+	}
+	return f.Fingerprint(), d.format, nil
+}
+
+// flacDecoder decodes the frames of a FLAC stream, one block of samples
+// per channel at a time.
+type flacDecoder struct {
+	br     *bitReader
+	format Format
+	md5    [16]byte // MD5 of the samples given by STREAMINFO, zero if unknown
+	scale  float64  // full scale of the samples
+	block  [][]int64
+}
+
+// newFLACDecoder reads the metadata blocks of the stream up to the first
+// frame, the stream information is the first block.
+// Metadata block header  [1 bit last block flag] [7 bits type] [uint24 length]
+//
+// STREAMINFO
+// Block sizes          [uint16 minimum] [uint16 maximum]
+// Frame sizes          [uint24 minimum] [uint24 maximum]
+// Sample rate          [20 bits]
+// Channels             [3 bits] (channels - 1)
+// Bits per sample      [5 bits] (bits - 1)
+// Samples              [36 bits] (0 if unknown)
+// MD5                  [16 bytes]
+func newFLACDecoder(r io.Reader) (*flacDecoder, error) {
+	br := bufio.NewReader(r)
+	if err := skipID3v2(br); err != nil {
+		return nil, err
+	}
+
+	var magic [4]byte
+	if _, err := io.ReadFull(br, magic[:]); err != nil || string(magic[:]) != "fLaC" {
+		return nil, ErrNotFLAC
+	}
+
+	d := &flacDecoder{br: &bitReader{r: br}}
+	for first := true; ; first = false {
+		var header [4]byte
+		if _, err := io.ReadFull(br, header[:]); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrNotFLAC, err)
+		}
+		last, blockType := header[0]&0x80 != 0, header[0]&0x7F
+		size := int(header[1])<<16 | int(header[2])<<8 | int(header[3])
+
+		switch {
+		case first && (blockType != 0 || size < 34):
+			return nil, fmt.Errorf("%w: the first block is not STREAMINFO", ErrNotFLAC)
+		case first:
+			b := make([]byte, size)
+			if _, err := io.ReadFull(br, b); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrNotFLAC, err)
+			}
+			v := binary.BigEndian.Uint64(b[10:18])
+			d.format = Format{
+				SampleRate:    int(v >> 44),
+				Channels:      int(v>>41&0x07) + 1,
+				BitsPerSample: int(v>>36&0x1F) + 1,
+			}
+			if samples := v & (1<<36 - 1); d.format.SampleRate > 0 {
+				d.format.Duration = time.Duration(samples) * time.Second / time.Duration(d.format.SampleRate)
+			}
+			copy(d.md5[:], b[18:34])
+		default:
+			if _, err := br.Discard(size); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrNotFLAC, err)
+			}
+		}
+
+		if last {
+			break
+		}
+	}
+
+	if d.format.SampleRate == 0 {
+		return nil, fmt.Errorf("%w: invalid sample rate", ErrNotFLAC)
+	}
+	d.scale = float64(int64(1) << (d.format.BitsPerSample - 1))
+	return d, nil
+}
+
+// skipID3v2 skips the ID3v2 tag at the start of r if any, the tag is 10 bytes
+// long (20 with a footer) plus its syncsafe size.
+func skipID3v2(r *bufio.Reader) error {
+	b, err := r.Peek(10)
+	if err != nil || string(b[0:3]) != "ID3" {
+		return nil
+	}
+
+	size := int(b[6]&0x7F)<<21 | int(b[7]&0x7F)<<14 | int(b[8]&0x7F)<<7 | int(b[9]&0x7F)
+	size += 10
+	if b[5]&0x10 != 0 {
+		size += 10
+	}
+	_, err = r.Discard(size)
+	return err
+}
+
+// block sizes of the block size codes 0 to 15, 0 for the reserved and the
+// explicit sizes (6 and 7)
+var flacBlockSizes = [16]int{0, 192, 576, 1152, 2304, 4608, 0, 0, 256, 512, 1024, 2048, 4096, 8192, 16384, 32768}
+
+// bits per sample of the sample size codes, 0 is the size of STREAMINFO and
+// 3 is reserved
+var flacSampleSizes = [8]int{0, 8, 12, -1, 16, 20, 24, 32}
+
+// next decodes a frame, it returns a block of samples for every channel
+// which is valid until the next call, io.EOF at the end of the stream.
+// Sync code           [14 bits] 0b11111111111110
+// Reserved            [1 bit]
+// Blocking strategy   [1 bit]
+// Block size          [4 bits]
+// Sample rate         [4 bits] (the rate of STREAMINFO is used)
+// Channels            [4 bits] (0-7 independent, 8 left/side, 9 side/right, 10 mid/side)
+// Sample size         [3 bits]
+// Reserved            [1 bit]
+// Coded number        [UTF-8 like, 1 to 7 bytes]
+// Block size          [uint8 or uint16] (block size codes 6 and 7)
+// Sample rate         [uint8 or uint16] (sample rate codes 12 to 14)
+// CRC-8               [uint8]
+// Subframes           [one per channel]
+// Padding             [to byte alignment]
+// CRC-16              [uint16]
+func (d *flacDecoder) next() ([][]int64, error) {
+	br := d.br
+	sync, err := br.read(16)
+	if err == io.ErrUnexpectedEOF && br.n == 0 {
+		return nil, io.EOF
+	} else if err != nil {
+		return nil, err
+	}
+	if sync>>2 != 0x3FFE {
+		return nil, fmt.Errorf("%w: lost frame sync", ErrNotFLAC)
+	}
+
+	h, err := br.read(16)
+	if err != nil {
+		return nil, err
+	}
+	blockSizeCode, rateCode := int(h>>12), int(h>>8&0x0F)
+	channelCode, sizeCode := int(h>>4&0x0F), int(h>>1&0x07)
+
+	// the coded frame or sample number is skipped
+	first, err := br.read(8)
+	if err != nil {
+		return nil, err
+	}
+	for b := first << 1; b&0x80 != 0; b <<= 1 {
+		if _, err := br.read(8); err != nil {
+			return nil, err
+		}
+	}
+
+	blockSize := flacBlockSizes[blockSizeCode]
+	switch blockSizeCode {
+	case 6, 7:
+		n, err := br.read(8 * (blockSizeCode - 5))
+		if err != nil {
+			return nil, err
+		}
+		blockSize = int(n) + 1
+	}
+	if blockSize == 0 {
+		return nil, fmt.Errorf("%w: reserved block size", ErrNotFLAC)
+	}
+
+	switch rateCode {
+	case 12, 13, 14:
+		if _, err := br.read(8 + 8*min(rateCode-12, 1)); err != nil {
+			return nil, err
+		}
+	case 15:
+		return nil, fmt.Errorf("%w: invalid sample rate", ErrNotFLAC)
+	}
+
+	bps := d.format.BitsPerSample
+	if sizeCode != 0 {
+		bps = flacSampleSizes[sizeCode]
+	}
+	if bps <= 0 {
+		return nil, fmt.Errorf("%w: reserved sample size", ErrNotFLAC)
+	}
+
+	channels := channelCode + 1
+	if channelCode >= 8 {
+		channels = 2
+	}
+	if channelCode > 10 || channels != d.format.Channels {
+		return nil, fmt.Errorf("%w: invalid channel assignment %d", ErrNotFLAC, channelCode)
+	}
+
+	// CRC-8 of the header
+	if _, err := br.read(8); err != nil {
+		return nil, err
+	}
+
+	if len(d.block) != channels || cap(d.block[0]) < blockSize {
+		d.block = make([][]int64, channels)
+		for c := range d.block {
+			d.block[c] = make([]int64, blockSize)
+		}
+	}
+	for c := range d.block {
+		d.block[c] = d.block[c][:blockSize]
+
+		// the side channel has one more bit
+		size := bps
+		if (channelCode == 8 || channelCode == 10) && c == 1 || channelCode == 9 && c == 0 {
+			size++
+		}
+		if err := d.readSubframe(d.block[c], size); err != nil {
+			return nil, err
+		}
+	}
+
+	left, right := d.block[0], d.block[len(d.block)-1]
+	switch channelCode {
+	case 8: // left/side
+		for i := range right {
+			right[i] = left[i] - right[i]
+		}
+	case 9: // side/right
+		for i := range left {
+			left[i] += right[i]
+		}
+	case 10: // mid/side
+		for i := range left {
+			mid := left[i]<<1 | right[i]&1
+			left[i], right[i] = (mid+right[i])>>1, (mid-right[i])>>1
+		}
+	}
+
+	// CRC-16 of the frame
+	br.align()
+	if _, err := br.read(16); err != nil {
+		return nil, err
+	}
+	return d.block, nil
+}
+
+// fixed predictors coefficients by order
+var flacFixedCoefs = [5][]int64{{}, {1}, {2, -1}, {3, -3, 1}, {4, -6, 4, -1}}
+
+// readSubframe decodes the samples of a channel.
+// Padding             [1 bit]
+// Type                [6 bits] (0 constant, 1 verbatim, 8-12 fixed, 32-63 LPC)
+// Wasted bits flag    [1 bit] followed by the unary coded wasted bits - 1
+func (d *flacDecoder) readSubframe(samples []int64, bps int) error {
+	br := d.br
+	h, err := br.read(8)
+	if err != nil {
+		return err
+	}
+	kind := int(h >> 1 & 0x3F)
+
+	wasted := 0
+	if h&1 != 0 {
+		n, err := br.unary()
+		if err != nil {
+			return err
+		}
+		wasted = n + 1
+		bps -= wasted
+	}
+	if bps <= 0 {
+		return fmt.Errorf("%w: invalid wasted bits", ErrNotFLAC)
+	}
+
+	switch {
+	case kind == 0: // constant
+		v, err := br.signed(bps)
+		if err != nil {
+			return err
+		}
+		for i := range samples {
+			samples[i] = v
+		}
+
+	case kind == 1: // verbatim
+		for i := range samples {
+			if samples[i], err = br.signed(bps); err != nil {
+				return err
+			}
+		}
+
+	case kind >= 8 && kind <= 12: // fixed predictor
+		order := kind - 8
+		if err := d.readWarmUp(samples, bps, order); err != nil {
+			return err
+		}
+		if err := d.readResidual(samples, order, flacFixedCoefs[order], 0); err != nil {
+			return err
+		}
+
+	case kind >= 32: // linear predictor
+		// Warm up samples     [order, bits per sample signed]
+		// Precision           [4 bits] (bits - 1)
+		// Shift               [5 bits signed]
+		// Coefficients        [order, precision bits signed]
+		order := kind - 31
+		if err := d.readWarmUp(samples, bps, order); err != nil {
+			return err
+		}
+
+		precision, err := br.read(4)
+		if err != nil {
+			return err
+		}
+		if precision == 0x0F {
+			return fmt.Errorf("%w: invalid LPC precision", ErrNotFLAC)
+		}
+		shift, err := br.signed(5)
+		if err != nil {
+			return err
+		}
+		if shift < 0 {
+			return fmt.Errorf("%w: negative LPC shift", ErrNotFLAC)
+		}
+
+		coefs := make([]int64, order)
+		for i := range coefs {
+			if coefs[i], err = br.signed(int(precision) + 1); err != nil {
+				return err
+			}
+		}
+		if err := d.readResidual(samples, order, coefs, int(shift)); err != nil {
+			return err
+		}
+
+	default:
+		return fmt.Errorf("%w: reserved subframe type %d", ErrNotFLAC, kind)
+	}
+
+	if wasted > 0 {
+		for i := range samples {
+			samples[i] <<= wasted
+		}
+	}
+	return nil
+}
+
+// readWarmUp reads the first order samples of a predicted subframe
+func (d *flacDecoder) readWarmUp(samples []int64, bps, order int) error {
+	if order > len(samples) {
+		return fmt.Errorf("%w: predictor order %d for %d samples", ErrNotFLAC, order, len(samples))
+	}
+
+	var err error
+	for i := 0; i < order; i++ {
+		if samples[i], err = d.br.signed(bps); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readResidual reads the Rice coded residual of the samples following the
+// warm up samples and applies the predictor: the sample i is the residual
+// plus the sum of the coefficients times the previous samples, shifted right
+// by shift.
+// Coding method       [2 bits] (0: 4 bit parameters, 1: 5 bit parameters)
+// Partition order     [4 bits]
+// Partitions          [parameter, escape: 5 bits size and unencoded values]
+func (d *flacDecoder) readResidual(samples []int64, order int, coefs []int64, shift int) error {
+	br := d.br
+	method, err := br.read(2)
+	if err != nil {
+		return err
+	}
+	if method > 1 {
+		return fmt.Errorf("%w: reserved residual coding method", ErrNotFLAC)
+	}
+	paramBits := 4 + int(method)
+	escape := uint64(1)<<paramBits - 1
+
+	partitionOrder, err := br.read(4)
+	if err != nil {
+		return err
+	}
+	partitions := 1 << partitionOrder
+	partitionSize := len(samples) >> partitionOrder
+	if partitionSize<<partitionOrder != len(samples) || partitionSize < order {
+		return fmt.Errorf("%w: invalid partition order %d", ErrNotFLAC, partitionOrder)
+	}
+
+	i := order
+	for p := 0; p < partitions; p++ {
+		end := (p + 1) * partitionSize
+		param, err := br.read(paramBits)
+		if err != nil {
+			return err
+		}
+
+		if param == escape {
+			size, err := br.read(5)
+			if err != nil {
+				return err
+			}
+			for ; i < end; i++ {
+				v := int64(0)
+				if size > 0 {
+					if v, err = br.signed(int(size)); err != nil {
+						return err
+					}
+				}
+				samples[i] = v
+			}
+			continue
+		}
+
+		for ; i < end; i++ {
+			q, err := br.unary()
+			if err != nil {
+				return err
+			}
+			low, err := br.read(int(param))
+			if err != nil {
+				return err
+			}
+			u := uint64(q)<<param | low
+			samples[i] = int64(u>>1) ^ -int64(u&1)
+		}
+	}
+
+	// the residual is replaced by the predicted samples
+	for i := order; i < len(samples); i++ {
+		var sum int64
+		for j, c := range coefs {
+			sum += c * samples[i-1-j]
+		}
+		samples[i] += sum >> shift
+	}
+	return nil
+}
+
+// bitReader reads big endian bit fields.
+type bitReader struct {
+	r     io.ByteReader
+	cache uint64 // the n low bits are not read yet
+	n     int
+}
+
+// read returns the next n (up to 56) bits, io.ErrUnexpectedEOF at the end
+// of the stream.
+func (br *bitReader) read(n int) (uint64, error) {
+	for br.n < n {
+		b, err := br.r.ReadByte()
+		if err == io.EOF {
+			return 0, io.ErrUnexpectedEOF
+		} else if err != nil {
+			return 0, err
+		}
+		br.cache = br.cache<<8 | uint64(b)
+		br.n += 8
+	}
+
+	br.n -= n
+	v := br.cache >> br.n & (1<<n - 1)
+	br.cache &= 1<<br.n - 1
+	return v, nil
+}
+
+// signed returns the next n bits as a two's complement integer
+func (br *bitReader) signed(n int) (int64, error) {
+	v, err := br.read(n)
+	if err != nil {
+		return 0, err
+	}
+	shift := 64 - n
+	return int64(v<<shift) >> shift, nil
+}
+
+// unary returns the number of 0 bits before the next 1 bit
+func (br *bitReader) unary() (int, error) {
+	n := 0
+	for {
+		bit, err := br.read(1)
+		if err != nil {
+			return 0, err
+		}
+		if bit == 1 {
+			return n, nil
+		}
+		n++
+		if n > 1<<20 {
+			return 0, fmt.Errorf("%w: invalid unary code", ErrNotFLAC)
+		}
+	}
+}
+
+// align skips the bits up to the next byte
+func (br *bitReader) align() {
+	br.n -= br.n % 8
+	br.cache &= 1<<br.n - 1
+}
@@ -0,0 +1,61 @@
+package fingerprint
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestFLACDecoder checks the decoded samples against the MD5 of STREAMINFO
+func TestFLACDecoder(t *testing.T) {
+	for _, dir := range []string{"with_tags", "without_tags"} {
+		t.Run(dir, func(t *testing.T) {
+			b, err := os.ReadFile("../testdata/" + dir + "/sample.flac")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			d, err := newFLACDecoder(bytes.NewReader(b))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d.md5 == [16]byte{} {
+				t.Fatal("no MD5 in STREAMINFO")
+			}
+
+			h := md5.New()
+			sampleSize := (d.format.BitsPerSample + 7) / 8
+			buf := make([]byte, 8)
+			samples := 0
+			for {
+				block, err := d.next()
+				if err != nil {
+					break
+				}
+				for i := range block[0] {
+					for _, channel := range block {
+						binary.LittleEndian.PutUint64(buf, uint64(channel[i]))
+						h.Write(buf[:sampleSize])
+					}
+				}
+				samples += len(block[0])
+			}
+
+			if got := h.Sum(nil); !bytes.Equal(got, d.md5[:]) {
+				t.Errorf("MD5 of %d samples = %x, want %x", samples, got, d.md5)
+			}
+			if got := time.Duration(samples) * time.Second / time.Duration(d.format.SampleRate); got != d.format.Duration {
+				t.Errorf("decoded %v, want %v", got, d.format.Duration)
+			}
+		})
+	}
+}
+
+func TestFromFLACNotFLAC(t *testing.T) {
+	if _, _, err := FromFLAC(bytes.NewReader([]byte("RIFF\x00\x00\x00\x00WAVE"))); err == nil {
+		t.Error("FromFLAC(WAV) returned no error")
+	}
+}
@@ -2,9 +2,13 @@ module music-go
 
 go 1.24.1
 
+require (
+	github.com/hajimehoshi/go-mp3 v0.3.4
+	github.com/tursodatabase/go-libsql v0.0.0-20250313100617-0ab5a1a61a71
+)
+
 require (
 	github.com/antlr4-go/antlr/v4 v4.13.0 // indirect
 	github.com/libsql/sqlite-antlr4-parser v0.0.0-20240327125255-dbf53b6cbf06 // indirect
-	github.com/tursodatabase/go-libsql v0.0.0-20250313100617-0ab5a1a61a71 // indirect
 	golang.org/x/exp v0.0.0-20230515195305-f3d0a9c9a5cc // indirect
 )
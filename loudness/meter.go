@@ -0,0 +1,198 @@
+package loudness
+
+import (
+	"math"
+)
+
+// ReplayGain 2.0 reference level in LUFS
+const ReferenceLoudness = -18.0
+
+const (
+	absoluteGate = -70.0 // LUFS
+	relativeGate = -10.0 // LU below the absolute gated loudness
+)
+
+// biquad is a second order IIR filter in direct form I
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     float64
+}
+
+func (f *biquad) process(x float64) float64 {
+	y := f.b0*x + f.b1*f.x1 + f.b2*f.x2 - f.a1*f.y1 - f.a2*f.y2
+	f.x2, f.x1 = f.x1, x
+	f.y2, f.y1 = f.y1, y
+	return y
+}
+
+// kWeighting returns the two stages of the ITU-R BS.1770 K-weighting filter
+// (high shelf then high pass) for the sample rate, the coefficients are
+// derived from the analog prototypes so any sample rate is supported.
+func kWeighting(sampleRate int) [2]biquad {
+	fs := float64(sampleRate)
+
+	// stage 1: high shelf modelling the acoustic effect of the head
+	f0 := 1681.974450955533
+	g := 3.999843853973347
+	q := 0.7071752369554196
+	k := math.Tan(math.Pi * f0 / fs)
+	vh := math.Pow(10, g/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/q + k*k
+	shelf := biquad{
+		b0: (vh + vb*k/q + k*k) / a0,
+		b1: 2 * (k*k - vh) / a0,
+		b2: (vh - vb*k/q + k*k) / a0,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+
+	// stage 2: RLB high pass
+	f0 = 38.13547087602444
+	q = 0.5003270373238773
+	k = math.Tan(math.Pi * f0 / fs)
+	a0 = 1 + k/q + k*k
+	highPass := biquad{
+		b0: 1,
+		b1: -2,
+		b2: 1,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+
+	return [2]biquad{shelf, highPass}
+}
+
+// Meter measures the integrated loudness (EBU R128 / ITU-R BS.1770-4) and
+// the true peak of an audio stream.
+//
+// The loudness is computed from 400ms gating blocks overlapping by 75%, the
+// blocks below -70 LUFS and then the blocks 10 LU below the loudness of the
+// remaining blocks are ignored.
+type Meter struct {
+	channels  int
+	filters   [][2]biquad
+	truePeaks []*truePeakMeter
+
+	subBlockSize int        // samples per channel in 100ms
+	subBlockSum  float64    // sum of the weighted squares of the current sub block
+	subBlockLen  int        // samples per channel in the current sub block
+	lastSums     [3]float64 // sums of the 3 previous sub blocks
+	subBlocks    int        // number of complete sub blocks
+
+	blocks []float64 // mean square of every 400ms block
+}
+
+// NewMeter returns a meter for interleaved samples with the given sample
+// rate and number of channels (1 or 2, the other channels are ignored).
+func NewMeter(sampleRate, channels int) *Meter {
+	m := &Meter{
+		channels:     channels,
+		filters:      make([][2]biquad, channels),
+		truePeaks:    make([]*truePeakMeter, channels),
+		subBlockSize: sampleRate / 10,
+	}
+
+	for i := 0; i < channels; i++ {
+		m.filters[i] = kWeighting(sampleRate)
+		m.truePeaks[i] = newTruePeakMeter()
+	}
+	return m
+}
+
+// Write adds interleaved samples in the range [-1, 1], len(samples) must be
+// a multiple of the number of channels.
+func (m *Meter) Write(samples []float64) {
+	for i := 0; i+m.channels <= len(samples); i += m.channels {
+		var sum float64
+		for c := 0; c < m.channels; c++ {
+			x := samples[i+c]
+			m.truePeaks[c].write(x)
+
+			y := m.filters[c][0].process(x)
+			y = m.filters[c][1].process(y)
+			// the weight of the left and right (and center) channels is 1.0
+			sum += y * y
+		}
+
+		m.subBlockSum += sum
+		m.subBlockLen++
+		if m.subBlockLen == m.subBlockSize {
+			m.endSubBlock()
+		}
+	}
+}
+
+// endSubBlock completes a 100ms sub block, every sub block completes a 400ms
+// block with the 3 previous ones.
+func (m *Meter) endSubBlock() {
+	if m.subBlocks >= 3 {
+		total := m.subBlockSum + m.lastSums[0] + m.lastSums[1] + m.lastSums[2]
+		m.blocks = append(m.blocks, total/float64(4*m.subBlockSize))
+	}
+
+	m.lastSums[0], m.lastSums[1], m.lastSums[2] = m.lastSums[1], m.lastSums[2], m.subBlockSum
+	m.subBlocks++
+	m.subBlockSum = 0
+	m.subBlockLen = 0
+}
+
+// Loudness returns the integrated loudness in LUFS, -Inf if the stream is
+// silent or shorter than 400ms.
+func (m *Meter) Loudness() float64 {
+	return IntegratedLoudness(m)
+}
+
+// TruePeak returns the true peak, linear (1.0 is full scale).
+func (m *Meter) TruePeak() float64 {
+	var peak float64
+	for _, t := range m.truePeaks {
+		peak = max(peak, t.peak)
+	}
+	return peak
+}
+
+// IntegratedLoudness returns the integrated loudness of the meters measured
+// as a single stream, used to compute the album loudness from its tracks.
+func IntegratedLoudness(meters ...*Meter) float64 {
+	var sum float64
+	var n int
+	for _, m := range meters {
+		for _, z := range m.blocks {
+			if blockLoudness(z) > absoluteGate {
+				sum += z
+				n++
+			}
+		}
+	}
+
+	if n == 0 {
+		return math.Inf(-1)
+	}
+
+	gate := blockLoudness(sum/float64(n)) + relativeGate
+
+	sum, n = 0, 0
+	for _, m := range meters {
+		for _, z := range m.blocks {
+			if l := blockLoudness(z); l > absoluteGate && l > gate {
+				sum += z
+				n++
+			}
+		}
+	}
+
+	if n == 0 {
+		return math.Inf(-1)
+	}
+	return blockLoudness(sum / float64(n))
+}
+
+func blockLoudness(z float64) float64 {
+	return -0.691 + 10*math.Log10(z)
+}
+
+// Gain returns the ReplayGain 2.0 gain in dB for the loudness.
+func Gain(loudness float64) float64 {
+	return ReferenceLoudness - loudness
+}
@@ -0,0 +1,110 @@
+package loudness
+
+import (
+	"math"
+	"testing"
+)
+
+// sine returns seconds of a sine of the frequency and amplitude (dBFS) on
+// the channels, interleaved
+func sine(rate, channels int, freq, dBFS, phase, seconds float64) []float64 {
+	amplitude := math.Pow(10, dBFS/20)
+	samples := make([]float64, 0, int(seconds*float64(rate))*channels)
+	for i := 0; i < int(seconds*float64(rate)); i++ {
+		x := amplitude * math.Sin(2*math.Pi*freq*float64(i)/float64(rate)+phase)
+		for c := 0; c < channels; c++ {
+			samples = append(samples, x)
+		}
+	}
+	return samples
+}
+
+func measure(rate, channels int, samples []float64) *Meter {
+	m := NewMeter(rate, channels)
+	m.Write(samples)
+	return m
+}
+
+func TestLoudness(t *testing.T) {
+	tests := []struct {
+		name     string
+		rate     int
+		channels int
+		dBFS     float64
+		want     float64
+	}{
+		// a full scale sine on one channel measures -3.01 LUFS
+		{"mono -20 dBFS", 48000, 1, -20, -23.01},
+		{"mono -20 dBFS 44.1 kHz", 44100, 1, -20, -23.01},
+		// EBU Tech 3341 test 1: stereo -23 dBFS sine
+		{"stereo -23 dBFS", 48000, 2, -23, -23},
+		{"stereo -33 dBFS", 48000, 2, -33, -33},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := measure(tt.rate, tt.channels, sine(tt.rate, tt.channels, 997, tt.dBFS, 0, 20))
+			if got := m.Loudness(); math.Abs(got-tt.want) > 0.1 {
+				t.Errorf("Loudness() = %.2f LUFS, want %.2f ±0.1", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoudnessSilence(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples []float64
+	}{
+		{"silence", make([]float64, 2*48000*5)},
+		{"below the absolute gate", sine(48000, 2, 997, -80, 0, 5)},
+		{"shorter than 400ms", sine(48000, 2, 997, -20, 0, 0.39)},
+		{"empty", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := measure(48000, 2, tt.samples).Loudness(); !math.IsInf(got, -1) {
+				t.Errorf("Loudness() = %v, want -Inf", got)
+			}
+		})
+	}
+}
+
+func TestIntegratedLoudness(t *testing.T) {
+	// the album is measured as one stream: the quiet track is gated out by
+	// the relative gate
+	loud := measure(48000, 2, sine(48000, 2, 997, -20, 0, 10))
+	quiet := measure(48000, 2, sine(48000, 2, 997, -40, 0, 10))
+	if got := IntegratedLoudness(loud, quiet); math.Abs(got-loud.Loudness()) > 0.1 {
+		t.Errorf("IntegratedLoudness() = %.2f LUFS, want %.2f", got, loud.Loudness())
+	}
+
+	same := measure(48000, 2, sine(48000, 2, 997, -20, 0, 10))
+	if got := IntegratedLoudness(loud, same); math.Abs(got-loud.Loudness()) > 0.01 {
+		t.Errorf("IntegratedLoudness() = %.2f LUFS, want %.2f", got, loud.Loudness())
+	}
+}
+
+func TestTruePeak(t *testing.T) {
+	// the samples of a sine at a quarter of the sample rate shifted by 45°
+	// are at 70.7% of its peak
+	samples := sine(48000, 1, 12000, -6, math.Pi/4, 1)
+	var samplePeak float64
+	for _, x := range samples {
+		samplePeak = max(samplePeak, math.Abs(x))
+	}
+
+	amplitude := math.Pow(10, -6.0/20)
+	got := measure(48000, 1, samples).TruePeak()
+	if got <= samplePeak*1.2 {
+		t.Errorf("TruePeak() = %.3f, want more than the sample peak %.3f", got, samplePeak)
+	}
+	if math.Abs(got-amplitude) > 0.05 {
+		t.Errorf("TruePeak() = %.3f, want %.3f ±0.05", got, amplitude)
+	}
+
+	// without inter-sample peaks the true peak is the sample peak
+	samples = sine(48000, 1, 100, -6, 0, 1)
+	if got := measure(48000, 1, samples).TruePeak(); math.Abs(got-amplitude) > 0.01 {
+		t.Errorf("TruePeak() of a low frequency sine = %.3f, want %.3f", got, amplitude)
+	}
+}
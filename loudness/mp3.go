@@ -0,0 +1,59 @@
+package loudness
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"music-go/musictag"
+
+	"github.com/hajimehoshi/go-mp3"
+)
+
+// number of stereo frames decoded at once
+const decodeFrames = 4096
+
+// AnalyzeMP3 decodes the MP3 stream and measures its loudness.
+func AnalyzeMP3(r io.ReadSeeker) (*Meter, error) {
+	// the decoder always returns 2 channels, mono files are measured as a
+	// single channel
+	props, err := musictag.ReadAudioProperties(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	d, err := mp3.NewDecoder(r)
+	if err != nil {
+		return nil, err
+	}
+
+	channels := 2
+	if props.Channels == 1 {
+		channels = 1
+	}
+	m := NewMeter(d.SampleRate(), channels)
+
+	buf := make([]byte, decodeFrames*4)
+	samples := make([]float64, 0, decodeFrames*2)
+	for {
+		n, err := io.ReadFull(d, buf)
+		// 16 bit little endian, left and right
+		samples = samples[:0]
+		for i := 0; i+4 <= n; i += 4 {
+			samples = append(samples, float64(int16(binary.LittleEndian.Uint16(buf[i:])))/32768)
+			if channels == 2 {
+				samples = append(samples, float64(int16(binary.LittleEndian.Uint16(buf[i+2:])))/32768)
+			}
+		}
+		m.Write(samples)
+
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return m, nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+}
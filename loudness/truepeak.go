@@ -0,0 +1,53 @@
+package loudness
+
+import "math"
+
+// true peak is measured by oversampling 4 times (ITU-R BS.1770-4 annex 2)
+const (
+	oversampling  = 4
+	tapsPerPhase  = 12
+	interpolation = oversampling * tapsPerPhase
+)
+
+// polyphase coefficients of the interpolation filter, a windowed sinc with
+// the cutoff at the original Nyquist frequency
+var truePeakPhases = func() [oversampling][tapsPerPhase]float64 {
+	var phases [oversampling][tapsPerPhase]float64
+	center := float64(interpolation-1) / 2
+
+	for n := 0; n < interpolation; n++ {
+		x := (float64(n) - center) / oversampling
+		h := 1.0
+		if x != 0 {
+			h = math.Sin(math.Pi*x) / (math.Pi * x)
+		}
+		// Hann window
+		h *= 0.5 - 0.5*math.Cos(2*math.Pi*(float64(n)+0.5)/interpolation)
+
+		phases[n%oversampling][n/oversampling] = h
+	}
+	return phases
+}()
+
+type truePeakMeter struct {
+	history [tapsPerPhase]float64 // last samples, history[0] is the newest
+	peak    float64
+}
+
+func newTruePeakMeter() *truePeakMeter {
+	return &truePeakMeter{}
+}
+
+func (t *truePeakMeter) write(x float64) {
+	copy(t.history[1:], t.history[:tapsPerPhase-1])
+	t.history[0] = x
+
+	t.peak = max(t.peak, math.Abs(x))
+	for p := range truePeakPhases {
+		var y float64
+		for k, h := range truePeakPhases[p] {
+			y += h * t.history[k]
+		}
+		t.peak = max(t.peak, math.Abs(y))
+	}
+}
@@ -24,6 +24,14 @@ func main() {
 	}
     defer db.Close()
 
+	// music-go duplicates: print the songs stored more than once and exit
+	if len(os.Args) > 1 && os.Args[1] == "duplicates" {
+		if err := printDuplicates(db); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
     // musics, err := FindAllFilesRecursively(os.Args[1])
     // logger.Println(err)
     // err = db.PushMusicsTOmusicsTable(musics)
@@ -61,3 +69,25 @@ func FindAllFilesRecursively(dir_path string) ([]string, error) {
 
 	return files, nil
 }
+
+// print the songs with the same audio data, the audio hash of the songs
+// scanned by older versions is computed first
+func printDuplicates(db *database.DataBase) error {
+	if _, err := db.UpdateAudioHashes(); err != nil {
+		return err
+	}
+
+	groups, err := db.GetDuplicates()
+	if err != nil {
+		return err
+	}
+
+	for _, g := range groups {
+		fmt.Printf("%s (%d copies)\n", g.AudioHash, len(g.Songs))
+		for _, song := range g.Songs {
+			fmt.Printf("\t%d\t%s\n", song.Id, song.Path)
+		}
+	}
+	fmt.Printf("%d songs have duplicate copies\n", len(groups))
+	return nil
+}
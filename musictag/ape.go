@@ -0,0 +1,319 @@
+package musictag
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var ErrNoAPETag = errors.New("No APEv2 tag found")
+
+// APEMetadata is the implementation of Metadata used for APEv1 and APEv2 tags
+// (Monkey's Audio, WavPack, Musepack and some MP3 files).
+type APEMetadata struct {
+	fileType FileType
+	version  uint
+	items    map[string]string // multiple values are separated by $00
+	pictures []*Picture
+}
+
+func (m APEMetadata) getString(keys ...string) string {
+	for _, k := range keys {
+		if v, ok := m.items[k]; ok {
+			return firstValue(v)
+		}
+	}
+	return ""
+}
+
+// firstValue returns the first value of an item with multiple values
+func firstValue(v string) string {
+	first, _, _ := strings.Cut(v, "\x00")
+	return first
+}
+
+func (APEMetadata) GetTagFormat() TagFormat   { return APEv2 }
+func (m APEMetadata) GetFileType() FileType   { return m.fileType }
+func (m APEMetadata) GetTitle() string        { return m.getString("title") }
+func (m APEMetadata) GetArtist() string       { return m.getString("artist") }
+func (m APEMetadata) GetAlbum() string        { return m.getString("album") }
+func (m APEMetadata) GetGenre() string        { return m.getString("genre") }
+func (m APEMetadata) GetComment() string      { return m.getString("comment") }
+func (m APEMetadata) GetAlbumArt() *Picture   { return frontCover(m.pictures) }
+func (m APEMetadata) GetPictures() []*Picture { return m.pictures }
+func (m APEMetadata) GetVersion() uint        { return m.version }
+func (m APEMetadata) GetComposer() string     { return m.getString("composer") }
+func (m APEMetadata) GetTrack() (int, int)    { return parseNumberPair(m.getString("track")) }
+func (m APEMetadata) GetDisc() (int, int)     { return parseNumberPair(m.getString("disc")) }
+func (m APEMetadata) GetBPM() int             { return parseBPM(m.getString("bpm")) }
+func (m APEMetadata) GetLyrics() string       { return m.getString("lyrics") }
+
+func (m APEMetadata) GetSyncedLyrics() []LyricLine { return parseEmbeddedLRC(m.GetLyrics()) }
+func (m APEMetadata) GetRating() int               { return parseFMPSRating(m.GetCustom(fmpsRating)) }
+func (m APEMetadata) GetPlayCount() int            { return parseFMPSPlayCount(m.GetCustom(fmpsPlayCount)) }
+func (m APEMetadata) GetChapters() []Chapter       { return nil }
+
+// GetCustom returns the first value of the item key, or of the first item
+// (in alphabetical order) matching key.
+func (m APEMetadata) GetCustom(key string) string {
+	// item keys are case insensitive, they are stored in lower case
+	if v, ok := m.items[strings.ToLower(key)]; ok {
+		return firstValue(v)
+	}
+
+	for _, k := range slices.Sorted(maps.Keys(m.items)) {
+		if matchCustomKey(k, key) {
+			return firstValue(m.items[k])
+		}
+	}
+	return ""
+}
+
+// GetArtists returns the values of the Artists item, or of the Artist item
+// which can hold multiple values.
+func (m APEMetadata) GetArtists() []string {
+	if v, ok := m.items["artists"]; ok {
+		return splitNullSeparated(v)
+	}
+	return splitNullSeparated(m.items["artist"])
+}
+
+func (m APEMetadata) GetAlbumArtist() string { return m.getString("album artist", "albumartist") }
+
+func (m APEMetadata) GetYear() int {
+	date := m.getString("year", "date")
+	if len(date) > 4 {
+		date = date[:4]
+	}
+
+	year, err := strconv.Atoi(date)
+	if err != nil {
+		return 0
+	}
+	return year
+}
+
+// findTrailingTagsEnd returns the offset where the trailing APE tag (if any)
+// ends, skipping the ID3v1 tag, the Lyrics3v2 block and the appended ID3v2
+// tag which can follow it.
+func findTrailingTagsEnd(r io.ReadSeeker) (int64, error) {
+	end, err := findID3v1End(r)
+	if err != nil {
+		return 0, err
+	}
+
+	start, err := findAppendedID3v2Tag(r, end)
+	if err != nil {
+		return 0, err
+	}
+	if start >= 0 {
+		end = start
+	}
+	return end, nil
+}
+
+// findID3v1End returns the offset where the file ends before the ID3v1 tag
+// and the Lyrics3v2 block.
+//
+// Lyrics3v2 block (see https://id3.org/Lyrics3v2)
+// Start               "LYRICSBEGIN"
+// Fields              [3 byte ID, 5 digit size, data]
+// Size                [6 digit size of the block excluding this and "LYRICS200"]
+// End                 "LYRICS200"
+func findID3v1End(r io.ReadSeeker) (int64, error) {
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+
+	// ID3v1
+	if end >= 128 {
+		if _, err = r.Seek(end-128, io.SeekStart); err != nil {
+			return 0, err
+		}
+		tag, err := readString(r, 3)
+		if err != nil {
+			return 0, err
+		}
+		if tag == "TAG" {
+			end -= 128
+		}
+	}
+
+	// Lyrics3v2
+	if end >= 15 {
+		if _, err = r.Seek(end-15, io.SeekStart); err != nil {
+			return 0, err
+		}
+		b, err := readBytes(r, 15)
+		if err != nil {
+			return 0, err
+		}
+		if string(b[6:15]) == "LYRICS200" {
+			size, err := strconv.Atoi(string(b[0:6]))
+			if err == nil && int64(size)+15 <= end {
+				end -= int64(size) + 15
+			}
+		}
+	}
+
+	return end, nil
+}
+
+// ReadAPETags reads the APEv2 (or APEv1) tag at the end of the file.
+// (see https://wiki.hydrogenaud.io/index.php?title=APEv2_specification)
+//
+// Footer (and optional header, same layout)
+// Preamble            "APETAGEX"
+// Version             [uint32 little endian] (1000 or 2000)
+// Tag size            [uint32 little endian] (items and footer, excluding header)
+// Item count          [uint32 little endian]
+// Flags               [uint32 little endian]
+// Reserved            [8 bytes]
+//
+// Item
+// Value size          [uint32 little endian]
+// Flags               [uint32 little endian]
+// Key                 [ASCII string] $00
+// Value               [UTF-8 text or binary data]
+func ReadAPETags(r io.ReadSeeker) (Metadata, error) {
+	end, err := findTrailingTagsEnd(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if end < 32 {
+		return nil, ErrNoAPETag
+	}
+
+	if _, err = r.Seek(end-32, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	footer, err := readBytes(r, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	if string(footer[0:8]) != "APETAGEX" {
+		return nil, ErrNoAPETag
+	}
+
+	version := uint(getIntLittleEndian(footer[8:12]))
+	size := int64(getIntLittleEndian(footer[12:16]))
+	count := uint(getIntLittleEndian(footer[16:20]))
+
+	if size < 32 || size > end {
+		return nil, fmt.Errorf("invalid APE tag size %d", size)
+	}
+
+	if _, err = r.Seek(end-size, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	b, err := readBytes(r, uint(size-32))
+	if err != nil {
+		return nil, err
+	}
+
+	fileType, err := sniffAPEFileType(r)
+	if err != nil {
+		return nil, err
+	}
+
+	m := APEMetadata{
+		fileType: fileType,
+		version:  version,
+		items:    make(map[string]string),
+	}
+
+	for i := uint(0); i < count && len(b) >= 8; i++ {
+		valueSize := uint(getIntLittleEndian(b[0:4]))
+		flags := uint(getIntLittleEndian(b[4:8]))
+		b = b[8:]
+
+		keyEnd := bytes.IndexByte(b, 0)
+		if keyEnd < 0 {
+			return nil, errors.New("invalid APE item: key is not terminated")
+		}
+		key := strings.ToLower(string(b[:keyEnd]))
+		b = b[keyEnd+1:]
+
+		if valueSize > uint(len(b)) {
+			return nil, fmt.Errorf("invalid APE item %q size %d", key, valueSize)
+		}
+		value := b[:valueSize]
+		b = b[valueSize:]
+
+		// bits 1-2: 0 -> UTF-8 text, 1 -> binary, 2 -> external link
+		switch (flags >> 1) & 0x03 {
+		case 0:
+			m.items[key] = string(value)
+
+		case 1:
+			if strings.HasPrefix(key, "cover art") {
+				m.pictures = append(m.pictures, readAPEPicture(key, value))
+			}
+		}
+	}
+
+	return m, nil
+}
+
+// binary cover art items start with the file name terminated by $00
+func readAPEPicture(key string, b []byte) *Picture {
+	var desc string
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		desc = string(b[:i])
+		b = b[i+1:]
+	}
+
+	p := &Picture{
+		Description: desc,
+		Data:        b,
+	}
+
+	switch key {
+	case "cover art (front)":
+		p.Type = pictureTypes[0x03]
+	case "cover art (back)":
+		p.Type = pictureTypes[0x04]
+	default:
+		p.Type = pictureTypes[0x00]
+	}
+
+	switch {
+	case bytes.HasPrefix(b, []byte("\xff\xd8")):
+		p.Ext, p.MIMEType = "jpg", "image/jpeg"
+	case bytes.HasPrefix(b, []byte("\x89PNG")):
+		p.Ext, p.MIMEType = "png", "image/png"
+	}
+	return p
+}
+
+// sniffAPEFileType guess the file type which carry the APE tag from the magic bytes.
+func sniffAPEFileType(r io.ReadSeeker) (FileType, error) {
+	start, err := leadingID3v2End(r)
+	if err != nil {
+		return UnknownFileType, err
+	}
+
+	if _, err = r.Seek(start, io.SeekStart); err != nil {
+		return UnknownFileType, err
+	}
+
+	header, err := io.ReadAll(io.LimitReader(r, sniffHeaderSize))
+	if err != nil {
+		return UnknownFileType, err
+	}
+
+	if fileType := sniffAPETagged(header); fileType != UnknownFileType {
+		return fileType, nil
+	}
+	return sniffMPEG(header), nil
+}
@@ -0,0 +1,276 @@
+package musictag
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var ErrNoAudioData = errors.New("no audio data found")
+
+// AudioHash returns the hex encoded SHA-256 of the audio data of the file.
+// The tags (ID3v2, ID3v1, Lyrics3, APE) and the container metadata (FLAC
+// metadata blocks, Ogg header packets, MP4 atoms other than mdat, RIFF and
+// AIFF chunks other than the sound data) are excluded, so the hash of a file
+// doesn't change when it is retagged and two files with the same hash hold
+// the same audio.
+func AudioHash(r io.ReadSeeker) (string, error) {
+	_, fileType, start, err := sniffContainer(r)
+	if err != nil {
+		return "", err
+	}
+
+	end, err := audioDataEnd(r)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err = r.Seek(start, io.SeekStart); err != nil {
+		return "", err
+	}
+	c := &containerReader{r: r, start: start}
+
+	h := sha256.New()
+	w := &countingWriter{w: h}
+	switch fileType {
+	case FLAC:
+		err = hashFLACAudio(c, end-start, w)
+	case OGG, OPUS:
+		err = hashOggAudio(c, w)
+	case MP4:
+		err = hashMP4Audio(c, w)
+	case WAV:
+		err = hashIFFAudio(c, binary.LittleEndian, "data", w)
+	case AIFF:
+		err = hashIFFAudio(c, binary.BigEndian, "SSND", w)
+	case DSF:
+		err = hashDSFAudio(c, w)
+	default:
+		// MPEG audio and the APE tagged formats: the audio is between the
+		// leading and the trailing tags
+		_, err = io.CopyN(w, r, end-start)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if w.n == 0 {
+		return "", ErrNoAudioData
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// hashFLACAudio hashes the frames following the metadata blocks, end is the
+// offset of the trailing tags.
+func hashFLACAudio(r io.ReadSeeker, end int64, w io.Writer) error {
+	magic, err := readString(r, 4)
+	if err != nil {
+		return err
+	}
+	if magic != "fLaC" {
+		return ErrNotFLAC
+	}
+
+	for last := false; !last; {
+		var size uint
+		last, _, size, err = readFLACMetadataBlockHeader(r)
+		if err != nil {
+			return err
+		}
+		if _, err = r.Seek(int64(size), io.SeekCurrent); err != nil {
+			return err
+		}
+	}
+
+	offset, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if offset > end {
+		return ErrTruncated
+	}
+	_, err = io.CopyN(w, r, end-offset)
+	return err
+}
+
+// hashOggAudio hashes the packets of the first logical stream following the
+// header packets. The pages are not hashed, their sequence numbers and
+// checksums change when the comment header grows by a page.
+func hashOggAudio(r io.ReadSeeker, w io.Writer) error {
+	var serial uint
+	var first []byte
+	headers := -1 // number of header packets, known once the first packet is read
+	packets := 0
+
+	for page := 0; ; page++ {
+		h, err := readOggPageHeader(r)
+		if err == io.EOF || err == io.ErrUnexpectedEOF || (err == ErrNotOgg && page > 0) {
+			// end of the stream, or trailing tags
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if page == 0 {
+			serial = h.Serial
+		} else if h.Serial != serial {
+			// page of an other multiplexed stream
+			var pageSize int64
+			for _, l := range h.SegmentTable {
+				pageSize += int64(l)
+			}
+			if _, err := r.Seek(pageSize, io.SeekCurrent); err != nil {
+				return err
+			}
+			continue
+		}
+
+		for _, l := range h.SegmentTable {
+			b, err := readBytes(r, uint(l))
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+
+			switch {
+			case headers < 0:
+				first = append(first, b...)
+			case packets >= headers:
+				if _, err := w.Write(b); err != nil {
+					return err
+				}
+			}
+
+			// a lacing value less than 255 terminates the packet
+			if l < 255 {
+				if headers < 0 {
+					headers = oggHeaderPackets(first)
+				}
+				packets++
+			}
+		}
+	}
+}
+
+// oggHeaderPackets returns the number of header packets of the stream from
+// its first packet: identification, comment and setup headers for Vorbis,
+// the number given by the mapping header for FLAC, and the identification
+// and comment headers for the other codecs (Opus, Speex).
+// FLAC mapping header
+// Signature           $7F "FLAC"
+// Version             [2 bytes]
+// Header packets      [uint16] (following this packet)
+func oggHeaderPackets(first []byte) int {
+	switch {
+	case len(first) >= 7 && string(first[0:7]) == "\x01vorbis":
+		return 3
+	case len(first) >= 9 && string(first[0:5]) == "\x7fFLAC":
+		return 1 + getInt(first[7:9])
+	}
+	return 2
+}
+
+// hashMP4Audio hashes the media data atoms (mdat).
+func hashMP4Audio(r io.ReadSeeker, w io.Writer) error {
+	remaining, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if _, err = r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	for remaining >= 8 {
+		name, size, headerSize, err := readMP4AtomHeader(r, remaining)
+		if err != nil {
+			return err
+		}
+		remaining -= int64(size)
+		bodySize := int64(size - headerSize)
+
+		if name == "mdat" {
+			_, err = io.CopyN(w, r, bodySize)
+		} else {
+			_, err = r.Seek(bodySize, io.SeekCurrent)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hashIFFAudio hashes the sound data chunk of a RIFF/WAVE or AIFF file
+// (see ReadWAVTags and ReadAIFFTags for the layout).
+func hashIFFAudio(r io.ReadSeeker, bo binary.ByteOrder, dataChunk string, w io.Writer) error {
+	b, err := readBytes(r, 12)
+	if err != nil {
+		return err
+	}
+
+	size := int64(bo.Uint32(b[4:8])) - 4
+	return readIFFChunks(r, size, bo, func(id string, size uint) error {
+		if id == dataChunk {
+			_, err := io.CopyN(w, r, int64(size))
+			return err
+		}
+		_, err := r.Seek(int64(size), io.SeekCurrent)
+		return err
+	})
+}
+
+// hashDSFAudio hashes the data chunk of a DSF file, it follows the DSD and
+// fmt chunks (see ReadDSFTags).
+// data chunk
+// Header              "data"
+// Chunk size          [uint64 little endian] (12 + sample data size)
+// Sample data
+func hashDSFAudio(r io.ReadSeeker, w io.Writer) error {
+	b, err := readBytes(r, 28)
+	if err != nil {
+		return err
+	}
+	if string(b[0:4]) != "DSD " {
+		return ErrNotDSF
+	}
+	offset := int64(getIntLittleEndian(b[4:12]))
+
+	for _, id := range []string{"fmt ", "data"} {
+		if _, err = r.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+		b, err = readBytes(r, 12)
+		if err != nil {
+			return err
+		}
+		if string(b[0:4]) != id {
+			return fmt.Errorf("%w: expected %q chunk", ErrNotDSF, id)
+		}
+
+		size := int64(getIntLittleEndian(b[4:12]))
+		if size < 12 {
+			return fmt.Errorf("%w: invalid size %d for chunk %q", ErrNotDSF, size, id)
+		}
+		if id == "data" {
+			_, err = io.CopyN(w, r, size-12)
+			return err
+		}
+		offset += size
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+package musictag
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func hashOf(t *testing.T, b []byte) string {
+	t.Helper()
+	h, err := AudioHash(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func readTestdata(t *testing.T, dir, name string) []byte {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join("..", "testdata", dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// checkRetagged checks that the retagged file has the new title and the
+// audio hash of the original file.
+func checkRetagged(t *testing.T, original, retagged []byte) {
+	t.Helper()
+	if bytes.Equal(original, retagged) {
+		t.Fatal("the file was not retagged")
+	}
+
+	m, err := ReadFrom(bytes.NewReader(retagged))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.GetTitle(); got != "Retagged" {
+		t.Errorf("title = %q, want %q", got, "Retagged")
+	}
+
+	if got, want := hashOf(t, retagged), hashOf(t, original); got != want {
+		t.Errorf("AudioHash of the retagged file = %s, want %s", got, want)
+	}
+}
+
+func TestAudioHashMP3(t *testing.T) {
+	untagged := readTestdata(t, "without_tags", "sample.mp3")
+	for _, name := range []string{"sample.id3v11.mp3", "sample.id3v22.mp3", "sample.id3v23.mp3", "sample.id3v24.mp3"} {
+		if got, want := hashOf(t, readTestdata(t, "with_tags", name)), hashOf(t, untagged); got != want {
+			t.Errorf("%s: AudioHash = %s, want %s", name, got, want)
+		}
+	}
+
+	// the tag grows and the file is rewritten
+	path := copyFixture(t, "with_tags", "sample.id3v24.mp3")
+	tag := readWritablePath(t, path)
+	if err := tag.SetText("TIT2", "Retagged"); err != nil {
+		t.Fatal(err)
+	}
+	tag.SetLyrics("eng", "", string(bytes.Repeat([]byte("la "), 1000)))
+	if err := WriteID3v2Tag(path, tag); err != nil {
+		t.Fatal(err)
+	}
+	retagged, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRetagged(t, readTestdata(t, "with_tags", "sample.id3v24.mp3"), retagged)
+}
+
+func TestAudioHashFLAC(t *testing.T) {
+	original := readTestdata(t, "with_tags", "sample.flac")
+	if got, want := hashOf(t, original), hashOf(t, readTestdata(t, "without_tags", "sample.flac")); got != want {
+		t.Errorf("AudioHash = %s, want the hash of the untagged file %s", got, want)
+	}
+
+	// the VORBIS_COMMENT block is replaced by a larger one
+	retagged := []byte("fLaC")
+	for offset := 4; ; {
+		header := original[offset : offset+4]
+		size := int(header[1])<<16 | int(header[2])<<8 | int(header[3])
+		body := original[offset+4 : offset+4+size]
+		if header[0]&0x7F == 4 {
+			body = vorbisComment("TITLE=Retagged", "COMMENT=a longer comment than the one of the fixture")
+		}
+
+		retagged = append(retagged, header[0], byte(len(body)>>16), byte(len(body)>>8), byte(len(body)))
+		retagged = append(retagged, body...)
+		offset += 4 + size
+		if header[0]&0x80 != 0 {
+			retagged = append(retagged, original[offset:]...)
+			break
+		}
+	}
+	checkRetagged(t, original, retagged)
+}
+
+func TestAudioHashMP4(t *testing.T) {
+	untagged := readTestdata(t, "without_tags", "sample.m4a")
+	for _, name := range []string{"sample.m4a", "sample.mp4"} {
+		if got, want := hashOf(t, readTestdata(t, "with_tags", name)), hashOf(t, untagged); got != want {
+			t.Errorf("%s: AudioHash = %s, want the hash of the untagged file %s", name, got, want)
+		}
+	}
+}
+
+// oggPage returns a page of the complete packets, the checksum is not computed
+func oggPage(serial, sequence uint32, packets ...[]byte) []byte {
+	var segments, data []byte
+	for _, p := range packets {
+		for n := len(p); ; n -= 255 {
+			segments = append(segments, byte(min(n, 255)))
+			if n < 255 {
+				break
+			}
+		}
+		data = append(data, p...)
+	}
+
+	b := []byte("OggS\x00\x00")
+	b = binary.LittleEndian.AppendUint64(b, 0)
+	b = binary.LittleEndian.AppendUint32(b, serial)
+	b = binary.LittleEndian.AppendUint32(b, sequence)
+	b = binary.LittleEndian.AppendUint32(b, 0)
+	b = append(b, byte(len(segments)))
+	b = append(b, segments...)
+	return append(b, data...)
+}
+
+func TestAudioHashOgg(t *testing.T) {
+	for _, name := range []string{"sample.ogg", "sample.multipage.ogg"} {
+		t.Run(name, func(t *testing.T) {
+			original := readTestdata(t, "with_tags", name)
+			r := bytes.NewReader(original)
+			headers, err := readOggPackets(r, 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the audio starts on the page following the setup header
+			if _, err := r.Seek(0, io.SeekStart); err != nil {
+				t.Fatal(err)
+			}
+			var serial uint
+			for packets := 0; packets < 3; {
+				h, err := readOggPageHeader(r)
+				if err != nil {
+					t.Fatal(err)
+				}
+				serial = h.Serial
+				var size int64
+				for _, l := range h.SegmentTable {
+					size += int64(l)
+					if l < 255 {
+						packets++
+					}
+				}
+				if _, err := r.Seek(size, io.SeekCurrent); err != nil {
+					t.Fatal(err)
+				}
+			}
+			audio, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			comment := append([]byte("\x03vorbis"), vorbisComment("TITLE=Retagged", "COMMENT=a longer comment than the one of the fixture")...)
+			comment = append(comment, 1)
+			retagged := oggPage(uint32(serial), 0, headers[0])
+			retagged = append(retagged, oggPage(uint32(serial), 1, comment, headers[2])...)
+			retagged = append(retagged, audio...)
+			checkRetagged(t, original, retagged)
+		})
+	}
+}
+
+// iffFile returns a RIFF/WAVE (little endian) or AIFF (big endian) file of
+// the chunks, given as pairs of ID and data.
+func iffFile(bo binary.AppendByteOrder, chunks ...string) []byte {
+	form, formType := "RIFF", "WAVE"
+	if bo == binary.BigEndian {
+		form, formType = "FORM", "AIFF"
+	}
+
+	body := []byte(formType)
+	for i := 0; i < len(chunks); i += 2 {
+		body = append(body, chunks[i]...)
+		body = bo.AppendUint32(body, uint32(len(chunks[i+1])))
+		body = append(body, chunks[i+1]...)
+		if len(chunks[i+1])%2 != 0 {
+			body = append(body, 0)
+		}
+	}
+
+	b := bo.AppendUint32([]byte(form), uint32(len(body)))
+	return append(b, body...)
+}
+
+func TestAudioHashWAV(t *testing.T) {
+	// PCM, 1 channel, 44100 Hz, 16 bits
+	format := "\x01\x00\x01\x00\x44\xac\x00\x00\x88\x58\x01\x00\x02\x00\x10\x00"
+	data := string(bytes.Repeat([]byte{1, 2, 3, 4, 5}, 200))
+
+	original := iffFile(binary.LittleEndian, "fmt ", format, "LIST", "INFOINAM\x06\x00\x00\x00Title\x00", "data", data)
+	retagged := iffFile(binary.LittleEndian, "fmt ", format, "data", data, "LIST", "INFOINAM\x09\x00\x00\x00Retagged\x00\x00")
+	checkRetagged(t, original, retagged)
+}
+
+func TestAudioHashAIFF(t *testing.T) {
+	// 1 channel, 500 frames, 16 bits, 44100 Hz
+	comm := "\x00\x01\x00\x00\x01\xf4\x00\x10\x40\x0e\xac\x44\x00\x00\x00\x00\x00\x00"
+	ssnd := "\x00\x00\x00\x00\x00\x00\x00\x00" + string(bytes.Repeat([]byte{1, 2, 3, 4, 5}, 200))
+
+	original := iffFile(binary.BigEndian, "NAME", "Title", "COMM", comm, "SSND", ssnd)
+	retagged := iffFile(binary.BigEndian, "COMM", comm, "SSND", ssnd, "NAME", "Retagged")
+	checkRetagged(t, original, retagged)
+}
+
+func TestAudioHashDSF(t *testing.T) {
+	original := readTestdata(t, "with_tags", "sample.dsf")
+
+	// the ID3v2 tag at the metadata offset is replaced by a larger one
+	tag, err := NewID3v2Tag(ID3v2_3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tag.SetText("TIT2", "Retagged"); err != nil {
+		t.Fatal(err)
+	}
+	tag.SetComment("eng", "", string(bytes.Repeat([]byte("a"), 2000)))
+
+	metadata := binary.LittleEndian.Uint64(original[20:28])
+	retagged := append(bytes.Clone(original[:metadata]), tag.Bytes(0)...)
+	binary.LittleEndian.PutUint64(retagged[12:20], uint64(len(retagged)))
+	checkRetagged(t, original, retagged)
+}
@@ -2,9 +2,15 @@ package musictag
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"io"
 )
 
+// ErrTruncated is returned when a size read in a file is bigger than the
+// data left in the file.
+var ErrTruncated = errors.New("size is bigger than the remaining data")
+
 func getBit(b byte, n uint) bool {
 	x := byte(1 << n)
 	return (b & x) == x
@@ -22,7 +28,7 @@ func get7BitChunkedInt(b []byte) int {
 func read7BitChunkedUint(r io.Reader, n uint) (uint, error) {
 	b, err := readBytes(r, n)
 	if err != nil {
-		return 0, nil
+		return 0, err
 	}
 	return uint(get7BitChunkedInt(b)), nil
 }
@@ -38,8 +44,40 @@ func getInt(b []byte) int {
 
 const readByteMax = 10 << 20 //10MB
 
+// remainingBytes returns the number of bytes left in r, false if it can not
+// be known without reading.
+func remainingBytes(r io.Reader) (int64, bool) {
+	switch r := r.(type) {
+	case interface{ Len() int }:
+		return int64(r.Len()), true
+	case io.Seeker:
+		current, err := r.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0, false
+		}
+		end, err := r.Seek(0, io.SeekEnd)
+		if err != nil {
+			return 0, false
+		}
+		if _, err = r.Seek(current, io.SeekStart); err != nil {
+			return 0, false
+		}
+		return end - current, true
+	}
+	return 0, false
+}
+
+// readBytes reads n bytes, the sizes come from the file so n is checked
+// against the remaining data before allocating the buffer.
 func readBytes(r io.Reader, n uint) ([]byte, error) {
+	if n > 4096 {
+		if remaining, ok := remainingBytes(r); ok && int64(n) > remaining {
+			return nil, fmt.Errorf("%w: %d bytes wanted, %d left", ErrTruncated, n, remaining)
+		}
+	}
+
 	if n > readByteMax {
+		// the reader size is unknown, the buffer only grows with the data read
 		b := &bytes.Buffer{}
 		_, err := io.CopyN(b, r, int64(n))
 		if err != nil {
@@ -79,3 +117,20 @@ func readInt(r io.Reader, n uint) (int, error) {
 	}
 	return getInt(b), nil
 }
+
+func getIntLittleEndian(b []byte) int {
+	var n int
+	for i := len(b) - 1; i >= 0; i-- {
+		n = n << 8
+		n |= int(b[i])
+	}
+	return n
+}
+
+func readUintLittleEndian(r io.Reader, n uint) (uint, error) {
+	b, err := readBytes(r, n)
+	if err != nil {
+		return 0, err
+	}
+	return uint(getIntLittleEndian(b)), nil
+}
@@ -0,0 +1,236 @@
+package musictag
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Chapter is a chapter of an audio book, a podcast or a DJ mix.
+type Chapter struct {
+	Title string
+	Start time.Duration
+	End   time.Duration // 0 if unknown
+}
+
+// id3Chapter is a CHAP frame, the element ID is referenced by the CTOC frames
+type id3Chapter struct {
+	ID string
+	Chapter
+}
+
+// id3TableOfContents is a CTOC frame
+type id3TableOfContents struct {
+	ID       string
+	TopLevel bool
+	Children []string // element IDs of the chapters or of other tables of contents
+}
+
+// readCHAPFrame reads a chapter frame (see https://id3.org/id3v2-chapters-1.0)
+// <ID3v2.3 or ID3v2.4 frame header, ID: "CHAP">
+// Element ID      <text string> $00
+// Start time      $xx xx xx xx (milliseconds)
+// End time        $xx xx xx xx (milliseconds)
+// Start offset    $xx xx xx xx
+// End offset      $xx xx xx xx
+// <Optional embedded sub-frames>
+func readCHAPFrame(b []byte, h *ID3v2Header) (*id3Chapter, error) {
+	id, b, ok := cutTerminatedText(b, encodingISO8859)
+	if !ok || len(b) < 16 {
+		return nil, errors.New("CHAP frame too short")
+	}
+
+	c := &id3Chapter{
+		ID: string(id),
+		Chapter: Chapter{
+			Start: time.Duration(getInt(b[0:4])) * time.Millisecond,
+			End:   time.Duration(getInt(b[4:8])) * time.Millisecond,
+		},
+	}
+	c.Title = readChapterTitle(b[16:], h)
+	return c, nil
+}
+
+// readCTOCFrame reads a table of contents frame
+// <ID3v2.3 or ID3v2.4 frame header, ID: "CTOC">
+// Element ID      <text string> $00
+// Flags           %000000ab (a: top-level, b: ordered)
+// Entry count     $xx
+// Child element ID  <text string> $00 (for every entry)
+// <Optional embedded sub-frames>
+func readCTOCFrame(b []byte) (*id3TableOfContents, error) {
+	id, b, ok := cutTerminatedText(b, encodingISO8859)
+	if !ok || len(b) < 2 {
+		return nil, errors.New("CTOC frame too short")
+	}
+
+	toc := &id3TableOfContents{
+		ID:       string(id),
+		TopLevel: b[0]&0x02 != 0,
+	}
+
+	count := int(b[1])
+	b = b[2:]
+	for i := 0; i < count; i++ {
+		child, rest, ok := cutTerminatedText(b, encodingISO8859)
+		if !ok {
+			return nil, fmt.Errorf("invalid CTOC frame: %d entries expected, got %d", count, i)
+		}
+		toc.Children = append(toc.Children, string(child))
+		b = rest
+	}
+	return toc, nil
+}
+
+// readChapterTitle returns the TIT2 sub-frame of a chapter, the sub-frames
+// have the frame header of the tag version. Invalid sub-frames are ignored.
+// Only the title is decoded, the CHAP sub-frames of a malformed tag must not
+// be read recursively.
+func readChapterTitle(b []byte, h *ID3v2Header) string {
+	if len(b) == 0 {
+		return ""
+	}
+
+	// the unsynchronisation was already removed from the parent frame
+	sub := &ID3v2Header{Version: h.Version, Size: uint(len(b))}
+	titleFrame := frames.Name("title", h.Version)
+
+	var title []string
+	walkID3v2Frames(bytes.NewReader(b), 0, sub, nil, func(name string, b []byte) error {
+		if name != titleFrame || title != nil {
+			return nil
+		}
+		values, err := readTFrame(b)
+		if err != nil {
+			return err
+		}
+		title = append([]string{}, values...)
+		return nil
+	})
+	return strings.Join(title, multiValueSeparator)
+}
+
+// orderID3Chapters returns the chapters in the order of the top-level
+// table of contents, or sorted by start time without one.
+func orderID3Chapters(chapters []*id3Chapter, tocs []*id3TableOfContents) []Chapter {
+	byID := make(map[string]*id3Chapter, len(chapters))
+	for _, c := range chapters {
+		byID[c.ID] = c
+	}
+	tocByID := make(map[string]*id3TableOfContents, len(tocs))
+	for _, toc := range tocs {
+		tocByID[toc.ID] = toc
+	}
+
+	var result []Chapter
+	visited := make(map[string]bool)
+	var walk func(toc *id3TableOfContents)
+	walk = func(toc *id3TableOfContents) {
+		if visited[toc.ID] {
+			return
+		}
+		visited[toc.ID] = true
+
+		for _, id := range toc.Children {
+			if c, ok := byID[id]; ok {
+				result = append(result, c.Chapter)
+			} else if child, ok := tocByID[id]; ok {
+				walk(child)
+			}
+		}
+	}
+
+	for _, toc := range tocs {
+		if toc.TopLevel {
+			walk(toc)
+			break
+		}
+	}
+	if len(result) > 0 {
+		return result
+	}
+
+	for _, c := range chapters {
+		result = append(result, c.Chapter)
+	}
+	sortChapters(result)
+	return result
+}
+
+// sortChapters sorts the chapters by start time and sets the unknown end
+// times to the start of the next chapter.
+func sortChapters(chapters []Chapter) {
+	sort.SliceStable(chapters, func(i, j int) bool { return chapters[i].Start < chapters[j].Start })
+	for i := 0; i+1 < len(chapters); i++ {
+		if chapters[i].End == 0 {
+			chapters[i].End = chapters[i+1].Start
+		}
+	}
+}
+
+// vorbisChapter matches the CHAPTERxxx and CHAPTERxxxNAME fields of the
+// vorbis chapter extension (https://wiki.xiph.org/Chapter_Extension)
+var vorbisChapter = regexp.MustCompile(`^chapter(\d+)(name)?$`)
+
+// readVorbisChapters reads the chapters of vorbis comments, the start time
+// is formatted as HH:MM:SS.sss
+func readVorbisChapters(comments map[string][]string) []Chapter {
+	byNumber := make(map[int]*Chapter)
+	for k, v := range comments {
+		match := vorbisChapter.FindStringSubmatch(k)
+		if match == nil || len(v) == 0 {
+			continue
+		}
+
+		n, _ := strconv.Atoi(match[1])
+		c, ok := byNumber[n]
+		if !ok {
+			c = &Chapter{Start: -1}
+			byNumber[n] = c
+		}
+
+		if match[2] != "" {
+			c.Title = v[0]
+		} else if start, ok := parseChapterTime(v[0]); ok {
+			c.Start = start
+		}
+	}
+
+	var chapters []Chapter
+	for _, c := range byNumber {
+		if c.Start >= 0 {
+			chapters = append(chapters, *c)
+		}
+	}
+	sortChapters(chapters)
+	return chapters
+}
+
+// parseChapterTime parse a time formatted as HH:MM:SS.sss
+func parseChapterTime(s string) (time.Duration, bool) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) != 3 {
+		return 0, false
+	}
+
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, false
+	}
+	minutes, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, false
+	}
+	seconds, err := strconv.ParseFloat(parts[2], 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute +
+		time.Duration(seconds*float64(time.Second)), true
+}
@@ -0,0 +1,115 @@
+package musictag
+
+import (
+	"errors"
+	"io"
+)
+
+var ErrNotDSF = errors.New("Invalid DSF file")
+
+// DSFMetadata is the implementation of Metadata used for DSF files, the tag
+// itself is a ID3v2 tag stored at the end of the file.
+type DSFMetadata struct {
+	Metadata
+	sampleRate uint
+	channels   uint
+}
+
+func (DSFMetadata) GetFileType() FileType { return DSF }
+
+// GetSampleRate returns the DSD sampling frequency in Hz (e.g. 2822400 for DSD64).
+func (m DSFMetadata) GetSampleRate() uint { return m.sampleRate }
+
+// GetChannels returns the number of audio channels.
+func (m DSFMetadata) GetChannels() uint { return m.channels }
+
+// AudioFormat is implemented by the metadata of the files whose header gives
+// the sampling of the audio (DSF for now).
+type AudioFormat interface {
+	GetSampleRate() uint
+	GetChannels() uint
+}
+
+// GetAudioFormat returns the sampling of the audio read with the tags of m,
+// ok is false when the container does not give it.
+func GetAudioFormat(m Metadata) (f AudioFormat, ok bool) {
+	switch m := m.(type) {
+	case AudioFormat:
+		return m, true
+
+	case MultiMetadata:
+		for _, t := range m {
+			if f, ok := GetAudioFormat(t); ok {
+				return f, true
+			}
+		}
+
+	case fileMetadata:
+		return GetAudioFormat(m.Metadata)
+	}
+	return nil, false
+}
+
+// ReadDSFTags reads the ID3v2 tag pointed by the DSD chunk of a DSF file.
+// (see https://dsd-guide.com/sites/default/files/white-papers/DSFFileFormatSpec_E.pdf)
+//
+// A file without tag (null metadata pointer) gives empty tags with the
+// sampling of the audio, see GetAudioFormat.
+//
+// DSD chunk
+// Header              "DSD "
+// Chunk size          [uint64 little endian] (28)
+// Total file size     [uint64 little endian]
+// Metadata pointer    [uint64 little endian] (0 if there is no ID3v2 tag)
+//
+// fmt chunk
+// Header              "fmt "
+// Chunk size          [uint64 little endian] (52)
+// Format version      [uint32 little endian]
+// Format ID           [uint32 little endian]
+// Channel type        [uint32 little endian]
+// Channel num         [uint32 little endian]
+// Sampling frequency  [uint32 little endian]
+// ...
+func ReadDSFTags(r io.ReadSeeker) (Metadata, error) {
+	b, err := readBytes(r, 28)
+	if err != nil {
+		return nil, err
+	}
+
+	if string(b[0:4]) != "DSD " {
+		return nil, ErrNotDSF
+	}
+	metadataPointer := int64(getIntLittleEndian(b[20:28]))
+
+	b, err = readBytes(r, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	if string(b[0:4]) != "fmt " {
+		return nil, ErrNotDSF
+	}
+
+	m := DSFMetadata{
+		channels:   uint(getIntLittleEndian(b[24:28])),
+		sampleRate: uint(getIntLittleEndian(b[28:32])),
+	}
+
+	if metadataPointer == 0 {
+		// the file has no tag, its sampling is still known
+		m.Metadata = ID3v2Metadata{header: &ID3v2Header{}, frames: map[string]any{}}
+		return m, nil
+	}
+
+	if _, err = r.Seek(metadataPointer, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	m.Metadata, err = ReadID3v2Tags(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
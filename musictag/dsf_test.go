@@ -0,0 +1,54 @@
+package musictag
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+// checkDSFAudioFormat checks the sampling of the DSF sample file, 2 channels
+// of DSD64
+func checkDSFAudioFormat(t *testing.T, m Metadata) {
+	t.Helper()
+	f, ok := GetAudioFormat(m)
+	if !ok {
+		t.Fatal("no audio format")
+	}
+	if got := f.GetSampleRate(); got != 2822400 {
+		t.Errorf("sample rate = %d, want 2822400", got)
+	}
+	if got := f.GetChannels(); got != 2 {
+		t.Errorf("%d channels, want 2", got)
+	}
+}
+
+func TestReadDSFTags(t *testing.T) {
+	m := readFixture(t, "with_tags", "sample.dsf")
+	if got := m.GetTagFormat(); got != ID3v2_4 {
+		t.Errorf("tag format = %v, want %v", got, ID3v2_4)
+	}
+	checkFixture(t, m, DSF, 0)
+	checkDSFAudioFormat(t, m)
+}
+
+func TestReadDSFWithoutTag(t *testing.T) {
+	b, err := os.ReadFile("../testdata/with_tags/sample.dsf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a null metadata pointer, the tag is ignored
+	copy(b[20:28], make([]byte, 8))
+
+	m, err := ReadFrom(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.GetFileType(); got != DSF {
+		t.Errorf("file type = %v, want %v", got, DSF)
+	}
+	if got := m.GetTitle(); got != "" {
+		t.Errorf("untagged file title = %q", got)
+	}
+	readAll(m)
+	checkDSFAudioFormat(t, m)
+}
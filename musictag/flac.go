@@ -0,0 +1,98 @@
+package musictag
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+var ErrNotFLAC = errors.New("Invalid FLAC file")
+
+// FLAC metadata block types (see https://xiph.org/flac/format.html#metadata_block_header)
+const (
+	flacStreamInfoBlock    byte = 0
+	flacPaddingBlock       byte = 1
+	flacApplicationBlock   byte = 2
+	flacSeekTableBlock     byte = 3
+	flacVorbisCommentBlock byte = 4
+	flacCueSheetBlock      byte = 5
+	flacPictureBlock       byte = 6
+)
+
+// reads the 4 byte metadata block header
+// last-metadata-block flag [1 bit]
+// block type               [7 bits]
+// length                   [24 bits]
+func readFLACMetadataBlockHeader(r io.Reader) (last bool, blockType byte, size uint, err error) {
+	b, err := readBytes(r, 4)
+	if err != nil {
+		return false, 0, 0, err
+	}
+
+	last = getBit(b[0], 7)
+	blockType = b[0] & 0x7F
+	size = uint(getInt(b[1:4]))
+	return last, blockType, size, nil
+}
+
+// ReadFLACTags reads the vorbis comment and front cover from the metadata
+// blocks of a FLAC file.
+func ReadFLACTags(r io.ReadSeeker) (Metadata, error) {
+	magic, err := readString(r, 4)
+	if err != nil {
+		return nil, err
+	}
+	if magic != "fLaC" {
+		return nil, ErrNotFLAC
+	}
+
+	m := VorbisMetadata{
+		fileType: FLAC,
+		comments: make(map[string][]string),
+	}
+
+	for last := false; !last; {
+		var blockType byte
+		var size uint
+
+		last, blockType, size, err = readFLACMetadataBlockHeader(r)
+		if err != nil {
+			return nil, err
+		}
+
+		switch blockType {
+		case flacVorbisCommentBlock:
+			b, err := readBytes(r, size)
+			if err != nil {
+				return nil, err
+			}
+
+			var pictures []*Picture
+			m.vendor, m.comments, pictures, err = readVorbisComment(bytes.NewReader(b))
+			if err != nil {
+				return nil, err
+			}
+			m.pictures = append(m.pictures, pictures...)
+
+		case flacPictureBlock:
+			b, err := readBytes(r, size)
+			if err != nil {
+				return nil, err
+			}
+
+			p, err := readPictureBlock(bytes.NewReader(b))
+			if err != nil {
+				return nil, err
+			}
+
+			m.pictures = append(m.pictures, p)
+
+		default:
+			if _, err = r.Seek(int64(size), io.SeekCurrent); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return m, nil
+}
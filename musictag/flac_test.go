@@ -0,0 +1,56 @@
+package musictag
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// readFixture reads the tags of the sample file name of testdata/dir
+func readFixture(t *testing.T, dir, name string) Metadata {
+	t.Helper()
+	f, err := os.Open(filepath.Join("..", "testdata", dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	m, err := ReadFrom(f)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return m
+}
+
+// checkFixture checks the tags written in the samples of testdata/with_tags
+func checkFixture(t *testing.T, m Metadata, fileType FileType, pictures int) {
+	t.Helper()
+	if got := m.GetFileType(); got != fileType {
+		t.Errorf("file type = %v, want %v", got, fileType)
+	}
+	if got := m.GetTitle(); got != "Test Title" {
+		t.Errorf("title = %q, want %q", got, "Test Title")
+	}
+	if got := m.GetArtist(); got != "Test Artist" {
+		t.Errorf("artist = %q, want %q", got, "Test Artist")
+	}
+	if got := m.GetAlbum(); got != "Test Album" {
+		t.Errorf("album = %q, want %q", got, "Test Album")
+	}
+	if got := len(m.GetPictures()); got != pictures {
+		t.Errorf("%d pictures, want %d", got, pictures)
+	}
+}
+
+func TestReadFLACTags(t *testing.T) {
+	m := readFixture(t, "with_tags", "sample.flac")
+	if got := m.GetTagFormat(); got != VorbisComment {
+		t.Errorf("tag format = %v, want %v", got, VorbisComment)
+	}
+	checkFixture(t, m, FLAC, 0)
+
+	m = readFixture(t, "without_tags", "sample.flac")
+	if got := m.GetTitle(); got != "" {
+		t.Errorf("untagged file title = %q", got)
+	}
+}
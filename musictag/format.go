@@ -0,0 +1,222 @@
+package musictag
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+// Format is a container format known by ReadFrom, the container is detected
+// from its first bytes independently of the tags it carries.
+type Format struct {
+	// Sniff returns the file type if the header is the start of a file of
+	// this format, UnknownFileType otherwise. The header holds the first
+	// bytes following the leading ID3v2 tag, it can be shorter than
+	// sniffHeaderSize for small files.
+	Sniff func(header []byte) FileType
+
+	// Read reads the tags of the container, r starts at the container (the
+	// leading ID3v2 tag is not visible). ErrNoTagFound is returned when the
+	// container has no tag.
+	Read func(r io.ReadSeeker) (Metadata, error)
+
+	// ID3v2Main is set when a leading ID3v2 tag is the main tag of the
+	// format (MP3), it is otherwise only used for the missing values.
+	ID3v2Main bool
+}
+
+// number of bytes given to the sniffers
+const sniffHeaderSize = 4 << 10
+
+// formats in detection order, the frame sync of MPEG audio is the weakest
+// signature and is tried last.
+var formats = []*Format{
+	{Sniff: sniffFixed("fLaC", FLAC), Read: ReadFLACTags},
+	{Sniff: sniffOgg, Read: ReadOggTags},
+	{Sniff: sniffMP4, Read: ReadMP4Tags},
+	{Sniff: sniffFixed("DSD ", DSF), Read: ReadDSFTags},
+	{Sniff: sniffRIFF, Read: ReadWAVTags},
+	{Sniff: sniffAIFF, Read: ReadAIFFTags},
+	{Sniff: sniffAPETagged, Read: readTrailingTags, ID3v2Main: true},
+	{Sniff: sniffMPEG, Read: readTrailingTags, ID3v2Main: true},
+}
+
+// files without a known signature only have their trailing tags read
+var unknownFormat = &Format{Read: readTrailingTags, ID3v2Main: true}
+
+// RegisterFormat adds a container format to ReadFrom, the formats
+// registered later are tried first so a built-in format can be overridden.
+func RegisterFormat(f *Format) {
+	formats = append([]*Format{f}, formats...)
+}
+
+// sniffFormat returns the format of the container starting at the current
+// position of r.
+func sniffFormat(r io.Reader) (*Format, FileType, error) {
+	header, err := io.ReadAll(io.LimitReader(r, sniffHeaderSize))
+	if err != nil {
+		return nil, UnknownFileType, err
+	}
+
+	for _, f := range formats {
+		if fileType := f.Sniff(header); fileType != UnknownFileType {
+			return f, fileType, nil
+		}
+	}
+	return unknownFormat, UnknownFileType, nil
+}
+
+// sniffContainer detects the container following the leading ID3v2 tag,
+// start is the offset of the container in the file.
+func sniffContainer(r io.ReadSeeker) (f *Format, fileType FileType, start int64, err error) {
+	start, err = leadingID3v2End(r)
+	if err != nil {
+		return nil, UnknownFileType, 0, err
+	}
+
+	if _, err = r.Seek(start, io.SeekStart); err != nil {
+		return nil, UnknownFileType, 0, err
+	}
+
+	f, fileType, err = sniffFormat(r)
+	if err != nil {
+		return nil, UnknownFileType, 0, err
+	}
+	return f, fileType, start, nil
+}
+
+// DetectFileType returns the type of the container following the leading
+// ID3v2 tag, UnknownFileType if it isn't recognized. Unlike ReadFrom it
+// doesn't need any tag in the file.
+func DetectFileType(r io.ReadSeeker) (FileType, error) {
+	_, fileType, _, err := sniffContainer(r)
+	return fileType, err
+}
+
+func sniffFixed(magic string, fileType FileType) func([]byte) FileType {
+	return func(b []byte) FileType {
+		if bytes.HasPrefix(b, []byte(magic)) {
+			return fileType
+		}
+		return UnknownFileType
+	}
+}
+
+// sniffOgg tells Opus from the other codecs (Vorbis, FLAC) with the first
+// packet of the first page, the page header is 27 bytes long and followed
+// by the segment table.
+func sniffOgg(b []byte) FileType {
+	if !bytes.HasPrefix(b, []byte("OggS")) {
+		return UnknownFileType
+	}
+
+	if len(b) > 26 {
+		packet := b[min(len(b), 27+int(b[26])):]
+		if bytes.HasPrefix(packet, []byte("OpusHead")) {
+			return OPUS
+		}
+	}
+	return OGG
+}
+
+func sniffMP4(b []byte) FileType {
+	if len(b) >= 8 && string(b[4:8]) == "ftyp" {
+		return MP4
+	}
+	return UnknownFileType
+}
+
+func sniffRIFF(b []byte) FileType {
+	if len(b) >= 12 && string(b[0:4]) == "RIFF" && string(b[8:12]) == "WAVE" {
+		return WAV
+	}
+	return UnknownFileType
+}
+
+func sniffAIFF(b []byte) FileType {
+	if len(b) >= 12 && string(b[0:4]) == "FORM" && (string(b[8:12]) == "AIFF" || string(b[8:12]) == "AIFC") {
+		return AIFF
+	}
+	return UnknownFileType
+}
+
+// sniffAPETagged detects the formats which are tagged with APEv2 tags at
+// the end of the file.
+func sniffAPETagged(b []byte) FileType {
+	switch {
+	case bytes.HasPrefix(b, []byte("MAC ")):
+		return APE
+	case bytes.HasPrefix(b, []byte("wvpk")):
+		return WavPack
+	case bytes.HasPrefix(b, []byte("MPCK")), bytes.HasPrefix(b, []byte("MP+")):
+		return Musepack
+	}
+	return UnknownFileType
+}
+
+// sniffMPEG looks for two consecutive MPEG audio frames, the audio can be
+// preceded by padding or junk.
+func sniffMPEG(b []byte) FileType {
+	if _, h := findMPEGFrame(b); h != nil {
+		return MP3
+	}
+	return UnknownFileType
+}
+
+// readTrailingTags reads the APE and ID3v1 tags at the end of the file,
+// used for MP3 and APE tagged (Monkey's Audio, WavPack, Musepack) files.
+func readTrailingTags(r io.ReadSeeker) (Metadata, error) {
+	var tags MultiMetadata
+
+	m, err := ReadAPETags(r)
+	if err == nil {
+		tags = append(tags, m)
+	} else if err != ErrNoAPETag {
+		return nil, err
+	}
+
+	m, err = ReadID3v1Tags(r)
+	if err == nil {
+		tags = append(tags, m)
+	} else if err != ErrNotID3V1 {
+		return nil, err
+	}
+
+	switch len(tags) {
+	case 0:
+		return nil, ErrNoTagFound
+	case 1:
+		return tags[0], nil
+	}
+	return tags, nil
+}
+
+// containerReader hides the leading ID3v2 tag from the format readers, the
+// offset 0 is the start of the container.
+type containerReader struct {
+	r     io.ReadSeeker
+	start int64
+}
+
+func (c *containerReader) Read(p []byte) (int, error) { return c.r.Read(p) }
+
+func (c *containerReader) Seek(offset int64, whence int) (int64, error) {
+	if whence == io.SeekStart {
+		if offset < 0 {
+			return 0, errors.New("containerReader.Seek: negative position")
+		}
+		offset += c.start
+	}
+
+	n, err := c.r.Seek(offset, whence)
+	return n - c.start, err
+}
+
+// fileMetadata is the result of ReadFrom, the tags found in the file and the
+// detected file type.
+type fileMetadata struct {
+	Metadata
+	fileType FileType
+}
+
+func (m fileMetadata) GetFileType() FileType { return m.fileType }
@@ -0,0 +1,120 @@
+package musictag
+
+import (
+	"fmt"
+	"io"
+)
+
+// FrameRef is the location in the file of an ID3v2 frame whose body was
+// skipped by ReadFrom with WithoutPictures. The pictures are then loaded on
+// demand with LoadPicture.
+type FrameRef struct {
+	Name   string // APIC, PIC or GEOB
+	Offset int64  // offset of the frame body in the file
+	Size   int64  // size of the frame body in the file
+
+	unsynchronised bool
+	compressed     bool
+	dataLength     uint // size of the decompressed body, 0 if unknown
+}
+
+// IsPicture reports whether the frame is an attached picture.
+func (ref FrameRef) IsPicture() bool {
+	return ref.Name == "APIC" || ref.Name == "PIC"
+}
+
+// isBinaryFrame reports whether the frame holds binary data which can be big
+// (pictures and encapsulated objects), their bodies are skipped by
+// WithoutPictures.
+func isBinaryFrame(name string) bool {
+	switch name {
+	case "APIC", "PIC", "GEOB", "GEO":
+		return true
+	}
+	return false
+}
+
+// skipFrameBody seeks past the body of a frame and returns its location,
+// size is the size of the body following the additional header bytes.
+func skipFrameBody(s io.ReadSeeker, name string, size uint, dataLength uint, flags *id3v2FrameFlags, h *ID3v2Header) (FrameRef, error) {
+	offset, err := s.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return FrameRef{}, err
+	}
+	if remaining, ok := remainingBytes(s); ok && int64(size) > remaining {
+		return FrameRef{}, ErrTruncated
+	}
+	if _, err = s.Seek(int64(size), io.SeekCurrent); err != nil {
+		return FrameRef{}, err
+	}
+
+	ref := FrameRef{Name: name, Offset: offset, Size: int64(size)}
+	if flags != nil {
+		ref.unsynchronised = flags.Unsynchronisation || (h.Version == ID3v2_4 && h.Unsynchronisation)
+		ref.compressed = flags.Compression
+		ref.dataLength = dataLength
+	}
+	return ref, nil
+}
+
+// SkippedFrames returns the frames whose body was skipped when reading the
+// tags with WithoutPictures, in the order of the tags.
+func SkippedFrames(m Metadata) []FrameRef {
+	switch m := m.(type) {
+	case ID3v2Metadata:
+		var refs []FrameRef
+		for _, name := range []string{"APIC", "PIC", "GEOB", "GEO"} {
+			for _, v := range m.getAll(name) {
+				if ref, ok := v.(FrameRef); ok {
+					refs = append(refs, ref)
+				}
+			}
+		}
+		return refs
+
+	case MultiMetadata:
+		var refs []FrameRef
+		for _, t := range m {
+			refs = append(refs, SkippedFrames(t)...)
+		}
+		return refs
+
+	case fileMetadata:
+		return SkippedFrames(m.Metadata)
+	}
+	return nil
+}
+
+// LoadPicture reads the picture of a frame skipped by ReadFrom with
+// WithoutPictures, r is the file the tags were read from.
+func LoadPicture(r io.ReadSeeker, ref FrameRef) (*Picture, error) {
+	if !ref.IsPicture() {
+		return nil, fmt.Errorf("frame %q is not a picture", ref.Name)
+	}
+	if ref.Offset < 0 || ref.Size < 0 {
+		return nil, fmt.Errorf("invalid location of frame %q", ref.Name)
+	}
+
+	if _, err := r.Seek(ref.Offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	b, err := readBytes(r, uint(ref.Size))
+	if err != nil {
+		return nil, err
+	}
+
+	if ref.unsynchronised {
+		b = removeUnsynchronisation(b)
+	}
+	if ref.compressed {
+		b, err = decompressFrame(b, ref.dataLength)
+		if err != nil {
+			return nil, fmt.Errorf("could not decompress %q: %v", ref.Name, err)
+		}
+	}
+
+	if ref.Name == "PIC" {
+		return readPICFrame(b)
+	}
+	return readAPICFrame(b)
+}
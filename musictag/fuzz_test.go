@@ -0,0 +1,92 @@
+package musictag
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// addSeeds adds the sample files of testdata to the corpus of f
+func addSeeds(f *testing.F) {
+	for _, dir := range []string{"with_tags", "without_tags"} {
+		paths, err := filepath.Glob(filepath.Join("..", "testdata", dir, "*"))
+		if err != nil {
+			f.Fatal(err)
+		}
+		for _, path := range paths {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				f.Fatal(err)
+			}
+			f.Add(data)
+		}
+	}
+}
+
+// readAll calls the getters of m, they must not panic on what the reader
+// accepted
+func readAll(m Metadata) {
+	m.GetTagFormat()
+	m.GetFileType()
+	m.GetTitle()
+	m.GetArtist()
+	m.GetArtists()
+	m.GetAlbum()
+	m.GetAlbumArtist()
+	m.GetYear()
+	m.GetGenre()
+	m.GetTrack()
+	m.GetDisc()
+	m.GetComposer()
+	m.GetBPM()
+	m.GetLyrics()
+	m.GetSyncedLyrics()
+	m.GetCustom("MusicBrainz Album Id")
+	m.GetRating()
+	m.GetPlayCount()
+	m.GetChapters()
+	m.GetAlbumArt()
+	m.GetPictures()
+	ReadReplayGain(m)
+}
+
+func FuzzReadFrom(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		m, err := ReadFrom(bytes.NewReader(data))
+		if err == nil {
+			readAll(m)
+		}
+
+		m, err = ReadFrom(bytes.NewReader(data), WithoutPictures())
+		if err == nil {
+			readAll(m)
+			for _, ref := range SkippedFrames(m) {
+				LoadPicture(bytes.NewReader(data), ref)
+			}
+		}
+
+		AudioHash(bytes.NewReader(data))
+	})
+}
+
+func FuzzReadID3v2Tags(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		m, err := ReadID3v2Tags(bytes.NewReader(data))
+		if err == nil {
+			readAll(m)
+		}
+	})
+}
+
+func FuzzReadID3v1Tags(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		m, err := ReadID3v1Tags(bytes.NewReader(data))
+		if err == nil {
+			readAll(m)
+		}
+	})
+}
@@ -96,8 +96,10 @@ var id3v22Frames = map[string]string{
 var id3v23Frames = map[string]string{
 	"AENC": "Audio encryption]",
 	"APIC": "Attached picture",
+	"CHAP": "Chapter",
 	"COMM": "Comments",
 	"COMR": "Commercial frame",
+	"CTOC": "Table of contents",
 	"ENCR": "Encryption method registration",
 	"EQUA": "Equalization",
 	"ETCO": "Event timing codes",
@@ -178,9 +180,11 @@ var id3v24Frames = map[string]string{
 	"AENC": "Audio encryption",
 	"APIC": "Attached picture",
 	"ASPI": "Audio seek point index",
+	"CHAP": "Chapter",
 
 	"COMM": "Comments",
 	"COMR": "Commercial frame",
+	"CTOC": "Table of contents",
 
 	"ENCR": "Encryption method registration",
 	"EQU2": "Equalisation (2)",
@@ -291,16 +295,25 @@ func validID3Frame(version TagFormat, name string) bool {
 	return ok
 }
 
-func readTFrame(b []byte) (string, error) {
+// readTFrame reads a text information frame, ID3v2.4 allows multiple values
+// separated by a null character (e.g. one artist per value).
+func readTFrame(b []byte) ([]string, error) {
 	if len(b) == 0 {
-		return "", nil
+		return nil, nil
 	}
 
 	txt, err := decodeText(b[0], b[1:])
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	// the text can be terminated, a trailing empty value is not a value
+	values := strings.Split(strings.TrimRight(txt, string(singleZero)), string(singleZero))
+	for i, v := range values {
+		// with UTF-16 every value starts with its own byte order marker
+		values[i] = strings.TrimPrefix(v, "\ufeff")
 	}
-	return strings.Join(strings.Split(txt, string(singleZero)), ""), nil
+	return values, nil
 }
 
 const (
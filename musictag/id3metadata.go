@@ -1,6 +1,7 @@
 package musictag
 
 import (
+	"math"
 	"regexp"
 	"strconv"
 	"strings"
@@ -30,35 +31,54 @@ func (f frameNames) Name(s string, fm TagFormat) string {
 }
 
 var frames = frameNames(map[string][2]string{
-	"title":        {"TT2", "TIT2"},
-	"artist":       {"TP1", "TPE1"},
-	"album":        {"TAL", "TALB"},
-	"album_artist": {"TP2", "TPE2"},
-	"composer":     {"TCM", "TCOM"},
-	"year":         {"TYE", "TYER"},
-	"track":        {"TRK", "TRCK"},
-	"genre":        {"TCO", "TCON"},
-	"picture":      {"PIC", "APIC"},
-	"comment":      {"COM", "COMM"},
+	"title":         {"TT2", "TIT2"},
+	"artist":        {"TP1", "TPE1"},
+	"album":         {"TAL", "TALB"},
+	"album_artist":  {"TP2", "TPE2"},
+	"composer":      {"TCM", "TCOM"},
+	"year":          {"TYE", "TYER"},
+	"track":         {"TRK", "TRCK"},
+	"disc":          {"TPA", "TPOS"},
+	"bpm":           {"TBP", "TBPM"},
+	"genre":         {"TCO", "TCON"},
+	"picture":       {"PIC", "APIC"},
+	"comment":       {"COM", "COMM"},
+	"lyrics":        {"ULT", "USLT"},
+	"custom":        {"TXX", "TXXX"},
+	"synced_lyrics": {"SLT", "SYLT"},
+	"popularimeter": {"POP", "POPM"},
+	"play_counter":  {"CNT", "PCNT"},
 })
 
 // metadataID3v2 is the implementation of Metadata used for ID3v2 tags.
 type ID3v2Metadata struct {
 	header *ID3v2Header
 	frames map[string]any
+	latin1 map[string]bool // text frames encoded with ISO-8859-1
 }
 
+// getString returns the value of a text frame, the values of a multi-valued
+// frame are joined with multiValueSeparator.
 func (m ID3v2Metadata) getString(k string) string {
-	v, ok := m.frames[k]
-	if !ok {
-		return ""
+	return strings.Join(m.getStrings(k), multiValueSeparator)
+}
+
+// getStrings returns the values of a text frame.
+func (m ID3v2Metadata) getStrings(k string) []string {
+	switch v := m.frames[k].(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
 	}
-	return v.(string)
+	return nil
 }
 
 func (m ID3v2Metadata) GetTagFormat() TagFormat { return m.header.Version }
-func (ID3v2Metadata) GetFileType() FileType     { return MP3 }
-func (m ID3v2Metadata) GetTitle() string        { return m.getString(frames.Name("title", m.GetTagFormat())) }
+
+// the tag can be carried by several containers, ReadFrom detects the file type
+func (ID3v2Metadata) GetFileType() FileType { return UnknownFileType }
+func (m ID3v2Metadata) GetTitle() string    { return m.getString(frames.Name("title", m.GetTagFormat())) }
 func (m ID3v2Metadata) GetArtist() string {
 	return m.getString(frames.Name("artist", m.GetTagFormat()))
 }
@@ -75,16 +95,163 @@ func (m ID3v2Metadata) GetGenre() string {
 	return id3v2genre(m.getString(frames.Name("genre", m.GetTagFormat())))
 }
 
+func (m ID3v2Metadata) GetComposer() string {
+	return m.getString(frames.Name("composer", m.GetTagFormat()))
+}
+
+func (m ID3v2Metadata) GetTrack() (int, int) {
+	return parseNumberPair(m.getString(frames.Name("track", m.GetTagFormat())))
+}
+
+func (m ID3v2Metadata) GetDisc() (int, int) {
+	return parseNumberPair(m.getString(frames.Name("disc", m.GetTagFormat())))
+}
+
+func (m ID3v2Metadata) GetBPM() int {
+	return parseBPM(m.getString(frames.Name("bpm", m.GetTagFormat())))
+}
+
+// GetComment returns the first COMM frame without description, iTunes
+// stores some values (e.g. iTunNORM) in comments with a description.
 func (m ID3v2Metadata) GetComment() string {
-	return m.getString(frames.Name("comment", m.GetTagFormat()))
+	for _, v := range m.getAll(frames.Name("comment", m.GetTagFormat())) {
+		if c, ok := v.(*Comm); ok && c.Description == "" {
+			return c.Text
+		}
+	}
+	return ""
 }
 
-func (m ID3v2Metadata) GetAlbumArt() *Picture {
-	v, ok := m.frames[frames.Name("picture", m.GetTagFormat())]
+func (m ID3v2Metadata) GetLyrics() string {
+	for _, v := range m.getAll(frames.Name("lyrics", m.GetTagFormat())) {
+		if c, ok := v.(*Comm); ok && c.Text != "" {
+			return c.Text
+		}
+	}
+	return ""
+}
+
+// GetCustom returns the value of the TXXX frame with the given description,
+// or of the COMM frame as iTunes stores some values (e.g. iTunNORM) in comments.
+func (m ID3v2Metadata) GetCustom(key string) string {
+	for _, name := range []string{"custom", "comment"} {
+		for _, v := range m.getAll(frames.Name(name, m.GetTagFormat())) {
+			if c, ok := v.(*Comm); ok && matchCustomKey(c.Description, key) {
+				return c.Text
+			}
+		}
+	}
+	return ""
+}
+
+// GetArtists returns the artists of the TXXX ARTISTS frame, or the values of
+// the artist frame.
+func (m ID3v2Metadata) GetArtists() []string {
+	if artists := m.GetCustom(customArtists); artists != "" {
+		return splitNullSeparated(artists)
+	}
+	return m.getStrings(frames.Name("artist", m.GetTagFormat()))
+}
+
+// GetRating returns the first rating of the POPM frames in stars (1-5), 0 if
+// the track is not rated.
+func (m ID3v2Metadata) GetRating() int {
+	for _, p := range m.GetPopularimeters() {
+		if p.Rating != 0 {
+			return popmStars(p.Rating)
+		}
+	}
+	return 0
+}
+
+// GetPlayCount returns the PCNT counter, or the highest counter of the POPM
+// frames.
+func (m ID3v2Metadata) GetPlayCount() int {
+	if v, ok := m.frames[frames.Name("play_counter", m.GetTagFormat())].(uint64); ok {
+		return int(min(v, math.MaxInt32))
+	}
+
+	var count uint64
+	for _, p := range m.GetPopularimeters() {
+		count = max(count, p.Counter)
+	}
+	return int(min(count, math.MaxInt32))
+}
+
+// GetPopularimeters returns the POPM frames, one for every user.
+func (m ID3v2Metadata) GetPopularimeters() []*Popularimeter {
+	var popms []*Popularimeter
+	for _, v := range m.getAll(frames.Name("popularimeter", m.GetTagFormat())) {
+		if p, ok := v.(*Popularimeter); ok {
+			popms = append(popms, p)
+		}
+	}
+	return popms
+}
+
+// GetChapters returns the CHAP frames in the order of the top-level CTOC
+// frame, or sorted by start time.
+func (m ID3v2Metadata) GetChapters() []Chapter {
+	var chapters []*id3Chapter
+	for _, v := range m.getAll("CHAP") {
+		if c, ok := v.(*id3Chapter); ok {
+			chapters = append(chapters, c)
+		}
+	}
+	if len(chapters) == 0 {
+		return nil
+	}
+
+	var tocs []*id3TableOfContents
+	for _, v := range m.getAll("CTOC") {
+		if toc, ok := v.(*id3TableOfContents); ok {
+			tocs = append(tocs, toc)
+		}
+	}
+	return orderID3Chapters(chapters, tocs)
+}
+
+// GetSyncedLyrics returns the first SYLT frame, or the USLT frame if it is
+// in the LRC format.
+func (m ID3v2Metadata) GetSyncedLyrics() []LyricLine {
+	for _, v := range m.getAll(frames.Name("synced_lyrics", m.GetTagFormat())) {
+		if lines, ok := v.([]LyricLine); ok && len(lines) > 0 {
+			return lines
+		}
+	}
+	return parseEmbeddedLRC(m.GetLyrics())
+}
+
+// getAll returns the values of all the frames with the given name, the
+// repeated frames are stored as name, name_0, name_1, ...
+func (m ID3v2Metadata) getAll(name string) []any {
+	v, ok := m.frames[name]
 	if !ok {
 		return nil
 	}
-	return v.(*Picture)
+
+	values := []any{v}
+	for i := 0; ; i++ {
+		v, ok := m.frames[name+"_"+strconv.Itoa(i)]
+		if !ok {
+			return values
+		}
+		values = append(values, v)
+	}
+}
+
+func (m ID3v2Metadata) GetAlbumArt() *Picture {
+	return frontCover(m.GetPictures())
+}
+
+func (m ID3v2Metadata) GetPictures() []*Picture {
+	var pictures []*Picture
+	for _, v := range m.getAll(frames.Name("picture", m.GetTagFormat())) {
+		if p, ok := v.(*Picture); ok {
+			pictures = append(pictures, p)
+		}
+	}
+	return pictures
 }
 
 func (m ID3v2Metadata) GetYear() int {
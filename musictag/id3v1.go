@@ -9,19 +9,45 @@ import (
 
 type ID3v1Metadata map[string]any
 
-func (ID3v1Metadata) GetTagFormat() TagFormat  { return ID3v1 }
-func (ID3v1Metadata) GetFileType() FileType    { return MP3 }
-func (m ID3v1Metadata) GetTitle() string       { return m["title"].(string) }
-func (m ID3v1Metadata) GetArtist() string      { return m["artist"].(string) }
-func (m ID3v1Metadata) GetAlbum() string       { return m["album"].(string) }
-func (m ID3v1Metadata) GetAlbumArtist() string { return "" }
-func (m ID3v1Metadata) GetGenre() string       { return m["genre"].(string) }
-func (m ID3v1Metadata) GetComment() string     { return m["comment"].(string) }
-func (m ID3v1Metadata) GetAlbumArt() *Picture  { return nil }
+// getString returns the field k, "" if it is missing
+func (m ID3v1Metadata) getString(k string) string {
+	s, _ := m[k].(string)
+	return s
+}
+
+func (ID3v1Metadata) GetTagFormat() TagFormat { return ID3v1 }
+
+// the tag can be carried by several containers, ReadFrom detects the file type
+func (ID3v1Metadata) GetFileType() FileType     { return UnknownFileType }
+func (m ID3v1Metadata) GetTitle() string        { return m.getString("title") }
+func (m ID3v1Metadata) GetArtist() string       { return m.getString("artist") }
+func (m ID3v1Metadata) GetAlbum() string        { return m.getString("album") }
+func (m ID3v1Metadata) GetAlbumArtist() string  { return "" }
+func (m ID3v1Metadata) GetGenre() string        { return m.getString("genre") }
+func (m ID3v1Metadata) GetComment() string      { return m.getString("comment") }
+func (m ID3v1Metadata) GetAlbumArt() *Picture   { return nil }
+func (m ID3v1Metadata) GetPictures() []*Picture { return nil }
+func (m ID3v1Metadata) GetDisc() (int, int)     { return 0, 0 }
+func (m ID3v1Metadata) GetComposer() string     { return "" }
+func (m ID3v1Metadata) GetBPM() int             { return 0 }
+func (m ID3v1Metadata) GetLyrics() string       { return "" }
+func (m ID3v1Metadata) GetCustom(string) string { return "" }
+
+func (m ID3v1Metadata) GetArtists() []string { return singleValue(m.GetArtist()) }
+
+func (m ID3v1Metadata) GetSyncedLyrics() []LyricLine { return nil }
+func (m ID3v1Metadata) GetChapters() []Chapter       { return nil }
+func (m ID3v1Metadata) GetRating() int               { return 0 }
+func (m ID3v1Metadata) GetPlayCount() int            { return 0 }
+
+// GetTrack returns the ID3v1.1 track number, ID3v1 has no total
+func (m ID3v1Metadata) GetTrack() (int, int) {
+	track, _ := m["track"].(int)
+	return track, 0
+}
 
 func (m ID3v1Metadata) GetYear() int {
-	year := m["year"].(string)
-	n, err := strconv.Atoi(year)
+	n, err := strconv.Atoi(m.getString("year"))
 	if err != nil {
 		return 0
 	}
@@ -65,12 +91,19 @@ func ReadID3v1Tags(filePointer io.ReadSeeker) (Metadata, error) {
 	}
 	year = trimString(year)
 
-	//TODO: modify comment if needed
 	comment, err := readString(filePointer, 30)
 	if err != nil {
 		return nil, err
 	}
 
+	// ID3v1.1 stores the track number in the last byte of the comment
+	// when the byte before it is zero
+	var track int
+	if comment[28] == 0 && comment[29] != 0 {
+		track = int(comment[29])
+		comment = comment[:28]
+	}
+
 	var genre string
 	genreId, err := readBytes(filePointer, 1)
 	if err != nil {
@@ -82,12 +115,13 @@ func ReadID3v1Tags(filePointer io.ReadSeeker) (Metadata, error) {
 	}
 
 	var id3v1Metadata = make(map[string]any)
-	id3v1Metadata["title"] = trimString(title)
-	id3v1Metadata["artist"] = trimString(artist)
-	id3v1Metadata["album"] = trimString(album)
+	id3v1Metadata["title"] = decodeISO8859([]byte(trimString(title)))
+	id3v1Metadata["artist"] = decodeISO8859([]byte(trimString(artist)))
+	id3v1Metadata["album"] = decodeISO8859([]byte(trimString(album)))
 	id3v1Metadata["genre"] = genre
 	id3v1Metadata["year"] = trimString(year)
-	id3v1Metadata["comment"] = trimString(comment)
+	id3v1Metadata["comment"] = decodeISO8859([]byte(trimString(comment)))
+	id3v1Metadata["track"] = track
 
 	return ID3v1Metadata(id3v1Metadata), nil
 }
@@ -1,6 +1,8 @@
 package musictag
 
 import (
+	"bytes"
+	"compress/zlib"
 	"errors"
 	"fmt"
 	"io"
@@ -8,16 +10,51 @@ import (
 )
 
 func ReadID3v2Tags(r io.ReadSeeker) (Metadata, error) {
-	h, offset, err := readID3v2Header(r)
+	h, offset, fr, err := openID3v2Frames(r)
 	if err != nil {
 		return nil, err
 	}
 
-	f, err := readID3v2Frames(r, offset, h)
+	m, err := readID3v2Tag(r, h, offset, fr, false)
 	if err != nil {
 		return nil, err
 	}
-	return ID3v2Metadata{header: h, frames: f}, nil
+	return m, nil
+}
+
+// readID3v2Tag reads the frames of the tag opened by openID3v2Frames, the
+// pictures are skipped only if the frames are read from the file itself.
+func readID3v2Tag(r io.Reader, h *ID3v2Header, offset uint, fr io.Reader, skipPictures bool) (ID3v2Metadata, error) {
+	f, latin1, err := readID3v2Frames(fr, offset, h, skipPictures && fr == r)
+	if err != nil {
+		return ID3v2Metadata{}, err
+	}
+	return ID3v2Metadata{header: h, frames: f, latin1: latin1}, nil
+}
+
+// openID3v2Frames reads the tag header and returns the reader of the frames.
+// ID3v2.{2,3} unsynchronisation is applied to the whole tag, the frames are
+// then read from the resynchronised data and the returned header has its size.
+func openID3v2Frames(r io.Reader) (h *ID3v2Header, offset uint, fr io.Reader, err error) {
+	h, offset, err = readID3v2Header(r)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	fr = r
+	if h.Unsynchronisation && h.Version != ID3v2_4 && h.Size+10 > offset {
+		b, err := readBytes(r, h.Size+10-offset)
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		b = removeUnsynchronisation(b)
+		fr = bytes.NewReader(b)
+
+		resynced := *h
+		resynced.Size = offset + uint(len(b)) - 10
+		h = &resynced
+	}
+	return h, offset, fr, nil
 }
 
 // Represent id3v2 header tag usualy contents in first 10bytes
@@ -26,6 +63,7 @@ type ID3v2Header struct {
 	Unsynchronisation bool
 	ExtendedHeader    bool
 	Experimental      bool
+	Footer            bool // ID3v2.4 only, the tag ends with a "3DI" footer
 	Size              uint
 }
 
@@ -59,8 +97,9 @@ func readID3v2Header(r io.Reader) (header *ID3v2Header, offset uint, err error)
 	header = &ID3v2Header{
 		Version:           format,
 		Unsynchronisation: getBit(b[2], 7),
-		ExtendedHeader:    getBit(b[2], 5),
+		ExtendedHeader:    getBit(b[2], 6),
 		Experimental:      getBit(b[2], 5),
+		Footer:            format == ID3v2_4 && getBit(b[2], 4),
 		Size:              uint(get7BitChunkedInt(b[3:7])),
 	}
 
@@ -86,7 +125,11 @@ func readID3v2Header(r io.Reader) (header *ID3v2Header, offset uint, err error)
 				return nil, 0, fmt.Errorf("expected to read 4 bytes (ID3v24 extended header len): %v", err)
 			}
 			// skip header, size is synchsafe int including len bytes
-			extendedHeaderSize := uint(get7BitChunkedInt(b)) - 4
+			extendedHeaderSize := uint(get7BitChunkedInt(b))
+			if extendedHeaderSize < 4 {
+				return nil, 0, fmt.Errorf("%w: ID3v24 extended header size %d", ErrMalformedTag, extendedHeaderSize)
+			}
+			extendedHeaderSize -= 4
 			_, err = readBytes(r, extendedHeaderSize)
 			if err != nil {
 				return nil, 0, fmt.Errorf("expected to read %d bytes (ID3v24 skip extended header): %v", extendedHeaderSize, err)
@@ -196,10 +239,13 @@ func readID3v2_4FrameHeader(r io.Reader) (name string, size uint, headerSize uin
 	return
 }
 
-// readID3v2Frames reads ID3v2 frames from the given reader using the ID3v2Header.
-func readID3v2Frames(r io.Reader, offset uint, h *ID3v2Header) (map[string]any, error) {
-	result := make(map[string]any)
-
+// walkID3v2Frames reads the ID3v2 frames from the given reader using the
+// ID3v2Header and calls fn with the body of every frame, once the frame
+// unsynchronisation and compression are removed. Encrypted frames are skipped.
+// When skipped is not nil and r is an io.ReadSeeker, the bodies of the binary
+// frames (see isBinaryFrame) are not read, skipped is called with their
+// location instead.
+func walkID3v2Frames(r io.Reader, offset uint, h *ID3v2Header, skipped func(ref FrameRef) error, fn func(name string, b []byte) error) error {
 	for offset < h.Size {
 		var err error
 		var name string
@@ -213,7 +259,7 @@ func readID3v2Frames(r io.Reader, offset uint, h *ID3v2Header) (map[string]any,
 		case ID3v2_3:
 			name, size, headerSize, err = readID3v2_3FrameHeader(r)
 			if err != nil {
-				return nil, err
+				return err
 			}
 			flags, err = readID3v23FrameFlags(r)
 			headerSize += 2
@@ -221,14 +267,14 @@ func readID3v2Frames(r io.Reader, offset uint, h *ID3v2Header) (map[string]any,
 		case ID3v2_4:
 			name, size, headerSize, err = readID3v2_4FrameHeader(r)
 			if err != nil {
-				return nil, err
+				return err
 			}
 			flags, err = readID3v24FrameFlags(r)
 			headerSize += 2
 		}
 
 		if err != nil {
-			return nil, err
+			return err
 		}
 
 		// FIXME: Do we still need this?
@@ -245,101 +291,276 @@ func readID3v2Frames(r io.Reader, offset uint, h *ID3v2Header) (map[string]any,
 			break
 		}
 
+		// size of the frame data once decompressed, 0 if unknown
+		var dataLength uint
 		if flags != nil {
-			if flags.Compression {
-				switch h.Version {
-				case ID3v2_3:
-					// No data length indicator defined.
-					if _, err := read7BitChunkedUint(r, 4); err != nil { // read 4
-						return nil, err
-					}
-					size -= 4
-
-				case ID3v2_4:
-					// Must have a data length indicator (to give the size) if compression is enabled.
-					if !flags.DataLengthIndicator {
-						return nil, errors.New("compression without data length indicator")
-					}
-
-				default:
-					return nil, fmt.Errorf("unsupported compression flag used in %v", h.Version)
+			// the additional header bytes follow the frame header in the order of the flags
+			// (see http://id3.org/id3v2.3.0 sec 3.3.1 and http://id3.org/id3v2.4.0-structure sec 4.1.2)
+			var extra uint
+			switch h.Version {
+			case ID3v2_3:
+				if flags.Compression {
+					extra += 4 // decompressed size
 				}
-			}
-
-			if flags.DataLengthIndicator {
-				if h.Version == ID3v2_3 {
-					return nil, fmt.Errorf("data length indicator set but not defined for %v", ID3v2_3)
+				if flags.Encryption {
+					extra++ // encryption method
+				}
+				if flags.GroupIdentity {
+					extra++ // group identifier
 				}
 
-				size, err = read7BitChunkedUint(r, 4)
-				if err != nil { // read 4
-					return nil, err
+			case ID3v2_4:
+				if flags.GroupIdentity {
+					extra++
+				}
+				if flags.Encryption {
+					extra++
+				}
+				if flags.DataLengthIndicator {
+					extra += 4
+				}
+				// Must have a data length indicator (to give the size) if compression is enabled.
+				if flags.Compression && !flags.DataLengthIndicator {
+					return errors.New("compression without data length indicator")
 				}
 			}
 
-			if flags.Encryption {
-				_, err = readBytes(r, 1) // read 1 byte of encryption method
-				if err != nil {
-					return nil, err
-				}
-				size--
+			if extra > size {
+				return fmt.Errorf("invalid size %d for frame %q with flags", size, name)
+			}
+
+			b, err := readBytes(r, extra)
+			if err != nil {
+				return err
+			}
+			size -= extra
+
+			switch {
+			case h.Version == ID3v2_3 && flags.Compression:
+				dataLength = uint(getInt(b[0:4]))
+			case h.Version == ID3v2_4 && flags.DataLengthIndicator:
+				dataLength = uint(get7BitChunkedInt(b[extra-4 : extra]))
 			}
 		}
 
+		if s, ok := r.(io.ReadSeeker); ok && skipped != nil && isBinaryFrame(name) {
+			ref, err := skipFrameBody(s, name, size, dataLength, flags, h)
+			if err != nil {
+				return err
+			}
+			if flags != nil && flags.Encryption {
+				continue
+			}
+			if err := skipped(ref); err != nil {
+				return err
+			}
+			continue
+		}
+
 		b, err := readBytes(r, size)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		// There can be multiple tag with the same name. Append a number to the
-		// name if there is more than one.
-		rawName := name
-		if _, ok := result[rawName]; ok {
-			for i := 0; ok; i++ {
-				rawName = name + "_" + strconv.Itoa(i)
-				_, ok = result[rawName]
+		if flags != nil {
+			// encrypted frames can't be decoded
+			if flags.Encryption {
+				continue
+			}
+
+			// ID3v2.4 unsynchronisation is applied per frame, the tag flag
+			// means all the frames are unsynchronised.
+			if flags.Unsynchronisation || (h.Version == ID3v2_4 && h.Unsynchronisation) {
+				b = removeUnsynchronisation(b)
 			}
+
+			if flags.Compression {
+				b, err = decompressFrame(b, dataLength)
+				if err != nil {
+					return fmt.Errorf("could not decompress %q: %v", name, err)
+				}
+			}
+		}
+
+		if err := fn(name, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readID3v2Frames reads ID3v2 frames from the given reader using the ID3v2Header.
+// latin1 holds the names of the text frames encoded with ISO-8859-1. With
+// skipPictures the binary frames are stored as a FrameRef, r must then be the
+// file so their offsets are file offsets.
+func readID3v2Frames(r io.Reader, offset uint, h *ID3v2Header, skipPictures bool) (result map[string]any, latin1 map[string]bool, err error) {
+	result = make(map[string]any)
+	latin1 = make(map[string]bool)
+
+	var skipped func(ref FrameRef) error
+	if skipPictures {
+		skipped = func(ref FrameRef) error {
+			result[frameKey(result, ref.Name)] = ref
+			return nil
+		}
+	}
+
+	err = walkID3v2Frames(r, offset, h, skipped, func(name string, b []byte) error {
+		rawName := frameKey(result, name)
+
+		if len(b) > 0 && b[0] == encodingISO8859 && isTextFrame(name) {
+			latin1[rawName] = true
 		}
 
 		switch {
+		case name == "TXXX" || name == "TXX":
+			t, err := readTextWithDescrFrame(b, false, true)
+			if err != nil {
+				return fmt.Errorf("could not read %q (%q): %v", name, rawName, err)
+			}
+			result[rawName] = t
+
 		case name[0] == 'T':
-			txt, err := readTFrame(b)
+			values, err := readTFrame(b)
 			if err != nil {
-				return nil, err
+				return err
+			}
+			switch len(values) {
+			case 0:
+				result[rawName] = ""
+			case 1:
+				result[rawName] = values[0]
+			default:
+				result[rawName] = values
 			}
-			result[rawName] = txt
 
 		case name == "COMM" || name == "COM" || name == "USLT" || name == "ULT":
 			t, err := readTextWithDescrFrame(b, true, true) // both lang and enc
 			if err != nil {
-				return nil, fmt.Errorf("could not read %q (%q): %v", name, rawName, err)
+				return fmt.Errorf("could not read %q (%q): %v", name, rawName, err)
 			}
 			result[rawName] = t
 
+		case name == "SYLT" || name == "SLT":
+			lines, err := readSYLTFrame(b)
+			if err == errUnsupportedSYLTFormat {
+				return nil
+			} else if err != nil {
+				return fmt.Errorf("could not read %q (%q): %v", name, rawName, err)
+			}
+			result[rawName] = lines
+
+		case name == "CHAP":
+			c, err := readCHAPFrame(b, h)
+			if err != nil {
+				return fmt.Errorf("could not read %q (%q): %v", name, rawName, err)
+			}
+			result[rawName] = c
+
+		case name == "CTOC":
+			toc, err := readCTOCFrame(b)
+			if err != nil {
+				return fmt.Errorf("could not read %q (%q): %v", name, rawName, err)
+			}
+			result[rawName] = toc
+
+		case name == "POPM" || name == "POP":
+			p, err := readPOPMFrame(b)
+			if err != nil {
+				return fmt.Errorf("could not read %q (%q): %v", name, rawName, err)
+			}
+			result[rawName] = p
+
+		case name == "PCNT" || name == "CNT":
+			result[rawName] = readCounter(b)
+
+		case name == "SEEK":
+			// minimum offset to the next tag from the end of this tag
+			if len(b) >= 4 {
+				result[rawName] = int64(getInt(b[0:4]))
+			}
+
 		case name == "APIC":
 			p, err := readAPICFrame(b)
 			if err != nil {
-				return nil, err
-			}
-			// only insert cover art
-			if p.Type == pictureTypes[0x03] {
-				result[rawName] = p
+				return err
 			}
+			result[rawName] = p
 
 		case name == "PIC":
 			p, err := readPICFrame(b)
 			if err != nil {
-				return nil, err
+				return err
 			}
+			result[rawName] = p
 
-			// only insert cover art
-			if p.Type == pictureTypes[0x03] {
-				result[rawName] = p
-			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return result, latin1, nil
+}
 
-		default:
-			continue
+// frameKey returns the key of the frame in the frames read by
+// readID3v2Frames. There can be multiple tag with the same name, a number is
+// appended to the name if there is more than one.
+func frameKey(frames map[string]any, name string) string {
+	key := name
+	if _, ok := frames[key]; ok {
+		for i := 0; ok; i++ {
+			key = name + "_" + strconv.Itoa(i)
+			_, ok = frames[key]
 		}
 	}
-	return result, nil
+	return key
+}
+
+// isTextFrame reports whether the frame starts with the text encoding and
+// is read as text (see readID3v2Frames).
+func isTextFrame(name string) bool {
+	switch name {
+	case "COMM", "COM", "USLT", "ULT", "SYLT", "SLT":
+		return true
+	}
+	return name[0] == 'T'
+}
+
+// removeUnsynchronisation reverts the unsynchronisation scheme by removing
+// the $00 inserted after every $FF (see http://id3.org/id3v2.4.0-structure sec 6.1)
+func removeUnsynchronisation(b []byte) []byte {
+	if bytes.Index(b, []byte{0xFF, 0x00}) < 0 {
+		return b
+	}
+
+	result := make([]byte, 0, len(b))
+	for i := 0; i < len(b); i++ {
+		result = append(result, b[i])
+		if b[i] == 0xFF && i+1 < len(b) && b[i+1] == 0x00 {
+			i++
+		}
+	}
+	return result
+}
+
+// decompressFrame inflates a zlib compressed frame, dataLength is the size
+// of the decompressed data if known.
+func decompressFrame(b []byte, dataLength uint) ([]byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	if dataLength > readByteMax {
+		return nil, fmt.Errorf("%w: compressed frame inflates to %d bytes", ErrMalformedTag, dataLength)
+	}
+
+	// a small frame can inflate to a lot of data, never read more than readByteMax
+	lr := io.LimitReader(zr, readByteMax)
+	if dataLength > 0 {
+		return readBytes(lr, dataLength)
+	}
+	return io.ReadAll(lr)
 }
@@ -0,0 +1,145 @@
+package musictag
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func TestRemoveUnsynchronisation(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []byte
+		want []byte
+	}{
+		{"empty", nil, nil},
+		{"nothing to remove", []byte{0x01, 0xFF, 0xE0, 0x00}, []byte{0x01, 0xFF, 0xE0, 0x00}},
+		{"false sync", []byte{0xFF, 0x00, 0xE0}, []byte{0xFF, 0xE0}},
+		{"zero after $FF", []byte{0xFF, 0x00, 0x00}, []byte{0xFF, 0x00}},
+		{"trailing $FF", []byte{0x41, 0xFF, 0x00}, []byte{0x41, 0xFF}},
+		{"consecutive $FF", []byte{0xFF, 0xFF, 0x00, 0xFF, 0x00, 0xFE}, []byte{0xFF, 0xFF, 0xFF, 0xFE}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := removeUnsynchronisation(tt.in); !bytes.Equal(got, tt.want) {
+				t.Errorf("removeUnsynchronisation(% x) = % x, want % x", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func compress(t *testing.T, b []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	if _, err := w.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecompressFrame(t *testing.T) {
+	data := []byte("\x03compressed title")
+	z := compress(t, data)
+	errAny := errors.New("any error")
+
+	tests := []struct {
+		name       string
+		in         []byte
+		dataLength uint
+		want       []byte
+		wantErr    error
+	}{
+		{"known length", z, uint(len(data)), data, nil},
+		{"unknown length", z, 0, data, nil},
+		{"shorter length", z, 4, data[:4], nil},
+		{"longer length", z, uint(len(data)) + 1, nil, errAny},
+		{"not zlib", data, uint(len(data)), nil, errAny},
+		{"length above readByteMax", z, readByteMax + 1, nil, ErrMalformedTag},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decompressFrame(tt.in, tt.dataLength)
+			switch {
+			case tt.wantErr == nil && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tt.wantErr == nil:
+				if !bytes.Equal(got, tt.want) {
+					t.Errorf("decompressFrame() = %q, want %q", got, tt.want)
+				}
+			case err == nil:
+				t.Errorf("decompressFrame() = %q, want an error", got)
+			case tt.wantErr != errAny && !errors.Is(err, tt.wantErr):
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// id3v2Tag returns a tag of the given major version holding a single frame
+// with the format flags and body.
+func id3v2Tag(version byte, name string, formatFlags byte, body []byte) []byte {
+	frame := make([]byte, 10, 10+len(body))
+	copy(frame, name)
+	if version == 4 {
+		putSynchsafeInt(frame[4:8], uint(len(body)))
+	} else {
+		binary.BigEndian.PutUint32(frame[4:8], uint32(len(body)))
+	}
+	frame[9] = formatFlags
+	frame = append(frame, body...)
+
+	tag := []byte{'I', 'D', '3', version, 0, 0, 0, 0, 0, 0}
+	putSynchsafeInt(tag[6:10], uint(len(frame)))
+	return append(tag, frame...)
+}
+
+// synchsafe returns n as a 4 byte synchsafe integer.
+func synchsafe(n uint) []byte {
+	b := make([]byte, 4)
+	putSynchsafeInt(b, n)
+	return b
+}
+
+func TestReadID3v2FrameFlags(t *testing.T) {
+	text := []byte("\x03Flagged Title")
+	z := compress(t, text)
+	bigEndian := func(n uint32) []byte { return binary.BigEndian.AppendUint32(nil, n) }
+
+	tests := []struct {
+		name  string
+		tag   []byte
+		title string // empty when the tag is invalid
+	}{
+		{"2.4 data length indicator", id3v2Tag(4, "TIT2", 0x01, append(synchsafe(uint(len(text))), text...)), "Flagged Title"},
+		{"2.4 compression", id3v2Tag(4, "TIT2", 0x09, append(synchsafe(uint(len(text))), z...)), "Flagged Title"},
+		{"2.4 unsynchronisation", id3v2Tag(4, "TIT2", 0x03, append(synchsafe(6), "\x01\xFF\x00\xFET\x00i\x00"...)), "Ti"},
+		{"2.3 compression", id3v2Tag(3, "TIT2", 0x80, append(bigEndian(uint32(len(text))), z...)), "Flagged Title"},
+		{"2.4 compression without data length indicator", id3v2Tag(4, "TIT2", 0x08, z), ""},
+		{"2.4 data length indicator above readByteMax", id3v2Tag(4, "TIT2", 0x09, append(synchsafe(readByteMax+1), z...)), ""},
+		{"2.3 decompressed size above readByteMax", id3v2Tag(3, "TIT2", 0x80, append(bigEndian(readByteMax+1), z...)), ""},
+		{"flags larger than the frame", id3v2Tag(3, "TIT2", 0x80, []byte{0, 0}), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := ReadID3v2Tags(bytes.NewReader(tt.tag))
+			if tt.title == "" {
+				if err == nil {
+					t.Errorf("no error, title = %q", m.GetTitle())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := m.GetTitle(); got != tt.title {
+				t.Errorf("title = %q, want %q", got, tt.title)
+			}
+		})
+	}
+}
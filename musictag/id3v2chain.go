@@ -0,0 +1,179 @@
+package musictag
+
+import (
+	"io"
+)
+
+// more tags are ignored, a chain of ID3v2 tags is usually 2 or 3 tags long
+const maxID3v2Tags = 16
+
+// leadingID3v2Offsets returns the offsets of the ID3v2 tags written back to
+// back at the start of the file (some streaming rippers prepend a new tag to
+// the existing one), end is the offset of the audio data after them.
+func leadingID3v2Offsets(r io.ReadSeeker) (offsets []int64, end int64, err error) {
+	for len(offsets) < maxID3v2Tags {
+		size, err := id3v2TagSizeAt(r, end)
+		if err != nil {
+			return nil, 0, err
+		}
+		if size == 0 {
+			break
+		}
+
+		offsets = append(offsets, end)
+		end += size
+	}
+	return offsets, end, nil
+}
+
+// leadingID3v2End returns the offset of the audio data after the ID3v2
+// tags at the start of the file.
+func leadingID3v2End(r io.ReadSeeker) (int64, error) {
+	_, end, err := leadingID3v2Offsets(r)
+	return end, err
+}
+
+// readID3v2TagAt reads the ID3v2 tag starting at offset and returns it
+// with the offset of its end, see WithoutPictures for skipPictures.
+func readID3v2TagAt(r io.ReadSeeker, offset int64, skipPictures bool) (ID3v2Metadata, int64, error) {
+	size, err := id3v2TagSizeAt(r, offset)
+	if err != nil {
+		return ID3v2Metadata{}, 0, err
+	}
+	if size == 0 {
+		return ID3v2Metadata{}, 0, ErrNoTagFound
+	}
+
+	if _, err = r.Seek(offset, io.SeekStart); err != nil {
+		return ID3v2Metadata{}, 0, err
+	}
+	h, headerEnd, fr, err := openID3v2Frames(r)
+	if err != nil {
+		return ID3v2Metadata{}, 0, err
+	}
+	m, err := readID3v2Tag(r, h, headerEnd, fr, skipPictures)
+	if err != nil {
+		return ID3v2Metadata{}, 0, err
+	}
+	return m, offset + size, nil
+}
+
+// readLeadingID3v2Tags reads the ID3v2 tags at the start of the file and
+// the tags found by following their SEEK frames, in the order they appear.
+// The tags read before an invalid tag are returned with the error.
+func readLeadingID3v2Tags(r io.ReadSeeker, skipPictures bool) ([]Metadata, error) {
+	offsets, _, err := leadingID3v2Offsets(r)
+	if err != nil || len(offsets) == 0 {
+		return nil, err
+	}
+
+	var tags []Metadata
+	var tagEnd int64
+	for _, offset := range offsets {
+		m, end, err := readID3v2TagAt(r, offset, skipPictures)
+		if err != nil {
+			return tags, err
+		}
+		tags = append(tags, m)
+		tagEnd = end
+
+		next, ok := m.seekOffset()
+		for ok && len(tags) < maxID3v2Tags {
+			// the SEEK frame points to a tag further in the audio stream,
+			// an invalid offset ends the chain.
+			m, end, err = readID3v2TagAt(r, tagEnd+next, skipPictures)
+			if err != nil {
+				break
+			}
+			tags = append(tags, m)
+			tagEnd = end
+			next, ok = m.seekOffset()
+		}
+	}
+	return tags, nil
+}
+
+// seekOffset returns the offset of the next tag from the end of this tag
+// given by the SEEK frame (see http://id3.org/id3v2.4.0-frames sec 4.29).
+func (m ID3v2Metadata) seekOffset() (int64, bool) {
+	offset, ok := m.frames["SEEK"].(int64)
+	return offset, ok && offset > 0
+}
+
+// findAppendedID3v2Tag returns the offset of the ID3v2.4 tag ending at end
+// with a footer, -1 if there is none.
+// Footer
+// Identifier          "3DI"
+// Version             $04 00
+// Flags               %abcd0000
+// Size                4 * %0xxxxxxx (synchsafe, without header and footer)
+func findAppendedID3v2Tag(r io.ReadSeeker, end int64) (int64, error) {
+	if end < 20 {
+		return -1, nil
+	}
+
+	if _, err := r.Seek(end-10, io.SeekStart); err != nil {
+		return 0, err
+	}
+	b, err := readBytes(r, 10)
+	if err != nil {
+		return 0, err
+	}
+
+	if string(b[0:3]) != "3DI" || b[3] != 4 {
+		return -1, nil
+	}
+
+	start := end - 20 - int64(get7BitChunkedInt(b[6:10]))
+	if start < 0 {
+		return -1, nil
+	}
+
+	size, err := id3v2TagSizeAt(r, start)
+	if err != nil {
+		return 0, err
+	}
+	if start+size != end {
+		return -1, nil
+	}
+	return start, nil
+}
+
+// readAppendedID3v2Tag reads the ID3v2.4 tag appended at the end of the
+// file, it is followed by the ID3v1 and Lyrics3v2 tags and is written
+// either after or before the APE tag. ErrNoTagFound is returned without it.
+func readAppendedID3v2Tag(r io.ReadSeeker, skipPictures bool) (Metadata, error) {
+	end, err := findID3v1End(r)
+	if err != nil {
+		return nil, err
+	}
+
+	start, err := findAppendedID3v2Tag(r, end)
+	if err != nil {
+		return nil, err
+	}
+
+	if start < 0 {
+		apeStart, err := findAPETagStart(r, end)
+		if err != nil {
+			return nil, err
+		}
+		if apeStart < 0 {
+			return nil, ErrNoTagFound
+		}
+
+		start, err = findAppendedID3v2Tag(r, apeStart)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if start < 0 {
+		return nil, ErrNoTagFound
+	}
+	m, _, err := readID3v2TagAt(r, start, skipPictures)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
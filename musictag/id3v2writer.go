@@ -0,0 +1,496 @@
+package musictag
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"unicode/utf16"
+)
+
+// padding added after the frames when the file has to be rewritten, so the
+// next edits can be done in place.
+const id3v2DefaultPadding = 2048
+
+var ErrUnsupportedVersion = errors.New("only ID3v2.3 and ID3v2.4 tags can be written")
+
+// ErrNotMPEG is returned when the tag of a file which is not MPEG audio
+// would be written, the other containers don't expect a leading ID3v2 tag.
+var ErrNotMPEG = errors.New("ID3v2 tags can only be written to MPEG audio files")
+
+type id3v2Frame struct {
+	name string
+	body []byte
+}
+
+// ID3v2Tag is a ID3v2.3 or ID3v2.4 tag to be written to a file.
+// Text is written as UTF-8 for ID3v2.4 and as ISO-8859-1 (or UTF-16 when
+// the text can't be represented in ISO-8859-1) for ID3v2.3.
+type ID3v2Tag struct {
+	version TagFormat
+	frames  []id3v2Frame
+}
+
+func NewID3v2Tag(version TagFormat) (*ID3v2Tag, error) {
+	if version != ID3v2_3 && version != ID3v2_4 {
+		return nil, ErrUnsupportedVersion
+	}
+	return &ID3v2Tag{version: version}, nil
+}
+
+func (t *ID3v2Tag) Version() TagFormat { return t.version }
+
+// ReadID3v2Tag reads the ID3v2.3 or ID3v2.4 tag at the start of r to be
+// modified and written back, the frames are kept as is except the encrypted
+// ones which are dropped.
+func ReadID3v2Tag(r io.ReadSeeker) (*ID3v2Tag, error) {
+	return readID3v2TagFramesAt(r, 0)
+}
+
+// readID3v2TagFramesAt reads the frames of the ID3v2.3 or ID3v2.4 tag
+// starting at offset, see ReadID3v2Tag.
+func readID3v2TagFramesAt(r io.ReadSeeker, offset int64) (*ID3v2Tag, error) {
+	if _, err := r.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	h, headerEnd, fr, err := openID3v2Frames(r)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := NewID3v2Tag(h.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	err = walkID3v2Frames(fr, headerEnd, h, nil, func(name string, b []byte) error {
+		t.frames = append(t.frames, id3v2Frame{name: name, body: b})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// add a frame, replacing the frames with the same name for which replace returns true
+func (t *ID3v2Tag) add(name string, body []byte, replace func(b []byte) bool) {
+	frames := t.frames[:0]
+	for _, f := range t.frames {
+		if f.name != name || !replace(f.body) {
+			frames = append(frames, f)
+		}
+	}
+	t.frames = append(frames, id3v2Frame{name: name, body: body})
+}
+
+// Remove removes all the frames with the given name.
+func (t *ID3v2Tag) Remove(name string) {
+	frames := t.frames[:0]
+	for _, f := range t.frames {
+		if f.name != name {
+			frames = append(frames, f)
+		}
+	}
+	t.frames = frames
+}
+
+// SetText sets a text frame (e.g. "TIT2", "TPE1"), replacing the previous value.
+// Use SetUserText for "TXXX" frames.
+// Text encoding       $xx
+// Information         <text string according to encoding>
+func (t *ID3v2Tag) SetText(name string, text string) error {
+	if len(name) != 4 || name[0] != 'T' || name == "TXXX" {
+		return fmt.Errorf("%q is not a text frame", name)
+	}
+
+	enc := t.encoding(text)
+	body := append([]byte{enc}, encodeText(enc, text)...)
+	t.add(name, body, func([]byte) bool { return true })
+	return nil
+}
+
+// SetUserText sets a "TXXX" frame, replacing the frame with the same description.
+// Text encoding       $xx
+// Description         <text string according to encoding> $00 (00)
+// Value               <text string according to encoding>
+func (t *ID3v2Tag) SetUserText(desc, value string) {
+	enc := t.encoding(desc + value)
+
+	body := []byte{enc}
+	body = append(body, encodeText(enc, desc)...)
+	body = append(body, textTerminator(enc)...)
+	body = append(body, encodeText(enc, value)...)
+
+	t.add("TXXX", body, func(b []byte) bool {
+		c, err := readTextWithDescrFrame(b, false, true)
+		return err == nil && c.Description == desc
+	})
+}
+
+// SetComment sets a "COMM" frame, replacing the frame with the same language
+// and description.
+func (t *ID3v2Tag) SetComment(lang, desc, text string) {
+	t.setTextWithLang("COMM", lang, desc, text)
+}
+
+// SetLyrics sets a "USLT" frame, replacing the frame with the same language
+// and description.
+func (t *ID3v2Tag) SetLyrics(lang, desc, text string) {
+	t.setTextWithLang("USLT", lang, desc, text)
+}
+
+// Text encoding       $xx
+// Language            $xx xx xx
+// Content descriptor  <text string according to encoding> $00 (00)
+// Text                <full text string according to encoding>
+func (t *ID3v2Tag) setTextWithLang(name, lang, desc, text string) {
+	lang = (lang + "XXX")[:3]
+	enc := t.encoding(desc + text)
+
+	body := []byte{enc}
+	body = append(body, lang...)
+	body = append(body, encodeText(enc, desc)...)
+	body = append(body, textTerminator(enc)...)
+	body = append(body, encodeText(enc, text)...)
+
+	t.add(name, body, func(b []byte) bool {
+		c, err := readTextWithDescrFrame(b, true, true)
+		return err == nil && c.Language == lang && c.Description == desc
+	})
+}
+
+// SetRating sets the rating (0-5 stars) of all the "POPM" frames, or adds a
+// frame for email if there is none. The play counters are kept.
+// Email to user       <text string> $00
+// Rating              $xx
+// Counter             $xx xx xx xx (xx ...)
+func (t *ID3v2Tag) SetRating(email string, stars int) {
+	rating := StarsToPOPM(stars)
+
+	found := false
+	for i, f := range t.frames {
+		if f.name != "POPM" {
+			continue
+		}
+		p, err := readPOPMFrame(f.body)
+		if err != nil {
+			continue
+		}
+		p.Rating = rating
+		t.frames[i].body = popmBody(p)
+		found = true
+	}
+
+	if !found {
+		t.frames = append(t.frames, id3v2Frame{name: "POPM", body: popmBody(&Popularimeter{Email: email, Rating: rating})})
+	}
+}
+
+func popmBody(p *Popularimeter) []byte {
+	body := append(encodeText(encodingISO8859, p.Email), 0, p.Rating)
+	return append(body, encodeCounter(p.Counter)...)
+}
+
+// WriteID3v2Rating sets the rating (0-5 stars) of the ID3v2 tag of the file,
+// see SetRating. A ID3v2.4 tag is added to the MPEG files without one, the
+// other containers are refused with ErrNotMPEG.
+func WriteID3v2Rating(path, email string, stars int) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	t, err := readWritableID3v2Tag(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	t.SetRating(email, stars)
+	return WriteID3v2Tag(path, t)
+}
+
+// readWritableID3v2Tag reads the leading ID3v2 tags of a MPEG file merged
+// into one tag, a new ID3v2.4 tag is returned if it has none. The frames of
+// the next tags of the chain are added when the first tag has no frame with
+// the same name, the tags of another version than the first one are dropped.
+// WriteID3v2Tag replaces the whole chain with the merged tag.
+func readWritableID3v2Tag(r io.ReadSeeker) (*ID3v2Tag, error) {
+	_, fileType, _, err := sniffContainer(r)
+	if err != nil {
+		return nil, err
+	}
+	if fileType != MP3 {
+		return nil, fmt.Errorf("%w: file type %q", ErrNotMPEG, fileType)
+	}
+
+	offsets, _, err := leadingID3v2Offsets(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(offsets) == 0 {
+		return NewID3v2Tag(ID3v2_4)
+	}
+
+	t, err := ReadID3v2Tag(r)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool)
+	for _, f := range t.frames {
+		names[f.name] = true
+	}
+	for _, offset := range offsets[1:] {
+		next, err := readID3v2TagFramesAt(r, offset)
+		if err == ErrUnsupportedVersion {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if next.version != t.version {
+			continue
+		}
+
+		for _, f := range next.frames {
+			if !names[f.name] {
+				t.frames = append(t.frames, f)
+			}
+		}
+	}
+	return t, nil
+}
+
+// AddPicture adds a "APIC" frame, replacing the picture of the same type.
+// Text encoding       $xx
+// MIME type           <text string> $00
+// Picture type        $xx
+// Description         <text string according to encoding> $00 (00)
+// Picture data        <binary data>
+func (t *ID3v2Tag) AddPicture(p *Picture) {
+	picType := pictureTypeCode(p.Type)
+	enc := t.encoding(p.Description)
+
+	body := []byte{enc}
+	body = append(body, p.MIMEType...)
+	body = append(body, 0, picType)
+	body = append(body, encodeText(enc, p.Description)...)
+	body = append(body, textTerminator(enc)...)
+	body = append(body, p.Data...)
+
+	t.add("APIC", body, func(b []byte) bool {
+		old, err := readAPICFrame(b)
+		return err == nil && pictureTypeCode(old.Type) == picType
+	})
+}
+
+// pictureTypeCode returns the APIC picture type code of a Picture.Type,
+// an empty type is considered as front cover.
+func pictureTypeCode(picType string) byte {
+	if picType == "" {
+		return 0x03
+	}
+	for k, v := range pictureTypes {
+		if v == picType {
+			return k
+		}
+	}
+	return 0x00
+}
+
+// encoding choose the text encoding for the tag version
+func (t *ID3v2Tag) encoding(text string) byte {
+	if t.version == ID3v2_4 {
+		return encodingUTF8
+	}
+
+	for _, r := range text {
+		if r > 0xFF {
+			return encodingUTF16WithBOM
+		}
+	}
+	return encodingISO8859
+}
+
+func encodeText(enc byte, text string) []byte {
+	switch enc {
+	case encodingISO8859:
+		b := make([]byte, 0, len(text))
+		for _, r := range text {
+			b = append(b, byte(r))
+		}
+		return b
+
+	case encodingUTF16WithBOM:
+		u := utf16.Encode([]rune(text))
+		b := make([]byte, 2, 2+2*len(u))
+		binary.LittleEndian.PutUint16(b, 0xFEFF)
+		for _, x := range u {
+			b = binary.LittleEndian.AppendUint16(b, x)
+		}
+		return b
+
+	default:
+		return []byte(text)
+	}
+}
+
+func textTerminator(enc byte) []byte {
+	if enc == encodingUTF16 || enc == encodingUTF16WithBOM {
+		return doubleZero
+	}
+	return singleZero
+}
+
+func putSynchsafeInt(b []byte, n uint) {
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i] = byte(n & 0x7F)
+		n >>= 7
+	}
+}
+
+// frameBytes serialises the frames (without tag header and padding).
+// Frame ID            $xx xx xx xx
+// Size                $xx xx xx xx (synchsafe integer in ID3v2.4)
+// Flags               $xx xx
+func (t *ID3v2Tag) frameBytes() []byte {
+	var buf bytes.Buffer
+	for _, f := range t.frames {
+		header := make([]byte, 10)
+		copy(header, f.name)
+		if t.version == ID3v2_4 {
+			putSynchsafeInt(header[4:8], uint(len(f.body)))
+		} else {
+			binary.BigEndian.PutUint32(header[4:8], uint32(len(f.body)))
+		}
+		buf.Write(header)
+		buf.Write(f.body)
+	}
+	return buf.Bytes()
+}
+
+// Bytes serialises the tag with the given amount of padding.
+// File identifier     "ID3"
+// Version             $03 00 or $04 00
+// Flags               %00000000
+// Size                4 * %0xxxxxxx (excluding the 10 byte header)
+func (t *ID3v2Tag) Bytes(padding uint) []byte {
+	frames := t.frameBytes()
+
+	b := make([]byte, 10, 10+uint(len(frames))+padding)
+	copy(b, "ID3")
+	b[3] = 3
+	if t.version == ID3v2_4 {
+		b[3] = 4
+	}
+	putSynchsafeInt(b[6:10], uint(len(frames))+padding)
+
+	b = append(b, frames...)
+	return append(b, make([]byte, padding)...)
+}
+
+// id3v2TagSizeAt returns the size of the ID3v2 tag starting at offset
+// including header (and footer), 0 if there is no tag.
+func id3v2TagSizeAt(r io.ReadSeeker, offset int64) (int64, error) {
+	if _, err := r.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	b, err := readBytes(r, 10)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	if string(b[0:3]) != "ID3" {
+		return 0, nil
+	}
+
+	size := int64(10 + get7BitChunkedInt(b[6:10]))
+	// ID3v2.4 footer present
+	if b[3] == 4 && getBit(b[5], 4) {
+		size += 10
+	}
+	return size, nil
+}
+
+// WriteID3v2Tag replaces the ID3v2 tags at the start of the file (all the
+// tags written back to back, see readWritableID3v2Tag) with the given tag.
+// When the new tag fits into the existing ones it is written in place and the
+// remaining space is used as padding, otherwise the file is rewritten to a
+// temporary file which then atomically replaces the original one.
+func WriteID3v2Tag(path string, t *ID3v2Tag) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	oldSize, err := leadingID3v2End(f)
+	if err != nil {
+		return err
+	}
+
+	frameSize := int64(len(t.frameBytes()))
+	if oldSize > 0 && frameSize+10 <= oldSize {
+		if _, err = f.WriteAt(t.Bytes(uint(oldSize-10-frameSize)), 0); err != nil {
+			return err
+		}
+		return f.Sync()
+	}
+
+	return rewriteWithID3v2Tag(f, path, t.Bytes(id3v2DefaultPadding), oldSize)
+}
+
+// rewriteWithID3v2Tag writes tag followed by the audio data of src (starting
+// at offset) to a temporary file in the same directory and renames it to path.
+func rewriteWithID3v2Tag(src *os.File, path string, tag []byte, offset int64) (err error) {
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = tmp.Write(tag); err != nil {
+		return err
+	}
+
+	if _, err = src.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(tmp, src); err != nil {
+		return err
+	}
+
+	if err = tmp.Chmod(info.Mode().Perm()); err != nil {
+		return err
+	}
+
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,259 @@
+package musictag
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// copyFixture copies the sample file name of testdata/dir to a temporary
+// directory and returns the path of the copy.
+func copyFixture(t *testing.T, dir, name string) string {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join("..", "testdata", dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// audioBytes returns the content of the file after the leading ID3v2 tags
+func audioBytes(t *testing.T, path string) []byte {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	end, err := leadingID3v2End(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b[end:]
+}
+
+// readPath reads the tags of the file at path
+func readPath(t *testing.T, path string) Metadata {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	m, err := ReadFrom(f)
+	if err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	return m
+}
+
+// readWritablePath reads the writable tag of the file at path
+func readWritablePath(t *testing.T, path string) *ID3v2Tag {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	tag, err := readWritableID3v2Tag(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tag
+}
+
+func fileSize(t *testing.T, path string) int64 {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Size()
+}
+
+func TestWriteID3v2TagInPlace(t *testing.T) {
+	for _, name := range []string{"sample.id3v23.mp3", "sample.id3v24.mp3"} {
+		t.Run(name, func(t *testing.T) {
+			path := copyFixture(t, "with_tags", name)
+			audio, size := audioBytes(t, path), fileSize(t, path)
+
+			// the tag fits in the padding of the fixture
+			tag := readWritablePath(t, path)
+			if err := tag.SetText("TIT2", "New Title"); err != nil {
+				t.Fatal(err)
+			}
+			tag.SetRating("test@example.com", 4)
+			if err := WriteID3v2Tag(path, tag); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := fileSize(t, path); got != size {
+				t.Errorf("file size = %d, want %d (written in place)", got, size)
+			}
+			if !bytes.Equal(audioBytes(t, path), audio) {
+				t.Error("the audio data changed")
+			}
+
+			m := readPath(t, path)
+			if got := m.GetTitle(); got != "New Title" {
+				t.Errorf("title = %q, want %q", got, "New Title")
+			}
+			if got := m.GetArtist(); got != "Test Artist" {
+				t.Errorf("artist = %q, want %q", got, "Test Artist")
+			}
+			if got := m.GetRating(); got != 4 {
+				t.Errorf("rating = %d, want 4", got)
+			}
+		})
+	}
+}
+
+func TestWriteID3v2TagRewrite(t *testing.T) {
+	path := copyFixture(t, "with_tags", "sample.id3v24.mp3")
+	audio, size := audioBytes(t, path), fileSize(t, path)
+
+	// the picture doesn't fit in the padding, the file is rewritten
+	tag := readWritablePath(t, path)
+	tag.AddPicture(&Picture{MIMEType: "image/png", Type: "Cover (front)", Data: bytes.Repeat([]byte{0xAB}, 4096)})
+	if err := WriteID3v2Tag(path, tag); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := fileSize(t, path); got <= size {
+		t.Errorf("file size = %d, want more than %d", got, size)
+	}
+	if !bytes.Equal(audioBytes(t, path), audio) {
+		t.Error("the audio data changed")
+	}
+
+	m := readPath(t, path)
+	checkFixture(t, m, MP3, 1)
+
+	// no temporary file is left
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("%d files in the directory, want 1", len(entries))
+	}
+}
+
+func TestWriteID3v2TagUntagged(t *testing.T) {
+	path := copyFixture(t, "without_tags", "sample.mp3")
+	audio := audioBytes(t, path)
+
+	tag := readWritablePath(t, path)
+	if got := tag.Version(); got != ID3v2_4 {
+		t.Errorf("version of the new tag = %v, want %v", got, ID3v2_4)
+	}
+
+	if err := WriteID3v2Rating(path, "test@example.com", 5); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(audioBytes(t, path), audio) {
+		t.Error("the audio data changed")
+	}
+	if got := readPath(t, path).GetRating(); got != 5 {
+		t.Errorf("rating = %d, want 5", got)
+	}
+}
+
+func TestWriteID3v2TagNotMPEG(t *testing.T) {
+	path := copyFixture(t, "with_tags", "sample.flac")
+	before, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteID3v2Rating(path, "test@example.com", 3); !errors.Is(err, ErrNotMPEG) {
+		t.Errorf("WriteID3v2Rating(FLAC) error = %v, want %v", err, ErrNotMPEG)
+	}
+
+	after, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(after, before) {
+		t.Error("the FLAC file changed")
+	}
+}
+
+func TestWriteID3v2TagChain(t *testing.T) {
+	first, err := NewID3v2Tag(ID3v2_4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first.SetText("TIT2", "First Title")
+
+	second, err := NewID3v2Tag(ID3v2_4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second.SetText("TIT2", "Second Title")
+	second.SetText("TALB", "Chained Album")
+
+	// a ID3v2.3 tag can't be merged in the ID3v2.4 tag
+	third, err := NewID3v2Tag(ID3v2_3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	third.SetText("TCOM", "Dropped Composer")
+
+	path := copyFixture(t, "without_tags", "sample.mp3")
+	audio := audioBytes(t, path)
+
+	var b bytes.Buffer
+	b.Write(first.Bytes(0))
+	b.Write(second.Bytes(16))
+	b.Write(third.Bytes(0))
+	b.Write(audio)
+	if err := os.WriteFile(path, b.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tag := readWritablePath(t, path)
+	tag.SetRating("test@example.com", 2)
+	if err := WriteID3v2Tag(path, tag); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	offsets, _, err := leadingID3v2Offsets(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(offsets) != 1 {
+		t.Errorf("%d leading tags after the write, want 1", len(offsets))
+	}
+	if !bytes.Equal(audioBytes(t, path), audio) {
+		t.Error("the audio data changed")
+	}
+
+	m := readPath(t, path)
+	if got := m.GetTitle(); got != "First Title" {
+		t.Errorf("title = %q, want %q", got, "First Title")
+	}
+	if got := m.GetAlbum(); got != "Chained Album" {
+		t.Errorf("album = %q, want %q", got, "Chained Album")
+	}
+	if got := m.GetComposer(); got != "" {
+		t.Errorf("composer = %q, want the ID3v2.3 tag dropped", got)
+	}
+	if got := m.GetRating(); got != 2 {
+		t.Errorf("rating = %d, want 2", got)
+	}
+}
@@ -0,0 +1,190 @@
+package musictag
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LyricLine is a line of synchronised lyrics starting at Time.
+type LyricLine struct {
+	Time time.Duration
+	Text string
+}
+
+// SYLT time stamp formats
+const (
+	syltMPEGFrames   byte = 1
+	syltMilliseconds byte = 2
+)
+
+var errUnsupportedSYLTFormat = errors.New("SYLT frame with MPEG frames time stamps is not supported")
+
+// readSYLTFrame reads a synchronised lyrics frame, only the millisecond time
+// stamp format is supported.
+// <Header for 'Synchronised lyrics/text', ID: "SYLT">
+// Text encoding       $xx
+// Language            $xx xx xx
+// Time stamp format   $xx (1: MPEG frames, 2: milliseconds)
+// Content type        $xx
+// Content descriptor  <text string according to encoding> $00 (00)
+// Lines               <text string according to encoding> $00 (00) + time stamp $xx xx xx xx
+func readSYLTFrame(b []byte) ([]LyricLine, error) {
+	if len(b) < 6 {
+		return nil, errors.New("SYLT frame too short")
+	}
+
+	enc := b[0]
+	if b[4] == syltMPEGFrames {
+		return nil, errUnsupportedSYLTFormat
+	}
+	b = b[6:]
+
+	// skip the content descriptor
+	_, b, ok := cutTerminatedText(b, enc)
+	if !ok {
+		return nil, nil
+	}
+
+	var lines []LyricLine
+	for len(b) > 0 {
+		t, rest, ok := cutTerminatedText(b, enc)
+		if !ok || len(rest) < 4 {
+			return nil, errors.New("invalid SYLT line: missing time stamp")
+		}
+
+		text, err := decodeText(enc, t)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding SYLT text: %v", err)
+		}
+
+		lines = append(lines, LyricLine{
+			Time: time.Duration(getInt(rest[0:4])) * time.Millisecond,
+			// a line feed marks the start of a new line
+			Text: strings.TrimLeft(text, "\r\n"),
+		})
+		b = rest[4:]
+	}
+	return lines, nil
+}
+
+// cutTerminatedText splits b at the first text terminator, unlike dataSplit
+// the time stamp following the terminator can start with zeros.
+func cutTerminatedText(b []byte, enc byte) (text, rest []byte, ok bool) {
+	if enc == encodingUTF16 || enc == encodingUTF16WithBOM {
+		for i := 0; i+1 < len(b); i += 2 {
+			if b[i] == 0 && b[i+1] == 0 {
+				return b[:i], b[i+2:], true
+			}
+		}
+		return nil, nil, false
+	}
+
+	i := bytes.IndexByte(b, 0)
+	if i < 0 {
+		return nil, nil, false
+	}
+	return b[:i], b[i+1:], true
+}
+
+var (
+	lrcTimeTag   = regexp.MustCompile(`^\[(\d+):(\d{1,2})(?:[.:](\d{1,3}))?\]`)
+	lrcOffsetTag = regexp.MustCompile(`^\[offset:\s*([+-]?\d+)\]`)
+)
+
+// ParseLRC parse lyrics in the LRC format, a line can have multiple time tags
+// ("[01:02.50][02:10.00]text"), the other ID tags are ignored except [offset:ms].
+// The lines are sorted by time.
+func ParseLRC(r io.Reader) ([]LyricLine, error) {
+	var lines []LyricLine
+	var offset time.Duration
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if m := lrcOffsetTag.FindStringSubmatch(line); m != nil {
+			ms, _ := strconv.Atoi(m[1])
+			offset = time.Duration(ms) * time.Millisecond
+			continue
+		}
+
+		var times []time.Duration
+		for {
+			m := lrcTimeTag.FindStringSubmatch(line)
+			if m == nil {
+				break
+			}
+			minutes, _ := strconv.Atoi(m[1])
+			seconds, _ := strconv.Atoi(m[2])
+			// the fraction is in hundredths ("[mm:ss.xx]") or thousandths
+			// of a second, read it as milliseconds
+			ms, _ := strconv.Atoi((m[3] + "000")[:3])
+			times = append(times, time.Duration(minutes)*time.Minute+time.Duration(seconds)*time.Second+time.Duration(ms)*time.Millisecond)
+			line = line[len(m[0]):]
+		}
+
+		for _, t := range times {
+			lines = append(lines, LyricLine{Time: t, Text: strings.TrimSpace(line)})
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// a positive offset shifts the lyrics up (displayed sooner)
+	for i := range lines {
+		lines[i].Time -= offset
+		if lines[i].Time < 0 {
+			lines[i].Time = 0
+		}
+	}
+
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].Time < lines[j].Time })
+	return lines, nil
+}
+
+// parseEmbeddedLRC parse lyrics stored as LRC in a text field, nil if it
+// has no time tags.
+func parseEmbeddedLRC(lyrics string) []LyricLine {
+	if lyrics == "" {
+		return nil
+	}
+	lines, err := ParseLRC(strings.NewReader(lyrics))
+	if err != nil {
+		return nil
+	}
+	return lines
+}
+
+// FormatLRC returns the lines in the LRC format.
+func FormatLRC(lines []LyricLine) string {
+	var sb strings.Builder
+	for _, l := range lines {
+		cs := l.Time.Milliseconds() / 10
+		fmt.Fprintf(&sb, "[%02d:%02d.%02d]%s\n", cs/6000, cs/100%60, cs%100, l.Text)
+	}
+	return sb.String()
+}
+
+// ReadLRCFile reads the sidecar .lrc file of the audio file (same path with
+// the .lrc extension).
+func ReadLRCFile(audioPath string) ([]LyricLine, error) {
+	f, err := os.Open(strings.TrimSuffix(audioPath, filepath.Ext(audioPath)) + ".lrc")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseLRC(f)
+}
@@ -0,0 +1,121 @@
+package musictag
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func ms(n int) time.Duration { return time.Duration(n) * time.Millisecond }
+
+func TestParseLRC(t *testing.T) {
+	tests := []struct {
+		name string
+		lrc  string
+		want []LyricLine
+	}{
+		{"hundredths", "[00:12.29]first\n[01:02.05]second", []LyricLine{{ms(12290), "first"}, {ms(62050), "second"}}},
+		{"colon before hundredths", "[00:12:29]first\n[01:02:05]second", []LyricLine{{ms(12290), "first"}, {ms(62050), "second"}}},
+		{"milliseconds", "[00:12.345]first", []LyricLine{{ms(12345), "first"}}},
+		{"tenths", "[00:12.3]first", []LyricLine{{ms(12300), "first"}}},
+		{"no fraction", "[00:12]first", []LyricLine{{ms(12000), "first"}}},
+		{"long minutes", "[123:04.00]late", []LyricLine{{ms(123*60000 + 4000), "late"}}},
+		{"several time tags", "[00:03.00][00:01.00]chorus\n[00:02.00]verse", []LyricLine{{ms(1000), "chorus"}, {ms(2000), "verse"}, {ms(3000), "chorus"}}},
+		{"spaces and empty line", "  [00:01.00]  padded  \n[00:02.00]", []LyricLine{{ms(1000), "padded"}, {ms(2000), ""}}},
+		{"ID tags", "[ti:Title]\n[ar:Artist]\n[00:01.00]line", []LyricLine{{ms(1000), "line"}}},
+		{"positive offset", "[offset:+500]\n[00:00.20]first\n[00:02.00]second", []LyricLine{{0, "first"}, {ms(1500), "second"}}},
+		{"negative offset", "[offset:-250]\n[00:01.00]line", []LyricLine{{ms(1250), "line"}}},
+		{"offset after the lines", "[00:01.00]line\n[offset: 100]", []LyricLine{{ms(900), "line"}}},
+		{"invalid time tags", "[0:123.00]seconds\n[aa:bb]letters\nplain text", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLRC(strings.NewReader(tt.lrc))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseLRC() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatLRC(t *testing.T) {
+	lines := []LyricLine{{ms(12290), "first"}, {ms(62050), "second"}, {ms(123*60000 + 4000), "late"}}
+	want := "[00:12.29]first\n[01:02.05]second\n[123:04.00]late\n"
+	got := FormatLRC(lines)
+	if got != want {
+		t.Fatalf("FormatLRC() = %q, want %q", got, want)
+	}
+	if parsed, _ := ParseLRC(strings.NewReader(got)); !reflect.DeepEqual(parsed, lines) {
+		t.Errorf("ParseLRC(FormatLRC()) = %v, want %v", parsed, lines)
+	}
+}
+
+func TestReadSYLTFrame(t *testing.T) {
+	tests := []struct {
+		name    string
+		frame   string
+		want    []LyricLine
+		wantErr bool
+	}{
+		{
+			name:  "milliseconds",
+			frame: "\x00eng\x02\x01desc\x00first\x00\x00\x00\x30\x39\nsecond\x00\x00\x00\xf2\x32",
+			want:  []LyricLine{{ms(12345), "first"}, {ms(62002), "second"}},
+		},
+		{
+			name:  "UTF-16",
+			frame: "\x01eng\x02\x01\xff\xfe\x00\x00\xff\xfeH\x00i\x00\x00\x00\x00\x00\x03\xe8",
+			want:  []LyricLine{{ms(1000), "Hi"}},
+		},
+		{
+			name:  "time stamp starting with zeros",
+			frame: "\x03eng\x02\x01\x00line\x00\x00\x00\x00\x00",
+			want:  []LyricLine{{0, "line"}},
+		},
+		{
+			name:  "no content descriptor terminator",
+			frame: "\x00eng\x02\x01desc",
+		},
+		{
+			name:    "missing time stamp",
+			frame:   "\x00eng\x02\x01\x00line\x00\x00\x01",
+			wantErr: true,
+		},
+		{
+			name:    "MPEG frames",
+			frame:   "\x00eng\x01\x01\x00line\x00\x00\x00\x00\x10",
+			wantErr: true,
+		},
+		{
+			name:    "too short",
+			frame:   "\x00eng\x02",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readSYLTFrame([]byte(tt.frame))
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("readSYLTFrame() = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readSYLTFrame() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := readSYLTFrame([]byte("\x00eng\x01\x01\x00")); !errors.Is(err, errUnsupportedSYLTFormat) {
+		t.Errorf("MPEG frames error = %v, want %v", err, errUnsupportedSYLTFormat)
+	}
+}
@@ -0,0 +1,436 @@
+package musictag
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var ErrNotMP4 = errors.New("Invalid MP4 file")
+
+// MP4Metadata is the implementation of Metadata used for iTunes-style MP4
+// metadata stored in moov/udta/meta/ilst.
+type MP4Metadata struct {
+	brand    string
+	atoms    map[string]any
+	chapters []Chapter
+}
+
+func (m MP4Metadata) getString(k string) string {
+	v, ok := m.atoms[k]
+	if !ok {
+		return ""
+	}
+	s, _ := v.(string)
+	return s
+}
+
+func (m MP4Metadata) getPair(k string) (int, int) {
+	v, ok := m.atoms[k]
+	if !ok {
+		return 0, 0
+	}
+	p, _ := v.([2]int)
+	return p[0], p[1]
+}
+
+func (MP4Metadata) GetTagFormat() TagFormat  { return ITunes }
+func (MP4Metadata) GetFileType() FileType    { return MP4 }
+func (m MP4Metadata) GetTitle() string       { return m.getString("\xa9nam") }
+func (m MP4Metadata) GetArtist() string      { return m.getString("\xa9ART") }
+func (m MP4Metadata) GetAlbum() string       { return m.getString("\xa9alb") }
+func (m MP4Metadata) GetAlbumArtist() string { return m.getString("aART") }
+func (m MP4Metadata) GetComment() string     { return m.getString("\xa9cmt") }
+func (m MP4Metadata) GetBrand() string       { return m.brand }
+func (m MP4Metadata) GetComposer() string    { return m.getString("\xa9wrt") }
+func (m MP4Metadata) GetBPM() int            { return parseBPM(m.getString("tmpo")) }
+func (m MP4Metadata) GetLyrics() string      { return m.getString("\xa9lyr") }
+
+// GetArtists returns the freeform ARTISTS item, or the artist
+func (m MP4Metadata) GetArtists() []string {
+	if artists := m.GetCustom(customArtists); artists != "" {
+		return []string{artists}
+	}
+	return singleValue(m.GetArtist())
+}
+
+// GetCustom returns the value of the freeform ("----") item with the given
+// name, the iTunes item first, then the first item (in alphabetical order)
+// matching the name.
+func (m MP4Metadata) GetCustom(key string) string {
+	if v, ok := m.atoms["----:com.apple.iTunes:"+key]; ok {
+		s, _ := v.(string)
+		return s
+	}
+
+	for _, k := range slices.Sorted(maps.Keys(m.atoms)) {
+		if !strings.HasPrefix(k, "----:") {
+			continue
+		}
+		// the name follows the last colon of "----:mean:name"
+		if matchCustomKey(k[strings.LastIndex(k, ":")+1:], key) {
+			s, _ := m.atoms[k].(string)
+			return s
+		}
+	}
+	return ""
+}
+
+func (m MP4Metadata) GetSyncedLyrics() []LyricLine { return parseEmbeddedLRC(m.GetLyrics()) }
+func (m MP4Metadata) GetRating() int               { return parseFMPSRating(m.GetCustom(fmpsRating)) }
+func (m MP4Metadata) GetPlayCount() int            { return parseFMPSPlayCount(m.GetCustom(fmpsPlayCount)) }
+func (m MP4Metadata) GetChapters() []Chapter       { return m.chapters }
+
+// GetTrack returns the track number and the total number of tracks.
+func (m MP4Metadata) GetTrack() (int, int) { return m.getPair("trkn") }
+
+// GetDisc returns the disc number and the total number of discs.
+func (m MP4Metadata) GetDisc() (int, int) { return m.getPair("disk") }
+
+func (m MP4Metadata) GetGenre() string {
+	if genre := m.getString("\xa9gen"); genre != "" {
+		return genre
+	}
+	return m.getString("gnre")
+}
+
+func (m MP4Metadata) GetYear() int {
+	date := m.getString("\xa9day")
+	if len(date) > 4 {
+		// ©day is usualy a ISO 8601 date like 2000-01-01T00:00:00Z
+		date = date[:4]
+	}
+
+	year, err := strconv.Atoi(date)
+	if err != nil {
+		return 0
+	}
+	return year
+}
+
+func (m MP4Metadata) GetAlbumArt() *Picture { return frontCover(m.GetPictures()) }
+
+func (m MP4Metadata) GetPictures() []*Picture {
+	pictures, _ := m.atoms["covr"].([]*Picture)
+	return pictures
+}
+
+// ReadMP4Tags reads the iTunes-style metadata from a MP4/M4A file.
+func ReadMP4Tags(r io.ReadSeeker) (Metadata, error) {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	name, atomSize, headerSize, err := readMP4AtomHeader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	if name != "ftyp" || atomSize < headerSize+4 {
+		return nil, ErrNotMP4
+	}
+
+	brand, err := readString(r, 4)
+	if err != nil {
+		return nil, err
+	}
+
+	// skip the rest of ftyp atom
+	if _, err = r.Seek(int64(atomSize), io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	m := MP4Metadata{
+		brand: brand,
+		atoms: make(map[string]any),
+	}
+	if err = readMP4Atoms(r, size-int64(atomSize), 0, m.atoms); err != nil {
+		return nil, err
+	}
+
+	// the tracks and the Nero chapters are only used for the chapters
+	tracks, _ := m.atoms["trak"].([]*mp4Track)
+	nero, _ := m.atoms["chpl"].([]Chapter)
+	delete(m.atoms, "trak")
+	delete(m.atoms, "chpl")
+
+	m.chapters, err = readMP4Chapters(r, tracks, nero)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// readMP4AtomHeader reads the atom header, remaining is the number of bytes
+// left in the parent atom and used when the size is 0 (atom extends to the
+// end of the parent).
+// Size                [uint32]
+// Type                [4 byte string]
+// Extended size       [uint64, only if size == 1]
+func readMP4AtomHeader(r io.Reader, remaining int64) (name string, size uint, headerSize uint, err error) {
+	size, err = readUint(r, 4)
+	if err != nil {
+		return
+	}
+
+	name, err = readString(r, 4)
+	if err != nil {
+		return
+	}
+	headerSize = 8
+
+	switch size {
+	case 0:
+		size = uint(remaining)
+	case 1:
+		size, err = readUint(r, 8)
+		if err != nil {
+			return
+		}
+		headerSize += 8
+	}
+
+	if size < headerSize || size > uint(max(remaining, 0)) {
+		err = fmt.Errorf("%w: invalid size %d for atom %q", ErrMalformedTag, size, name)
+	}
+	return
+}
+
+// the atoms read are at most a few levels deep (moov/trak/mdia/minf/stbl)
+const mp4MaxDepth = 8
+
+// readMP4Atoms walks the atoms down to moov/udta/meta/ilst and skips the rest,
+// the tracks (moov/trak) and the Nero chapters (moov/udta/chpl) are stored as
+// "trak" and "chpl". depth is the number of parent atoms.
+func readMP4Atoms(r io.ReadSeeker, remaining int64, depth int, result map[string]any) error {
+	if depth > mp4MaxDepth {
+		return fmt.Errorf("%w: MP4 atoms nested more than %d levels deep", ErrMalformedTag, mp4MaxDepth)
+	}
+
+	for remaining >= 8 {
+		name, size, headerSize, err := readMP4AtomHeader(r, remaining)
+		if err != nil {
+			return err
+		}
+		remaining -= int64(size)
+		bodySize := int64(size - headerSize)
+
+		switch name {
+		case "moov", "udta":
+			err = readMP4Atoms(r, bodySize, depth+1, result)
+
+		case "meta":
+			// meta is a full atom, skip version and flags
+			if bodySize < 4 {
+				return fmt.Errorf("%w: invalid size %d for atom %q", ErrMalformedTag, size, name)
+			}
+			if _, err = readBytes(r, 4); err != nil {
+				return err
+			}
+			err = readMP4Atoms(r, bodySize-4, depth+1, result)
+
+		case "ilst":
+			err = readMP4ItemList(r, bodySize, result)
+
+		case "trak":
+			t := &mp4Track{}
+			if err = readMP4Track(r, bodySize, depth+1, t); err != nil {
+				return err
+			}
+			tracks, _ := result["trak"].([]*mp4Track)
+			result["trak"] = append(tracks, t)
+
+		case "chpl":
+			var b []byte
+			if b, err = readBytes(r, uint(bodySize)); err != nil {
+				return err
+			}
+			result["chpl"], err = readMP4NeroChapters(b)
+
+		default:
+			_, err = r.Seek(bodySize, io.SeekCurrent)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	// skip trailing bytes which can't hold an atom
+	_, err := r.Seek(remaining, io.SeekCurrent)
+	return err
+}
+
+// MP4 data atom type indicators
+// (see https://developer.apple.com/documentation/quicktime-file-format/well-known_types)
+const (
+	mp4TypeImplicit uint = 0
+	mp4TypeUTF8     uint = 1
+	mp4TypeUTF16    uint = 2
+	mp4TypeJPEG     uint = 13
+	mp4TypePNG      uint = 14
+	mp4TypeInteger  uint = 21
+	mp4TypeBMP      uint = 27
+)
+
+// readMP4ItemList reads the items of an ilst atom, every item holds one or
+// more data atoms:
+// Size                [uint32]
+// Type                "data"
+// Version             [1 byte]
+// Type indicator      [3 bytes]
+// Locale              [4 bytes]
+// Value               [binary data]
+func readMP4ItemList(r io.ReadSeeker, remaining int64, result map[string]any) error {
+	for remaining >= 8 {
+		name, size, headerSize, err := readMP4AtomHeader(r, remaining)
+		if err != nil {
+			return err
+		}
+		remaining -= int64(size)
+
+		b, err := readBytes(r, size-headerSize)
+		if err != nil {
+			return err
+		}
+
+		// freeform items are stored as "----:mean:name"
+		if name == "----" {
+			key, value, err := readMP4FreeformItem(b)
+			if err != nil {
+				return err
+			}
+			if key != "" {
+				result["----:"+key] = value
+			}
+			continue
+		}
+
+		if len(b) < 16 || string(b[4:8]) != "data" {
+			continue
+		}
+
+		// covr can hold several pictures, one per data atom
+		if name == "covr" {
+			pictures, err := readMP4CoverArt(b)
+			if err != nil {
+				return err
+			}
+			result[name] = pictures
+			continue
+		}
+
+		dataSize := uint(getInt(b[0:4]))
+		if dataSize < 16 || dataSize > uint(len(b)) {
+			return fmt.Errorf("invalid data atom size %d for %q", dataSize, name)
+		}
+
+		dataType := uint(getInt(b[9:12]))
+		data := b[16:dataSize]
+
+		switch name {
+		case "trkn", "disk":
+			// reserved [2 bytes], number [2 bytes], total [2 bytes]
+			if len(data) < 6 {
+				continue
+			}
+			result[name] = [2]int{getInt(data[2:4]), getInt(data[4:6])}
+
+		case "gnre":
+			// ID3v1 genre index + 1
+			if len(data) < 2 {
+				continue
+			}
+			if id := getInt(data[0:2]) - 1; id >= 0 && id < len(id3Genres) {
+				result[name] = id3Genres[id]
+			}
+
+		default:
+			switch dataType {
+			case mp4TypeUTF8:
+				result[name] = string(data)
+			case mp4TypeUTF16:
+				txt, err := decodeUTF16(data, binary.BigEndian)
+				if err != nil {
+					return err
+				}
+				result[name] = txt
+			case mp4TypeInteger:
+				result[name] = strconv.Itoa(getInt(data))
+			}
+		}
+	}
+
+	_, err := r.Seek(remaining, io.SeekCurrent)
+	return err
+}
+
+// readMP4FreeformItem reads a "----" item, the key is "mean:name" (e.g.
+// "com.apple.iTunes:MusicBrainz Album Id") and the value the first text data atom.
+// Mean                [uint32 size] "mean" [4 bytes version and flags] [string]
+// Name                [uint32 size] "name" [4 bytes version and flags] [string]
+// Data                [uint32 size] "data" [4 bytes type] [4 bytes locale] [value]
+func readMP4FreeformItem(b []byte) (key string, value string, err error) {
+	var mean, name string
+	for len(b) >= 8 {
+		size := uint(getInt(b[0:4]))
+		if size < 12 || size > uint(len(b)) {
+			return "", "", fmt.Errorf("invalid freeform atom size %d", size)
+		}
+
+		switch string(b[4:8]) {
+		case "mean":
+			mean = string(b[12:size])
+		case "name":
+			name = string(b[12:size])
+		case "data":
+			if size >= 16 && value == "" && uint(getInt(b[9:12])) == mp4TypeUTF8 {
+				value = string(b[16:size])
+			}
+		}
+		b = b[size:]
+	}
+
+	if name == "" {
+		return "", "", nil
+	}
+	return mean + ":" + name, value, nil
+}
+
+// readMP4CoverArt reads all the data atoms of a covr atom.
+func readMP4CoverArt(b []byte) ([]*Picture, error) {
+	var pictures []*Picture
+	for len(b) >= 16 && string(b[4:8]) == "data" {
+		dataSize := uint(getInt(b[0:4]))
+		if dataSize < 16 || dataSize > uint(len(b)) {
+			return nil, fmt.Errorf("invalid data atom size %d for %q", dataSize, "covr")
+		}
+
+		// MP4 has no picture type, consider every picture as a front cover
+		p := &Picture{
+			Type: pictureTypes[0x03],
+			Data: b[16:dataSize],
+		}
+		switch uint(getInt(b[9:12])) {
+		case mp4TypeJPEG, mp4TypeImplicit:
+			p.Ext, p.MIMEType = "jpg", "image/jpeg"
+		case mp4TypePNG:
+			p.Ext, p.MIMEType = "png", "image/png"
+		case mp4TypeBMP:
+			p.Ext, p.MIMEType = "bmp", "image/bmp"
+		}
+
+		pictures = append(pictures, p)
+		b = b[dataSize:]
+	}
+	return pictures, nil
+}
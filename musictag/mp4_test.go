@@ -0,0 +1,50 @@
+package musictag
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func TestReadMP4Tags(t *testing.T) {
+	for _, name := range []string{"sample.m4a", "sample.mp4"} {
+		m := readFixture(t, "with_tags", name)
+		if got := m.GetTagFormat(); got != ITunes {
+			t.Errorf("%s: tag format = %v, want %v", name, got, ITunes)
+		}
+		checkFixture(t, m, MP4, 0)
+
+		m = readFixture(t, "without_tags", name)
+		if got := m.GetTitle(); got != "" {
+			t.Errorf("%s: untagged file title = %q", name, got)
+		}
+	}
+}
+
+// mp4Atom returns an atom holding the concatenation of children.
+func mp4Atom(name string, children ...[]byte) []byte {
+	body := bytes.Join(children, nil)
+	b := binary.BigEndian.AppendUint32(nil, uint32(8+len(body)))
+	return append(append(b, name...), body...)
+}
+
+func TestReadMP4TagsMalformedMeta(t *testing.T) {
+	ftyp := mp4Atom("ftyp", []byte("M4A \x00\x00\x00\x00"))
+	tests := []struct {
+		name string
+		meta []byte
+	}{
+		{"no version", mp4Atom("meta")},
+		{"short version", mp4Atom("meta", []byte{0, 0})},
+		{"invalid child size", mp4Atom("meta", []byte{0, 0, 0, 0}, []byte("\x00\x00\x00\x40ilst"))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := bytes.Join([][]byte{ftyp, mp4Atom("moov", mp4Atom("udta", tt.meta))}, nil)
+			if _, err := ReadMP4Tags(bytes.NewReader(b)); !errors.Is(err, ErrMalformedTag) {
+				t.Errorf("error = %v, want %v", err, ErrMalformedTag)
+			}
+		})
+	}
+}
@@ -0,0 +1,316 @@
+package musictag
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// mp4Track holds the atoms of a trak needed to read the chapter tracks
+type mp4Track struct {
+	id         uint
+	chapterIDs []uint // tref/chap, IDs of the chapter tracks of this track
+	timescale  uint
+	durations  []mp4TimeToSample // stts
+	sampleSize uint              // stsz, size of every sample or 0 if the sizes are in sizes
+	samples    int               // stsz, number of samples
+	sizes      []uint
+	chunks     []mp4SampleToChunk
+	offsets    []uint // stco or co64
+}
+
+type mp4TimeToSample struct {
+	count    uint
+	duration uint
+}
+
+type mp4SampleToChunk struct {
+	firstChunk      uint
+	samplesPerChunk uint
+}
+
+// chapter titles are short, bigger samples are not titles
+const mp4MaxChapterSample = 1 << 16
+
+// more chapters are ignored, the number of samples comes from the file
+const mp4MaxChapters = 1 << 14
+
+// readMP4Track reads the atoms of a trak down to the sample table, depth
+// is the number of parent atoms.
+func readMP4Track(r io.ReadSeeker, remaining int64, depth int, t *mp4Track) error {
+	if depth > mp4MaxDepth {
+		return fmt.Errorf("%w: MP4 atoms nested more than %d levels deep", ErrMalformedTag, mp4MaxDepth)
+	}
+
+	for remaining >= 8 {
+		name, size, headerSize, err := readMP4AtomHeader(r, remaining)
+		if err != nil {
+			return err
+		}
+		remaining -= int64(size)
+		bodySize := int64(size - headerSize)
+
+		switch name {
+		case "tref", "mdia", "minf", "stbl":
+			err = readMP4Track(r, bodySize, depth+1, t)
+
+		case "tkhd", "mdhd", "chap", "stts", "stsz", "stsc", "stco", "co64":
+			var b []byte
+			b, err = readBytes(r, uint(bodySize))
+			if err != nil {
+				return err
+			}
+			err = t.readAtom(name, b)
+
+		default:
+			_, err = r.Seek(bodySize, io.SeekCurrent)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := r.Seek(remaining, io.SeekCurrent)
+	return err
+}
+
+// readAtom reads the body of a track atom, the full atoms start with
+// version [1 byte] and flags [3 bytes].
+func (t *mp4Track) readAtom(name string, b []byte) error {
+	if name == "chap" {
+		for ; len(b) >= 4; b = b[4:] {
+			t.chapterIDs = append(t.chapterIDs, uint(getInt(b[0:4])))
+		}
+		return nil
+	}
+
+	if len(b) < 8 {
+		return fmt.Errorf("invalid size %d for atom %q", len(b), name)
+	}
+	version := b[0]
+
+	switch name {
+	case "tkhd":
+		// creation and modification times are 64 bit in version 1
+		if version == 1 && len(b) >= 24 {
+			t.id = uint(getInt(b[20:24]))
+		} else if len(b) >= 16 {
+			t.id = uint(getInt(b[12:16]))
+		}
+
+	case "mdhd":
+		if version == 1 && len(b) >= 24 {
+			t.timescale = uint(getInt(b[20:24]))
+		} else if len(b) >= 16 {
+			t.timescale = uint(getInt(b[12:16]))
+		}
+
+	case "stts":
+		for _, e := range mp4Table(b[8:], getInt(b[4:8]), 8) {
+			t.durations = append(t.durations, mp4TimeToSample{uint(getInt(e[0:4])), uint(getInt(e[4:8]))})
+		}
+
+	case "stsz":
+		if len(b) < 12 {
+			return fmt.Errorf("invalid size %d for atom %q", len(b), name)
+		}
+		t.sampleSize, t.samples = uint(getInt(b[4:8])), getInt(b[8:12])
+		if t.sampleSize != 0 {
+			// every sample has the same size, there is no table
+			return nil
+		}
+		for _, e := range mp4Table(b[12:], t.samples, 4) {
+			t.sizes = append(t.sizes, uint(getInt(e)))
+		}
+		t.samples = len(t.sizes)
+
+	case "stsc":
+		for _, e := range mp4Table(b[8:], getInt(b[4:8]), 12) {
+			t.chunks = append(t.chunks, mp4SampleToChunk{uint(getInt(e[0:4])), uint(getInt(e[4:8]))})
+		}
+
+	case "stco":
+		for _, e := range mp4Table(b[8:], getInt(b[4:8]), 4) {
+			t.offsets = append(t.offsets, uint(getInt(e)))
+		}
+
+	case "co64":
+		for _, e := range mp4Table(b[8:], getInt(b[4:8]), 8) {
+			t.offsets = append(t.offsets, uint(getInt(e)))
+		}
+	}
+	return nil
+}
+
+// mp4Table splits the entries of a sample table, the count is bounded by
+// the size of the table.
+func mp4Table(b []byte, count int, entrySize int) [][]byte {
+	count = min(count, len(b)/entrySize)
+	entries := make([][]byte, count)
+	for i := range entries {
+		entries[i] = b[i*entrySize : (i+1)*entrySize]
+	}
+	return entries
+}
+
+// size returns the size of the sample i
+func (t *mp4Track) size(i int) uint {
+	if t.sampleSize != 0 {
+		return t.sampleSize
+	}
+	return t.sizes[i]
+}
+
+// sampleOffsets returns the file offset of the first n samples of the track.
+func (t *mp4Track) sampleOffsets(n int) []uint {
+	var offsets []uint
+	var perChunk uint
+	next := 0
+	for i, offset := range t.offsets {
+		if len(offsets) >= n {
+			break
+		}
+
+		// the entry of the last first chunk before this chunk applies, the
+		// entries are sorted by first chunk
+		for ; next < len(t.chunks) && t.chunks[next].firstChunk <= uint(i+1); next++ {
+			perChunk = t.chunks[next].samplesPerChunk
+		}
+
+		for j := uint(0); j < perChunk && len(offsets) < n; j++ {
+			offsets = append(offsets, offset)
+			offset += t.size(len(offsets) - 1)
+		}
+	}
+	return offsets
+}
+
+// readMP4ChapterTrack reads the chapters of a QuickTime chapter track, a
+// text track where every sample is a title:
+// Text length         [uint16]
+// Text                [UTF-8 or UTF-16 with BOM]
+// The start time of a title is the time of its sample.
+func readMP4ChapterTrack(r io.ReadSeeker, t *mp4Track) ([]Chapter, error) {
+	if t.timescale == 0 {
+		return nil, fmt.Errorf("chapter track %d has no timescale", t.id)
+	}
+
+	samples := min(t.samples, mp4MaxChapters)
+
+	var starts []time.Duration
+	var ends []time.Duration
+	var elapsed uint
+	for _, e := range t.durations {
+		for i := uint(0); i < e.count && len(starts) < samples; i++ {
+			starts = append(starts, time.Duration(elapsed)*time.Second/time.Duration(t.timescale))
+			elapsed += e.duration
+			ends = append(ends, time.Duration(elapsed)*time.Second/time.Duration(t.timescale))
+		}
+	}
+
+	var chapters []Chapter
+	for i, offset := range t.sampleOffsets(samples) {
+		if i >= len(starts) {
+			break
+		}
+
+		size := t.size(i)
+		if size < 2 || size > mp4MaxChapterSample {
+			continue
+		}
+
+		if _, err := r.Seek(int64(offset), io.SeekStart); err != nil {
+			return nil, err
+		}
+		b, err := readBytes(r, size)
+		if err != nil {
+			return nil, err
+		}
+
+		textLen := uint(getInt(b[0:2]))
+		if textLen > size-2 {
+			return nil, fmt.Errorf("invalid chapter title length %d", textLen)
+		}
+
+		title := string(b[2 : 2+textLen])
+		if textLen >= 2 && b[2] == 0xFE && b[3] == 0xFF {
+			title, err = decodeUTF16WithBOM(b[2 : 2+textLen])
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		chapters = append(chapters, Chapter{Title: title, Start: starts[i], End: ends[i]})
+	}
+	return chapters, nil
+}
+
+// readMP4Chapters returns the chapters of the chapter track referenced by
+// an other track, or the Nero chapters (chpl atom).
+func readMP4Chapters(r io.ReadSeeker, tracks []*mp4Track, nero []Chapter) ([]Chapter, error) {
+	byID := make(map[uint]*mp4Track, len(tracks))
+	for _, t := range tracks {
+		byID[t.id] = t
+	}
+
+	for _, t := range tracks {
+		for _, id := range t.chapterIDs {
+			chapterTrack, ok := byID[id]
+			if !ok {
+				continue
+			}
+
+			chapters, err := readMP4ChapterTrack(r, chapterTrack)
+			if err != nil {
+				return nil, err
+			}
+			if len(chapters) > 0 {
+				return chapters, nil
+			}
+		}
+	}
+	return nero, nil
+}
+
+// readMP4NeroChapters reads a chpl atom
+// Version             [1 byte]
+// Flags               [3 bytes]
+// Reserved            [4 bytes, only if version is 1]
+// Chapter count       [1 byte]
+// For every chapter:
+// Start time          [uint64, 100 nanoseconds units]
+// Title length        [1 byte]
+// Title               [UTF-8 string]
+func readMP4NeroChapters(b []byte) ([]Chapter, error) {
+	if len(b) < 5 {
+		return nil, fmt.Errorf("invalid size %d for atom %q", len(b), "chpl")
+	}
+
+	if b[0] == 1 {
+		b = b[4:]
+	}
+	if len(b) < 5 {
+		return nil, fmt.Errorf("invalid size %d for atom %q", len(b), "chpl")
+	}
+
+	count := int(b[4])
+	b = b[5:]
+
+	var chapters []Chapter
+	for i := 0; i < count; i++ {
+		if len(b) < 9 || len(b) < 9+int(b[8]) {
+			return nil, fmt.Errorf("invalid chpl atom: %d chapters expected, got %d", count, i)
+		}
+
+		titleLen := int(b[8])
+		chapters = append(chapters, Chapter{
+			Title: string(b[9 : 9+titleLen]),
+			Start: time.Duration(getInt(b[0:8])) * 100,
+		})
+		b = b[9+titleLen:]
+	}
+
+	sortChapters(chapters)
+	return chapters, nil
+}
@@ -0,0 +1,370 @@
+package musictag
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+var ErrNoMPEGFrame = errors.New("No MPEG audio frame found")
+
+// AudioProperties represents the technical properties of the audio stream.
+type AudioProperties struct {
+	Duration    time.Duration
+	Bitrate     int    // average bitrate in kbps
+	SampleRate  int    // sample rate in Hz
+	Channels    int    // number of channels
+	ChannelMode string // Stereo, Joint stereo, Dual channel or Mono
+	VBR         bool   // variable bitrate (Xing or VBRI header found)
+	Encoder     string // encoder from the LAME tag (e.g. LAME3.100)
+}
+
+type mpegVersion int
+
+const (
+	mpeg2_5 mpegVersion = iota
+	mpegReserved
+	mpeg2
+	mpeg1
+)
+
+// bitrates in kbps indexed by [MPEG1][layer][bitrate index], layer 0 is layer I
+var mpegBitrates = [2][3][16]int{
+	// MPEG2 and MPEG2.5
+	{
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256, 0},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+	},
+	// MPEG1
+	{
+		{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448, 0},
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384, 0},
+		{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0},
+	},
+}
+
+var mpegSampleRates = map[mpegVersion][3]int{
+	mpeg1:   {44100, 48000, 32000},
+	mpeg2:   {22050, 24000, 16000},
+	mpeg2_5: {11025, 12000, 8000},
+}
+
+var mpegChannelModes = [4]string{"Stereo", "Joint stereo", "Dual channel", "Mono"}
+
+// mpegFrameHeader represents the 4 byte header of a MPEG audio frame.
+// Frame sync          11 bits
+// Version             2 bits (0: MPEG2.5, 2: MPEG2, 3: MPEG1)
+// Layer               2 bits (1: layer III, 2: layer II, 3: layer I)
+// Protection          1 bit
+// Bitrate index       4 bits
+// Sample rate index   2 bits
+// Padding             1 bit
+// Private             1 bit
+// Channel mode        2 bits
+// ...
+type mpegFrameHeader struct {
+	Version     mpegVersion
+	Layer       int // 1, 2 or 3
+	Bitrate     int // kbps
+	SampleRate  int
+	Padding     bool
+	ChannelMode byte
+}
+
+func parseMPEGFrameHeader(b []byte) (*mpegFrameHeader, bool) {
+	if len(b) < 4 || b[0] != 0xFF || b[1]&0xE0 != 0xE0 {
+		return nil, false
+	}
+
+	version := mpegVersion((b[1] >> 3) & 0x03)
+	layerBits := (b[1] >> 1) & 0x03
+	bitrateIndex := b[2] >> 4
+	sampleRateIndex := (b[2] >> 2) & 0x03
+
+	if version == mpegReserved || layerBits == 0 || bitrateIndex == 0 || bitrateIndex == 0x0F || sampleRateIndex == 0x03 {
+		return nil, false
+	}
+
+	h := &mpegFrameHeader{
+		Version:     version,
+		Layer:       4 - int(layerBits),
+		SampleRate:  mpegSampleRates[version][sampleRateIndex],
+		Padding:     getBit(b[2], 1),
+		ChannelMode: b[3] >> 6,
+	}
+
+	v1 := 0
+	if version == mpeg1 {
+		v1 = 1
+	}
+	h.Bitrate = mpegBitrates[v1][h.Layer-1][bitrateIndex]
+	return h, true
+}
+
+func (h *mpegFrameHeader) samplesPerFrame() int {
+	switch {
+	case h.Layer == 1:
+		return 384
+	case h.Layer == 3 && h.Version != mpeg1:
+		return 576
+	}
+	return 1152
+}
+
+// frameSize returns the size of the frame in bytes including the header
+func (h *mpegFrameHeader) frameSize() int {
+	if h.Layer == 1 {
+		size := 12 * h.Bitrate * 1000 / h.SampleRate
+		if h.Padding {
+			size++
+		}
+		return size * 4
+	}
+
+	size := h.samplesPerFrame() / 8 * h.Bitrate * 1000 / h.SampleRate
+	if h.Padding {
+		size++
+	}
+	return size
+}
+
+// sideInfoSize returns the size of the layer III side information, which is
+// where the Xing header starts after the frame header.
+func (h *mpegFrameHeader) sideInfoSize() int {
+	mono := h.ChannelMode == 3
+	switch {
+	case h.Version == mpeg1 && mono:
+		return 17
+	case h.Version == mpeg1:
+		return 32
+	case mono:
+		return 9
+	}
+	return 17
+}
+
+// max bytes to scan for the first frame after the ID3v2 tag
+const mpegSyncSearchMax = 64 << 10
+
+// ReadAudioProperties reads the audio properties of a MPEG audio (MP3) file
+// from the first frame after the ID3v2 tag and its Xing/Info or VBRI header.
+func ReadAudioProperties(r io.ReadSeeker) (*AudioProperties, error) {
+	start, err := leadingID3v2End(r)
+	if err != nil {
+		return nil, err
+	}
+
+	end, err := audioDataEnd(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = r.Seek(start, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	buf, err := io.ReadAll(io.LimitReader(r, mpegSyncSearchMax))
+	if err != nil {
+		return nil, err
+	}
+
+	offset, h := findMPEGFrame(buf)
+	if h == nil {
+		return nil, ErrNoMPEGFrame
+	}
+
+	frame := buf[offset:]
+	if len(frame) > h.frameSize() {
+		frame = frame[:h.frameSize()]
+	}
+
+	p := &AudioProperties{
+		Bitrate:     h.Bitrate,
+		SampleRate:  h.SampleRate,
+		Channels:    2,
+		ChannelMode: mpegChannelModes[h.ChannelMode],
+	}
+	if h.ChannelMode == 3 {
+		p.Channels = 1
+	}
+
+	audioSize := end - start - int64(offset)
+	frames, size := readXingHeader(frame, h, p)
+	if frames == 0 {
+		frames, size = readVBRIHeader(frame, p)
+	}
+
+	if frames > 0 {
+		p.Duration = time.Duration(frames) * time.Duration(h.samplesPerFrame()) * time.Second / time.Duration(h.SampleRate)
+		if size == 0 {
+			size = audioSize
+		}
+		if p.Duration > 0 {
+			p.Bitrate = int(size * 8 * int64(time.Second) / int64(p.Duration) / 1000)
+		}
+		return p, nil
+	}
+
+	// constant bitrate
+	if audioSize > 0 {
+		p.Duration = time.Duration(audioSize*8*1000/int64(h.Bitrate)) * time.Microsecond
+	}
+	return p, nil
+}
+
+// findMPEGFrame returns the offset of the first frame header which is
+// followed by a second valid frame header.
+func findMPEGFrame(b []byte) (int, *mpegFrameHeader) {
+	for i := 0; i+4 <= len(b); i++ {
+		h, ok := parseMPEGFrameHeader(b[i:])
+		if !ok {
+			continue
+		}
+
+		next := i + h.frameSize()
+		if next+4 > len(b) {
+			// can't check the next frame, trust this one
+			return i, h
+		}
+
+		if n, ok := parseMPEGFrameHeader(b[next:]); ok && n.Version == h.Version && n.Layer == h.Layer {
+			return i, h
+		}
+	}
+	return 0, nil
+}
+
+// readXingHeader reads the Xing/Info header and LAME tag of the first frame.
+// ID                  "Xing" (VBR) or "Info" (CBR)
+// Flags               [uint32] (1: frames, 2: bytes, 4: TOC, 8: quality)
+// Frames              [uint32]
+// Bytes               [uint32]
+// TOC                 [100 bytes]
+// Quality             [uint32]
+// Encoder             [9 bytes] (LAME tag)
+func readXingHeader(frame []byte, h *mpegFrameHeader, p *AudioProperties) (frames int64, size int64) {
+	offset := 4 + h.sideInfoSize()
+	if len(frame) < offset+8 {
+		return 0, 0
+	}
+
+	b := frame[offset:]
+	id := string(b[0:4])
+	if id != "Xing" && id != "Info" {
+		return 0, 0
+	}
+
+	flags := getInt(b[4:8])
+	b = b[8:]
+
+	if flags&0x01 != 0 && len(b) >= 4 {
+		frames = int64(getInt(b[0:4]))
+		b = b[4:]
+	}
+
+	if flags&0x02 != 0 && len(b) >= 4 {
+		size = int64(getInt(b[0:4]))
+		b = b[4:]
+	}
+
+	if flags&0x04 != 0 && len(b) >= 100 {
+		b = b[100:]
+	}
+
+	if flags&0x08 != 0 && len(b) >= 4 {
+		b = b[4:]
+	}
+
+	if len(b) >= 9 {
+		encoder := string(bytes.TrimRight(b[:9], "\x00 "))
+		if strings.HasPrefix(encoder, "LAME") || strings.HasPrefix(encoder, "Lavc") || strings.HasPrefix(encoder, "Lavf") {
+			p.Encoder = encoder
+		}
+	}
+
+	p.VBR = id == "Xing"
+	return frames, size
+}
+
+// readVBRIHeader reads the Fraunhofer VBRI header, always located 32 bytes
+// after the frame header.
+// ID                  "VBRI"
+// Version             [uint16]
+// Delay               [uint16]
+// Quality             [uint16]
+// Bytes               [uint32]
+// Frames              [uint32]
+func readVBRIHeader(frame []byte, p *AudioProperties) (frames int64, size int64) {
+	const offset = 4 + 32
+	if len(frame) < offset+18 {
+		return 0, 0
+	}
+
+	b := frame[offset:]
+	if string(b[0:4]) != "VBRI" {
+		return 0, 0
+	}
+
+	p.VBR = true
+	return int64(getInt(b[14:18])), int64(getInt(b[10:14]))
+}
+
+// audioDataEnd returns the offset where the audio data ends, before the
+// APE, Lyrics3v2, ID3v1 and appended ID3v2 tags.
+func audioDataEnd(r io.ReadSeeker) (int64, error) {
+	end, err := findTrailingTagsEnd(r)
+	if err != nil {
+		return 0, err
+	}
+
+	start, err := findAPETagStart(r, end)
+	if err != nil {
+		return 0, err
+	}
+	if start < 0 {
+		return end, nil
+	}
+
+	// the ID3v2 tag can be appended before the APE tag
+	end = start
+	if start, err = findAppendedID3v2Tag(r, end); err != nil {
+		return 0, err
+	} else if start >= 0 {
+		end = start
+	}
+	return end, nil
+}
+
+// findAPETagStart returns the offset of the APE tag (including its header)
+// ending at end, -1 if there is none.
+func findAPETagStart(r io.ReadSeeker, end int64) (int64, error) {
+	if end < 32 {
+		return -1, nil
+	}
+
+	if _, err := r.Seek(end-32, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	footer, err := readBytes(r, 32)
+	if err != nil {
+		return 0, err
+	}
+
+	if string(footer[0:8]) != "APETAGEX" {
+		return -1, nil
+	}
+
+	size := int64(getIntLittleEndian(footer[12:16]))
+	// header present
+	if getBit(footer[23], 7) {
+		size += 32
+	}
+	if size > end {
+		return -1, nil
+	}
+	return end - size, nil
+}
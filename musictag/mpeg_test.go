@@ -0,0 +1,81 @@
+package musictag
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestReadAudioProperties(t *testing.T) {
+	// the samples are the same 128 kbit/s CBR stream of 132 frames
+	tests := []struct {
+		dir, name string
+	}{
+		{"with_tags", "sample.id3v11.mp3"},
+		{"with_tags", "sample.id3v22.mp3"},
+		{"with_tags", "sample.id3v23.mp3"},
+		{"with_tags", "sample.id3v24.mp3"},
+		{"without_tags", "sample.mp3"},
+	}
+	want := AudioProperties{
+		Duration:    3448125 * time.Microsecond,
+		Bitrate:     128,
+		SampleRate:  44100,
+		Channels:    2,
+		ChannelMode: "Joint stereo",
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := ReadAudioProperties(bytes.NewReader(readTestdata(t, tt.dir, tt.name)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *p != want {
+				t.Errorf("ReadAudioProperties() = %+v, want %+v", *p, want)
+			}
+		})
+	}
+}
+
+func TestReadAudioPropertiesInvalid(t *testing.T) {
+	sample := readTestdata(t, "with_tags", "sample.id3v24.mp3")
+	garbage := make([]byte, 100<<10)
+	rand.New(rand.NewSource(1)).Read(garbage)
+	for i := range garbage {
+		// no frame sync
+		garbage[i] &= 0x7F
+	}
+
+	tests := []struct {
+		name string
+		b    []byte
+	}{
+		{"empty", nil},
+		{"garbage", garbage},
+		{"truncated tag", sample[:500]},
+		{"tag only", sample[:1034]},
+		{"truncated frame header", sample[:1036]},
+		{"FLAC", readTestdata(t, "with_tags", "sample.flac")[:4096]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			done := make(chan error, 1)
+			go func() {
+				_, err := ReadAudioProperties(bytes.NewReader(tt.b))
+				done <- err
+			}()
+
+			select {
+			case err := <-done:
+				if !errors.Is(err, ErrNoMPEGFrame) {
+					t.Errorf("error = %v, want %v", err, ErrNoMPEGFrame)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("ReadAudioProperties doesn't return")
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package musictag
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var ErrNotOgg = errors.New("Invalid Ogg file")
+
+// oggPageHeader represent the 27 byte header of a ogg page followed by the segment table
+// (see https://xiph.org/ogg/doc/framing.html)
+type oggPageHeader struct {
+	Version      byte
+	HeaderType   byte
+	Serial       uint
+	Sequence     uint
+	SegmentTable []byte
+}
+
+// readOggPageHeader reads the ogg page header.
+// Capture pattern     "OggS"
+// Version             [1 byte]
+// Header type         [1 byte]
+// Granule position    [8 bytes]
+// Serial number       [uint32 little endian]
+// Page sequence       [uint32 little endian]
+// Checksum            [4 bytes]
+// Page segments       [1 byte]
+// Segment table       [page segments bytes]
+func readOggPageHeader(r io.Reader) (*oggPageHeader, error) {
+	b, err := readBytes(r, 27)
+	if err != nil {
+		return nil, err
+	}
+
+	if string(b[0:4]) != "OggS" {
+		return nil, ErrNotOgg
+	}
+
+	segmentTable, err := readBytes(r, uint(b[26]))
+	if err != nil {
+		return nil, err
+	}
+
+	return &oggPageHeader{
+		Version:      b[4],
+		HeaderType:   b[5],
+		Serial:       uint(getIntLittleEndian(b[14:18])),
+		Sequence:     uint(getIntLittleEndian(b[18:22])),
+		SegmentTable: segmentTable,
+	}, nil
+}
+
+// readOggPackets reads the first n packets of the first logical stream,
+// reassembling packets spanning multiple pages.
+func readOggPackets(r io.ReadSeeker, n int) ([][]byte, error) {
+	var packets [][]byte
+	var packet []byte
+	var serial uint
+
+	for page := 0; len(packets) < n; page++ {
+		h, err := readOggPageHeader(r)
+		if err != nil {
+			return nil, err
+		}
+
+		var pageSize int64
+		for _, l := range h.SegmentTable {
+			pageSize += int64(l)
+		}
+
+		if page == 0 {
+			serial = h.Serial
+		} else if h.Serial != serial {
+			// page of an other multiplexed stream
+			if _, err := r.Seek(pageSize, io.SeekCurrent); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		for _, l := range h.SegmentTable {
+			b, err := readBytes(r, uint(l))
+			if err != nil {
+				return nil, err
+			}
+			packet = append(packet, b...)
+
+			// a lacing value less than 255 terminates the packet
+			if l < 255 {
+				packets = append(packets, packet)
+				packet = nil
+			}
+		}
+	}
+
+	return packets[:n], nil
+}
+
+// ReadOggTags reads the comment header of a Ogg Vorbis, Ogg Opus or Ogg FLAC
+// file.
+func ReadOggTags(r io.ReadSeeker) (Metadata, error) {
+	packets, err := readOggPackets(r, 2)
+	if err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrNotOgg
+		}
+		return nil, err
+	}
+
+	m := VorbisMetadata{}
+
+	var comment []byte
+	switch {
+	case bytes.HasPrefix(packets[0], []byte("\x01vorbis")):
+		if !bytes.HasPrefix(packets[1], []byte("\x03vorbis")) {
+			return nil, fmt.Errorf("%w: expected vorbis comment header", ErrNotOgg)
+		}
+		m.fileType = OGG
+		comment = packets[1][7:]
+
+	case bytes.HasPrefix(packets[0], []byte("OpusHead")):
+		if !bytes.HasPrefix(packets[1], []byte("OpusTags")) {
+			return nil, fmt.Errorf("%w: expected OpusTags header", ErrNotOgg)
+		}
+		m.fileType = OPUS
+		comment = packets[1][8:]
+
+	case bytes.HasPrefix(packets[0], []byte("\x7fFLAC")):
+		return readOggFLACTags(r, packets[0])
+
+	default:
+		return nil, fmt.Errorf("%w: unsupported codec", ErrNotOgg)
+	}
+
+	m.vendor, m.comments, m.pictures, err = readVorbisComment(bytes.NewReader(comment))
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// readOggFLACTags reads the VORBIS_COMMENT and PICTURE metadata blocks of a
+// Ogg FLAC file, every metadata block following STREAMINFO is a header
+// packet and VORBIS_COMMENT is the first one. ErrNoTagFound is returned
+// without these blocks.
+// First packet        $7F "FLAC"
+// Mapping version     [major, minor]
+// Header packets      [uint16 big endian] (without the first one, 0 if unknown)
+// Native signature    "fLaC"
+// STREAMINFO          [metadata block]
+func readOggFLACTags(r io.ReadSeeker, first []byte) (Metadata, error) {
+	if len(first) < 13 || string(first[9:13]) != "fLaC" {
+		return nil, fmt.Errorf("%w: invalid FLAC mapping header", ErrNotOgg)
+	}
+
+	headers := getInt(first[7:9])
+	if headers == 0 {
+		headers = 1
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	packets, err := readOggPackets(r, 1+headers)
+	if err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrNotOgg
+		}
+		return nil, err
+	}
+
+	m := VorbisMetadata{
+		fileType: OGG,
+		comments: make(map[string][]string),
+	}
+	found := false
+	for _, p := range packets[1:] {
+		if len(p) < 4 {
+			return nil, fmt.Errorf("%w: invalid FLAC metadata block", ErrMalformedTag)
+		}
+
+		switch p[0] & 0x7F {
+		case flacVorbisCommentBlock:
+			var pictures []*Picture
+			m.vendor, m.comments, pictures, err = readVorbisComment(bytes.NewReader(p[4:]))
+			if err != nil {
+				return nil, err
+			}
+			m.pictures = append(m.pictures, pictures...)
+			found = true
+
+		case flacPictureBlock:
+			pic, err := readPictureBlock(bytes.NewReader(p[4:]))
+			if err != nil {
+				return nil, err
+			}
+			m.pictures = append(m.pictures, pic)
+			found = true
+		}
+	}
+
+	if !found {
+		return nil, ErrNoTagFound
+	}
+	return m, nil
+}
@@ -0,0 +1,91 @@
+package musictag
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestReadOggTags(t *testing.T) {
+	// the picture of sample.multipage.ogg spans several pages
+	for name, pictures := range map[string]int{"sample.ogg": 0, "sample.multipage.ogg": 1} {
+		m := readFixture(t, "with_tags", name)
+		if got := m.GetTagFormat(); got != VorbisComment {
+			t.Errorf("%s: tag format = %v, want %v", name, got, VorbisComment)
+		}
+		checkFixture(t, m, OGG, pictures)
+	}
+
+	m := readFixture(t, "without_tags", "sample.ogg")
+	if got := m.GetTitle(); got != "" {
+		t.Errorf("untagged file title = %q", got)
+	}
+}
+
+// oggFLAC maps the native FLAC file to Ogg, keeping the metadata blocks for
+// which keep returns true. The header packets are counted if counted is set.
+func oggFLAC(t *testing.T, native []byte, counted bool, keep func(blockType byte) bool) []byte {
+	t.Helper()
+	if string(native[0:4]) != "fLaC" {
+		t.Fatal("not a FLAC file")
+	}
+
+	var blocks [][]byte
+	offset := 4
+	for {
+		size := getInt(native[offset+1 : offset+4])
+		block := bytes.Clone(native[offset : offset+4+size])
+		offset += 4 + size
+		if len(blocks) == 0 || keep(block[0]&0x7F) {
+			block[0] &= 0x7F
+			blocks = append(blocks, block)
+		}
+		if native[offset-4-size]&0x80 != 0 {
+			break
+		}
+	}
+	blocks[len(blocks)-1][0] |= 0x80
+
+	var headers uint16
+	if counted {
+		headers = uint16(len(blocks) - 1)
+	}
+	first := append([]byte("\x7fFLAC\x01\x00"), byte(headers>>8), byte(headers))
+	first = append(first, "fLaC"...)
+	first = append(first, blocks[0]...)
+
+	b := oggPage(1, 0, first)
+	b = append(b, oggPage(1, 1, blocks[1:]...)...)
+	// the first audio frame
+	return append(b, oggPage(1, 2, native[offset:offset+100])...)
+}
+
+func TestReadOggFLACTags(t *testing.T) {
+	native := readTestdata(t, "with_tags", "sample.flac")
+	all := func(byte) bool { return true }
+
+	for _, counted := range []bool{true, false} {
+		m, err := ReadOggTags(bytes.NewReader(oggFLAC(t, native, counted, all)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkFixture(t, m, OGG, 0)
+	}
+
+	// the picture blocks are read
+	picture := append([]byte{flacPictureBlock, 0, 0, 0}, pictureBlock("image/jpeg", []byte("jpeg data"))...)
+	picture[3] = byte(len(picture) - 4)
+	withPicture := append(native[:4:4], native[4:4+4+34]...)
+	withPicture = append(withPicture, picture...)
+	withPicture = append(withPicture, native[4+4+34:]...)
+	m, err := ReadOggTags(bytes.NewReader(oggFLAC(t, withPicture, true, all)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFixture(t, m, OGG, 1)
+
+	untagged := oggFLAC(t, native, true, func(blockType byte) bool { return blockType != flacVorbisCommentBlock })
+	if _, err := ReadOggTags(bytes.NewReader(untagged)); !errors.Is(err, ErrNoTagFound) {
+		t.Errorf("ReadOggTags(untagged) error = %v, want %v", err, ErrNoTagFound)
+	}
+}
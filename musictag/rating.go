@@ -0,0 +1,122 @@
+package musictag
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Popularimeter is a POPM frame, the rating and the play counter of the
+// track for the user identified by Email.
+type Popularimeter struct {
+	Email   string
+	Rating  byte // 1 (worst) to 255 (best), 0 if unknown
+	Counter uint64
+}
+
+// readPOPMFrame reads a popularimeter frame
+// <Header for 'Popularimeter', ID: "POPM">
+// Email to user   <text string> $00
+// Rating          $xx
+// Counter         $xx xx xx xx (xx ...)
+// The counter is at least 32 bit long and can be omitted.
+func readPOPMFrame(b []byte) (*Popularimeter, error) {
+	email, b, ok := cutTerminatedText(b, encodingISO8859)
+	if !ok || len(b) < 1 {
+		return nil, errors.New("POPM frame too short")
+	}
+
+	return &Popularimeter{
+		Email:   decodeISO8859(email),
+		Rating:  b[0],
+		Counter: readCounter(b[1:]),
+	}, nil
+}
+
+// readCounter reads the play counter of PCNT and POPM frames, a big endian
+// integer of at least 4 bytes (0 if empty).
+func readCounter(b []byte) uint64 {
+	if len(b) > 8 {
+		// the counter can grow past 64 bit, keep the maximum value
+		for _, x := range b[:len(b)-8] {
+			if x != 0 {
+				return math.MaxUint64
+			}
+		}
+		b = b[len(b)-8:]
+	}
+
+	var n uint64
+	for _, x := range b {
+		n = n<<8 | uint64(x)
+	}
+	return n
+}
+
+// encodeCounter returns the counter on 4 bytes, or 8 if it does not fit
+func encodeCounter(n uint64) []byte {
+	size := 4
+	if n > math.MaxUint32 {
+		size = 8
+	}
+
+	b := make([]byte, size)
+	for i := size - 1; i >= 0; i-- {
+		b[i] = byte(n)
+		n >>= 8
+	}
+	return b
+}
+
+// POPM ratings written for 1 to 5 stars, as done by Windows Media Player
+var popmStarRatings = [6]byte{0, 1, 64, 128, 196, 255}
+
+// popmStars converts a POPM rating (1-255) to stars (1-5), 0 is unrated.
+func popmStars(rating byte) int {
+	switch {
+	case rating == 0:
+		return 0
+	case rating < 32:
+		return 1
+	case rating < 96:
+		return 2
+	case rating < 160:
+		return 3
+	case rating < 224:
+		return 4
+	default:
+		return 5
+	}
+}
+
+// StarsToPOPM converts stars (0-5) to a POPM rating.
+func StarsToPOPM(stars int) byte {
+	return popmStarRatings[max(0, min(stars, 5))]
+}
+
+// FMPS fields used by vorbis comments, APEv2 and MP4 freeform items
+// (see https://www.freedesktop.org/wiki/Specifications/free-media-player-specs/)
+const (
+	fmpsRating    = "FMPS_RATING"
+	fmpsPlayCount = "FMPS_PLAYCOUNT"
+)
+
+// parseFMPSRating converts a FMPS rating (0.0 to 1.0) to stars (0-5).
+func parseFMPSRating(s string) int {
+	rating, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || rating < 0 || rating > 1 {
+		return 0
+	}
+	return int(math.Round(rating * 5))
+}
+
+// parseFMPSPlayCount parse a FMPS play count, a decimal number which can
+// have a fractional part for partial plays.
+func parseFMPSPlayCount(s string) int {
+	count, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || count < 0 {
+		return 0
+	}
+	return int(count)
+}
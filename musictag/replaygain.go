@@ -0,0 +1,114 @@
+package musictag
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ReplayGain holds the ReplayGain values of a track, the gains are in dB
+// relative to the ReplayGain reference level and the peaks are the linear
+// sample peaks (1.0 is full scale, 0 if unknown).
+type ReplayGain struct {
+	TrackGain float64
+	TrackPeak float64
+	AlbumGain float64
+	AlbumPeak float64
+	HasTrack  bool
+	HasAlbum  bool
+}
+
+// ReplayGain fields (ID3v2 TXXX, Vorbis comment, MP4 freeform and APE item)
+const (
+	replayGainTrackGain = "REPLAYGAIN_TRACK_GAIN"
+	replayGainTrackPeak = "REPLAYGAIN_TRACK_PEAK"
+	replayGainAlbumGain = "REPLAYGAIN_ALBUM_GAIN"
+	replayGainAlbumPeak = "REPLAYGAIN_ALBUM_PEAK"
+)
+
+// Opus gains are relative to -23 LUFS (EBU R128) instead of the ReplayGain
+// reference level of about -18 LUFS.
+const opusR128Offset = 5.0
+
+// ReadReplayGain reads the ReplayGain values of the tag from the
+// REPLAYGAIN_* fields, falling back to the Opus R128_*_GAIN fields and to the
+// iTunes iTunNORM comment (track gain only).
+func ReadReplayGain(m Metadata) ReplayGain {
+	var rg ReplayGain
+
+	rg.TrackGain, rg.HasTrack = parseGain(m.GetCustom(replayGainTrackGain))
+	rg.TrackPeak, _ = strconv.ParseFloat(strings.TrimSpace(m.GetCustom(replayGainTrackPeak)), 64)
+	rg.AlbumGain, rg.HasAlbum = parseGain(m.GetCustom(replayGainAlbumGain))
+	rg.AlbumPeak, _ = strconv.ParseFloat(strings.TrimSpace(m.GetCustom(replayGainAlbumPeak)), 64)
+
+	if !rg.HasTrack {
+		if gain, ok := parseOpusGain(m.GetCustom("R128_TRACK_GAIN")); ok {
+			rg.TrackGain, rg.HasTrack = gain, true
+		}
+	}
+
+	if !rg.HasAlbum {
+		if gain, ok := parseOpusGain(m.GetCustom("R128_ALBUM_GAIN")); ok {
+			rg.AlbumGain, rg.HasAlbum = gain, true
+		}
+	}
+
+	if !rg.HasTrack {
+		if gain, peak, ok := parseITunNORM(m.GetCustom("iTunNORM")); ok {
+			rg.TrackGain, rg.TrackPeak, rg.HasTrack = gain, peak, true
+		}
+	}
+
+	return rg
+}
+
+// parseGain parse a gain like "-6.48 dB"
+func parseGain(s string) (float64, bool) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimSpace(strings.TrimSuffix(strings.TrimSuffix(s, "dB"), "db"))
+	gain, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false
+	}
+	return gain, true
+}
+
+// parseOpusGain parse a R128 gain, a Q7.8 fixed point number in dB
+func parseOpusGain(s string) (float64, bool) {
+	q, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return 0, false
+	}
+	return float64(q)/256 + opusR128Offset, true
+}
+
+// parseITunNORM parse the iTunes Sound Check comment, 10 hexadecimal numbers:
+// 1-2   volume adjustment of the left and right channel (1/1000 W base)
+// 3-4   volume adjustment of the left and right channel (1/2500 W base)
+// 5-6   not used here
+// 7-8   peak values of the left and right channel (16 bit sample)
+// 9-10  not used here
+func parseITunNORM(s string) (gain float64, peak float64, ok bool) {
+	fields := strings.Fields(s)
+	if len(fields) < 10 {
+		return 0, 0, false
+	}
+
+	var v [10]uint64
+	for i := range v {
+		n, err := strconv.ParseUint(fields[i], 16, 32)
+		if err != nil {
+			return 0, 0, false
+		}
+		v[i] = n
+	}
+
+	adjustment := max(v[0], v[1])
+	if adjustment == 0 {
+		return 0, 0, false
+	}
+
+	gain = -10 * math.Log10(float64(adjustment)/1000)
+	peak = float64(max(v[6], v[7])) / 32768
+	return gain, peak, true
+}
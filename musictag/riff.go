@@ -0,0 +1,332 @@
+package musictag
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"strconv"
+)
+
+var (
+	ErrNotWAV  = errors.New("Invalid WAV file")
+	ErrNotAIFF = errors.New("Invalid AIFF file")
+)
+
+// RIFFMetadata is the implementation of Metadata used for WAV and AIFF files.
+// The values of the embedded ID3v2 chunk are preferred over the RIFF INFO
+// (or AIFF text chunk) values.
+type RIFFMetadata struct {
+	fileType FileType
+	info     map[string]string
+	id3      Metadata
+}
+
+func (m RIFFMetadata) get(f func(Metadata) string, k string) string {
+	if m.id3 != nil {
+		if v := f(m.id3); v != "" {
+			return v
+		}
+	}
+	return trimString(m.info[k])
+}
+
+func (m RIFFMetadata) GetTagFormat() TagFormat {
+	if m.id3 != nil {
+		return m.id3.GetTagFormat()
+	}
+	if m.fileType == AIFF {
+		return AIFFText
+	}
+	return RIFFInfo
+}
+
+func (m RIFFMetadata) GetFileType() FileType  { return m.fileType }
+func (m RIFFMetadata) GetTitle() string       { return m.get(Metadata.GetTitle, "INAM") }
+func (m RIFFMetadata) GetArtist() string      { return m.get(Metadata.GetArtist, "IART") }
+func (m RIFFMetadata) GetAlbum() string       { return m.get(Metadata.GetAlbum, "IPRD") }
+func (m RIFFMetadata) GetAlbumArtist() string { return m.get(Metadata.GetAlbumArtist, "") }
+func (m RIFFMetadata) GetGenre() string       { return m.get(Metadata.GetGenre, "IGNR") }
+func (m RIFFMetadata) GetComment() string     { return trimString(m.info["ICMT"]) }
+func (m RIFFMetadata) GetComposer() string    { return m.get(Metadata.GetComposer, "IMUS") }
+
+func (m RIFFMetadata) GetTrack() (int, int) {
+	if m.id3 != nil {
+		if n, total := m.id3.GetTrack(); n != 0 {
+			return n, total
+		}
+	}
+
+	// ITRK is not part of the original spec but used by most taggers
+	if n, total := parseNumberPair(trimString(m.info["ITRK"])); n != 0 {
+		return n, total
+	}
+	return parseNumberPair(trimString(m.info["IPRT"]))
+}
+
+func (m RIFFMetadata) GetDisc() (int, int) {
+	if m.id3 == nil {
+		return 0, 0
+	}
+	return m.id3.GetDisc()
+}
+
+func (m RIFFMetadata) GetLyrics() string {
+	if m.id3 == nil {
+		return ""
+	}
+	return m.id3.GetLyrics()
+}
+
+func (m RIFFMetadata) GetCustom(key string) string {
+	if m.id3 == nil {
+		return ""
+	}
+	return m.id3.GetCustom(key)
+}
+
+func (m RIFFMetadata) GetArtists() []string {
+	if m.id3 != nil {
+		if artists := m.id3.GetArtists(); len(artists) > 0 {
+			return artists
+		}
+	}
+	return singleValue(m.GetArtist())
+}
+
+func (m RIFFMetadata) GetRating() int {
+	if m.id3 == nil {
+		return 0
+	}
+	return m.id3.GetRating()
+}
+
+func (m RIFFMetadata) GetPlayCount() int {
+	if m.id3 == nil {
+		return 0
+	}
+	return m.id3.GetPlayCount()
+}
+
+func (m RIFFMetadata) GetChapters() []Chapter {
+	if m.id3 == nil {
+		return nil
+	}
+	return m.id3.GetChapters()
+}
+
+func (m RIFFMetadata) GetSyncedLyrics() []LyricLine {
+	if m.id3 == nil {
+		return nil
+	}
+	return m.id3.GetSyncedLyrics()
+}
+
+func (m RIFFMetadata) GetBPM() int {
+	if m.id3 == nil {
+		return 0
+	}
+	return m.id3.GetBPM()
+}
+
+func (m RIFFMetadata) GetYear() int {
+	if m.id3 != nil {
+		if year := m.id3.GetYear(); year != 0 {
+			return year
+		}
+	}
+
+	date := trimString(m.info["ICRD"])
+	if len(date) > 4 {
+		// ICRD is usualy a date like 2000-01-01
+		date = date[:4]
+	}
+
+	year, err := strconv.Atoi(date)
+	if err != nil {
+		return 0
+	}
+	return year
+}
+
+func (m RIFFMetadata) GetAlbumArt() *Picture {
+	if m.id3 == nil {
+		return nil
+	}
+	return m.id3.GetAlbumArt()
+}
+
+func (m RIFFMetadata) GetPictures() []*Picture {
+	if m.id3 == nil {
+		return nil
+	}
+	return m.id3.GetPictures()
+}
+
+// AIFF text chunks mapped to their RIFF INFO equivalent
+var aiffTextChunks = map[string]string{
+	"NAME": "INAM",
+	"AUTH": "IART",
+	"ANNO": "ICMT",
+}
+
+// ReadWAVTags reads the LIST/INFO and id3 chunks of a RIFF/WAVE file.
+// (see https://www.mmsp.ece.mcgill.ca/Documents/AudioFormats/WAVE/WAVE.html)
+//
+// Chunk ID            "RIFF"
+// Chunk size          [uint32 little endian]
+// Form type           "WAVE"
+// Chunks              [chunk ID, uint32 little endian size, data padded to even size]
+func ReadWAVTags(r io.ReadSeeker) (Metadata, error) {
+	b, err := readBytes(r, 12)
+	if err != nil {
+		return nil, err
+	}
+
+	if string(b[0:4]) != "RIFF" || string(b[8:12]) != "WAVE" {
+		return nil, ErrNotWAV
+	}
+
+	m := RIFFMetadata{
+		fileType: WAV,
+		info:     make(map[string]string),
+	}
+
+	size := int64(getIntLittleEndian(b[4:8])) - 4
+	err = readIFFChunks(r, size, binary.LittleEndian, func(id string, size uint) error {
+		switch id {
+		case "LIST":
+			// the list type is missing from a truncated LIST chunk
+			if size < 4 {
+				_, err := r.Seek(int64(size), io.SeekCurrent)
+				return err
+			}
+			listType, err := readString(r, 4)
+			if err != nil {
+				return err
+			}
+			if listType != "INFO" {
+				_, err = r.Seek(int64(size)-4, io.SeekCurrent)
+				return err
+			}
+			return readIFFChunks(r, int64(size)-4, binary.LittleEndian, func(id string, size uint) error {
+				txt, err := readString(r, size)
+				if err != nil {
+					return err
+				}
+				m.info[id] = txt
+				return nil
+			})
+
+		case "id3 ", "ID3 ":
+			return readIFFID3Chunk(r, size, &m)
+
+		default:
+			_, err := r.Seek(int64(size), io.SeekCurrent)
+			return err
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// ReadAIFFTags reads the text and ID3 chunks of a AIFF/AIFC file.
+// (see https://www.mmsp.ece.mcgill.ca/Documents/AudioFormats/AIFF/AIFF.html)
+//
+// Chunk ID            "FORM"
+// Chunk size          [uint32 big endian]
+// Form type           "AIFF" or "AIFC"
+// Chunks              [chunk ID, uint32 big endian size, data padded to even size]
+func ReadAIFFTags(r io.ReadSeeker) (Metadata, error) {
+	b, err := readBytes(r, 12)
+	if err != nil {
+		return nil, err
+	}
+
+	if string(b[0:4]) != "FORM" || (string(b[8:12]) != "AIFF" && string(b[8:12]) != "AIFC") {
+		return nil, ErrNotAIFF
+	}
+
+	m := RIFFMetadata{
+		fileType: AIFF,
+		info:     make(map[string]string),
+	}
+
+	size := int64(getInt(b[4:8])) - 4
+	err = readIFFChunks(r, size, binary.BigEndian, func(id string, size uint) error {
+		if k, ok := aiffTextChunks[id]; ok {
+			txt, err := readString(r, size)
+			if err != nil {
+				return err
+			}
+			m.info[k] = txt
+			return nil
+		}
+
+		if id == "ID3 " || id == "id3 " {
+			return readIFFID3Chunk(r, size, &m)
+		}
+
+		_, err := r.Seek(int64(size), io.SeekCurrent)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// readIFFChunks walks the chunks in the next remaining bytes and calls
+// readChunk for every chunk, readChunk must consume exactly size bytes.
+func readIFFChunks(r io.ReadSeeker, remaining int64, bo binary.ByteOrder, readChunk func(id string, size uint) error) error {
+	for remaining >= 8 {
+		b, err := readBytes(r, 8)
+		if err != nil {
+			// some encoders write a wrong RIFF size, stop at the end of file
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		id := string(b[0:4])
+		size := uint(bo.Uint32(b[4:8]))
+		remaining -= 8
+
+		if int64(size) > remaining {
+			size = uint(remaining)
+		}
+		remaining -= int64(size)
+
+		if err = readChunk(id, size); err != nil {
+			return err
+		}
+
+		// chunks are padded to even size
+		if size%2 == 1 && remaining > 0 {
+			if _, err = r.Seek(1, io.SeekCurrent); err != nil {
+				return err
+			}
+			remaining--
+		}
+	}
+	return nil
+}
+
+func readIFFID3Chunk(r io.ReadSeeker, size uint, m *RIFFMetadata) error {
+	start, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+
+	m.id3, err = ReadID3v2Tags(r)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.Seek(start+int64(size), io.SeekStart)
+	return err
+}
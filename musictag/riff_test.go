@@ -0,0 +1,36 @@
+package musictag
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestReadWAVTags(t *testing.T) {
+	info := "INFOINAM\x0b\x00\x00\x00Test Title\x00\x00IART\x0c\x00\x00\x00Test Artist\x00"
+	tests := []struct {
+		name   string
+		chunks []string
+	}{
+		{"INFO list", []string{"LIST", info, "data", "abcd"}},
+		{"other list", []string{"LIST", "adtlsome data", "LIST", info, "data", "abcd"}},
+		// the list type is missing
+		{"empty list", []string{"LIST", "", "LIST", info, "data", "abcd"}},
+		{"truncated list", []string{"LIST", "IN", "LIST", info, "data", "abcd"}},
+		{"odd truncated list", []string{"LIST", "INF", "LIST", info, "data", "abcd"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := ReadWAVTags(bytes.NewReader(iffFile(binary.LittleEndian, tt.chunks...)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := m.GetTitle(); got != "Test Title" {
+				t.Errorf("title = %q, want %q", got, "Test Title")
+			}
+			if got := m.GetArtist(); got != "Test Artist" {
+				t.Errorf("artist = %q, want %q", got, "Test Artist")
+			}
+		})
+	}
+}
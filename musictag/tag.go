@@ -3,35 +3,114 @@ package musictag
 import (
 	"errors"
 	"io"
+	"math"
+	"strconv"
+	"strings"
 )
 
 var ErrNoTagFound = errors.New("No tag found")
 
-// read the tag from music file currently supported id3v1,2.{2,3,4}
-func ReadFrom(r io.ReadSeeker) (Metadata, error) {
-	b, err := readBytes(r, 10)
+// ErrMalformedTag is returned when a tag can not be parsed, the error wraps
+// the cause and is returned instead of panicking on unexpected data.
+var ErrMalformedTag = errors.New("malformed tag")
+
+// read the tag from music file currently supported id3v1,2.{2,3,4}, APEv2, FLAC, MP4, Ogg, DSF, WAV and AIFF
+// The container is detected from its signature (see RegisterFormat), the
+// ID3v2 tags at the start or at the end of the file are read whatever the
+// container is.
+func ReadFrom(r io.ReadSeeker, opts ...ReadOption) (Metadata, error) {
+	var o readOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	format, fileType, start, err := sniffContainer(r)
 	if err != nil {
 		return nil, err
 	}
 
-	_, err = r.Seek(-10, io.SeekCurrent)
-	if err != nil {
+	id3v2, id3v2Err := readID3v2Tags(r, o.skipPictures)
+
+	if _, err = r.Seek(start, io.SeekStart); err != nil {
+		return nil, err
+	}
+	m, err := format.Read(&containerReader{r: r, start: start})
+	if err != nil && err != ErrNoTagFound {
 		return nil, err
 	}
 
-	if string(b[0:3]) == "ID3" {
-		return ReadID3v2Tags(r)
+	var tags MultiMetadata
+	if m != nil {
+		tags = append(tags, m)
+	}
+	if format.ID3v2Main {
+		tags = append(id3v2, tags...)
+	} else {
+		tags = append(tags, id3v2...)
 	}
 
-	m, err := ReadID3v1Tags(r)
+	switch {
+	case len(tags) == 0 && id3v2Err != nil:
+		return nil, id3v2Err
+	case len(tags) == 0:
+		return nil, ErrNoTagFound
+	case len(tags) == 1 && tags[0].GetFileType() == fileType:
+		m = tags[0]
+	case len(tags) == 1:
+		m = fileMetadata{Metadata: tags[0], fileType: fileType}
+	default:
+		m = fileMetadata{Metadata: tags, fileType: fileType}
+	}
+
+	if o.textDecoder != nil {
+		m = recodeMetadata(m, o.textDecoder)
+	}
+	return m, nil
+}
+
+// ReadOption changes how ReadFrom reads the tags.
+type ReadOption func(*readOptions)
+
+type readOptions struct {
+	textDecoder  TextDecoder
+	skipPictures bool
+}
+
+// WithTextDecoder decodes the ISO-8859-1 text of the ID3 tags with d (see
+// NewTextDecoder).
+func WithTextDecoder(d TextDecoder) ReadOption {
+	return func(o *readOptions) {
+		o.textDecoder = d
+	}
+}
+
+// WithoutPictures seeks past the body of the pictures and encapsulated
+// objects (APIC, PIC and GEOB frames) of the ID3v2 tags instead of reading
+// them, which is faster when only the text is needed. GetPictures and
+// GetAlbumArt then return nothing for these tags, their location is given by
+// SkippedFrames and a picture is read with LoadPicture.
+func WithoutPictures() ReadOption {
+	return func(o *readOptions) {
+		o.skipPictures = true
+	}
+}
+
+// readID3v2Tags reads the ID3v2 tags prepended (and chained with SEEK
+// frames) and appended to the file, the prepended ones first. The tags
+// which were read are returned with the first error.
+func readID3v2Tags(r io.ReadSeeker, skipPictures bool) (MultiMetadata, error) {
+	tags, err := readLeadingID3v2Tags(r, skipPictures)
 	if err != nil {
-		if err == ErrNotID3V1 {
-			return nil, ErrNoTagFound
-		}
 		return nil, err
 	}
 
-	return m, nil
+	m, err := readAppendedID3v2Tag(r, skipPictures)
+	if err == ErrNoTagFound {
+		return tags, nil
+	} else if err != nil {
+		return tags, err
+	}
+	return append(tags, m), nil
 }
 
 type TagFormat string
@@ -43,6 +122,11 @@ const (
 	ID3v2_2       TagFormat = "ID3V2.2"
 	ID3v2_3       TagFormat = "ID3V2.3"
 	ID3v2_4       TagFormat = "ID3V2.4"
+	VorbisComment TagFormat = "VORBIS"
+	ITunes        TagFormat = "ITUNES"
+	RIFFInfo      TagFormat = "RIFF INFO"
+	AIFFText      TagFormat = "AIFF TEXT"
+	APEv2         TagFormat = "APEv2"
 )
 
 type FileType string
@@ -51,6 +135,16 @@ type FileType string
 const (
 	UnknownFileType FileType = ""
 	MP3             FileType = "MP3"
+	FLAC            FileType = "FLAC"
+	MP4             FileType = "MP4"
+	OGG             FileType = "OGG"
+	OPUS            FileType = "OPUS"
+	DSF             FileType = "DSF"
+	WAV             FileType = "WAV"
+	AIFF            FileType = "AIFF"
+	APE             FileType = "APE"
+	WavPack         FileType = "WV"
+	Musepack        FileType = "MPC"
 )
 
 // Music metadata interface
@@ -67,6 +161,11 @@ type Metadata interface {
 	// GetArtist returns the track artist
 	GetArtist() string
 
+	// GetArtists returns the track artists, the values of a multi-valued
+	// artist field or of the ARTISTS field. A single value is returned as is,
+	// it can still hold several artists (e.g. "Artist A / Artist B").
+	GetArtists() []string
+
 	// GetAlbum returns the album name of the track
 	GetAlbum() string
 
@@ -79,6 +178,237 @@ type Metadata interface {
 	// GetGenre returns the genre of the track
 	GetGenre() string
 
-	// returns album art of the track
+	// GetTrack returns the track number and the total number of tracks (0 if unknown)
+	GetTrack() (n, total int)
+
+	// GetDisc returns the disc number and the total number of discs (0 if unknown)
+	GetDisc() (n, total int)
+
+	// GetComposer returns the composer of the track
+	GetComposer() string
+
+	// GetBPM returns the beats per minute of the track
+	GetBPM() int
+
+	// GetLyrics returns the unsynchronised lyrics of the track
+	GetLyrics() string
+
+	// GetSyncedLyrics returns the synchronised lyrics of the track, from a
+	// synchronised lyrics frame or lyrics stored in the LRC format
+	GetSyncedLyrics() []LyricLine
+
+	// GetCustom returns the value of a user defined field (ID3v2 TXXX, Vorbis
+	// comment, MP4 freeform or APE item), see matchCustomKey for the key matching
+	GetCustom(key string) string
+
+	// GetRating returns the rating of the track from 1 to 5 stars, 0 if the
+	// track is not rated
+	GetRating() int
+
+	// GetPlayCount returns the number of times the track was played
+	GetPlayCount() int
+
+	// GetChapters returns the chapters of the track sorted by start time
+	GetChapters() []Chapter
+
+	// returns album art of the track, the front cover if there is one
+	// otherwise the first picture
 	GetAlbumArt() *Picture
+
+	// GetPictures returns all the embedded pictures
+	GetPictures() []*Picture
+}
+
+// multiValueSeparator joins the values of a multi-valued field when it is
+// returned as a single string
+const multiValueSeparator = "; "
+
+// customArtists is the user defined field holding the list of the artists,
+// as written by MusicBrainz Picard
+const customArtists = "ARTISTS"
+
+// splitNullSeparated splits values separated by null characters (ID3v2.4
+// text frames, APEv2 items), the empty values are dropped.
+func splitNullSeparated(s string) []string {
+	var values []string
+	for _, v := range strings.Split(s, "\x00") {
+		if v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// singleValue returns the value as a list, nil if it is empty
+func singleValue(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return []string{s}
+}
+
+// parseNumberPair parse "n" or "n/total" as used by track and disc numbers
+func parseNumberPair(s string) (n, total int) {
+	a, b, _ := strings.Cut(strings.TrimSpace(s), "/")
+	n, _ = strconv.Atoi(strings.TrimSpace(a))
+	total, _ = strconv.Atoi(strings.TrimSpace(b))
+	return n, total
+}
+
+// parseBPM parse a BPM value, which some taggers write as a decimal number
+func parseBPM(s string) int {
+	bpm, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0
+	}
+	return int(math.Round(bpm))
+}
+
+// MusicBrainz identifiers, as written by MusicBrainz Picard
+const (
+	MusicBrainzTrackID  = "MusicBrainz Track Id"
+	MusicBrainzAlbumID  = "MusicBrainz Album Id"
+	MusicBrainzArtistID = "MusicBrainz Artist Id"
+)
+
+// matchCustomKey compares user defined field names ignoring the case, spaces
+// and underscores, so "MusicBrainz Album Id" (ID3v2, MP4) also matches
+// "MUSICBRAINZ_ALBUMID" (Vorbis, APE).
+func matchCustomKey(name, key string) bool {
+	normalize := strings.NewReplacer(" ", "", "_", "")
+	return strings.EqualFold(normalize.Replace(name), normalize.Replace(key))
+}
+
+// frontCover returns the front cover if present otherwise the first picture
+func frontCover(pictures []*Picture) *Picture {
+	for _, p := range pictures {
+		if p.Type == pictureTypes[0x03] {
+			return p
+		}
+	}
+
+	if len(pictures) == 0 {
+		return nil
+	}
+	return pictures[0]
+}
+
+// MultiMetadata merge the tags found in a single file (e.g. ID3v2, APEv2 and
+// ID3v1 in a MP3), the getters return the value of the first tag which has it.
+type MultiMetadata []Metadata
+
+func (m MultiMetadata) getString(f func(Metadata) string) string {
+	for _, t := range m {
+		if v := f(t); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func (m MultiMetadata) GetTagFormat() TagFormat { return m[0].GetTagFormat() }
+func (m MultiMetadata) GetFileType() FileType   { return m[0].GetFileType() }
+func (m MultiMetadata) GetTitle() string        { return m.getString(Metadata.GetTitle) }
+func (m MultiMetadata) GetArtist() string       { return m.getString(Metadata.GetArtist) }
+func (m MultiMetadata) GetAlbum() string        { return m.getString(Metadata.GetAlbum) }
+func (m MultiMetadata) GetAlbumArtist() string  { return m.getString(Metadata.GetAlbumArtist) }
+func (m MultiMetadata) GetGenre() string        { return m.getString(Metadata.GetGenre) }
+func (m MultiMetadata) GetComposer() string     { return m.getString(Metadata.GetComposer) }
+func (m MultiMetadata) GetLyrics() string       { return m.getString(Metadata.GetLyrics) }
+
+func (m MultiMetadata) GetCustom(key string) string {
+	return m.getString(func(t Metadata) string { return t.GetCustom(key) })
+}
+
+func (m MultiMetadata) GetArtists() []string {
+	for _, t := range m {
+		if artists := t.GetArtists(); len(artists) > 0 {
+			return artists
+		}
+	}
+	return nil
+}
+
+func (m MultiMetadata) GetRating() int {
+	for _, t := range m {
+		if rating := t.GetRating(); rating != 0 {
+			return rating
+		}
+	}
+	return 0
+}
+
+func (m MultiMetadata) GetPlayCount() int {
+	for _, t := range m {
+		if count := t.GetPlayCount(); count != 0 {
+			return count
+		}
+	}
+	return 0
+}
+
+func (m MultiMetadata) GetChapters() []Chapter {
+	for _, t := range m {
+		if chapters := t.GetChapters(); len(chapters) > 0 {
+			return chapters
+		}
+	}
+	return nil
+}
+
+func (m MultiMetadata) GetSyncedLyrics() []LyricLine {
+	for _, t := range m {
+		if lines := t.GetSyncedLyrics(); len(lines) > 0 {
+			return lines
+		}
+	}
+	return nil
+}
+
+func (m MultiMetadata) getPair(f func(Metadata) (int, int)) (int, int) {
+	for _, t := range m {
+		if n, total := f(t); n != 0 {
+			return n, total
+		}
+	}
+	return 0, 0
+}
+
+func (m MultiMetadata) GetTrack() (int, int) { return m.getPair(Metadata.GetTrack) }
+func (m MultiMetadata) GetDisc() (int, int)  { return m.getPair(Metadata.GetDisc) }
+
+func (m MultiMetadata) GetBPM() int {
+	for _, t := range m {
+		if bpm := t.GetBPM(); bpm != 0 {
+			return bpm
+		}
+	}
+	return 0
+}
+
+func (m MultiMetadata) GetYear() int {
+	for _, t := range m {
+		if year := t.GetYear(); year != 0 {
+			return year
+		}
+	}
+	return 0
+}
+
+func (m MultiMetadata) GetAlbumArt() *Picture {
+	for _, t := range m {
+		if p := t.GetAlbumArt(); p != nil {
+			return p
+		}
+	}
+	return nil
+}
+
+func (m MultiMetadata) GetPictures() []*Picture {
+	for _, t := range m {
+		if p := t.GetPictures(); len(p) > 0 {
+			return p
+		}
+	}
+	return nil
 }
@@ -0,0 +1,336 @@
+package musictag
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// TextDecoder decodes the text stored as ISO-8859-1, the ID3v2 frames with
+// the encoding $00 and the ID3v1 fields. Many tagging tools wrote them with
+// the local code page (or UTF-8) instead.
+type TextDecoder func(b []byte) string
+
+// code pages indexed by the byte minus 0x80, 0 is a byte which is dropped
+// (e.g. the ISCII invisible consonant) and U+FFFD an undefined byte.
+var codePages = map[string]*[128]rune{
+	"windows-1251":  codePageTable("ЂЃ‚ѓ„…†‡€‰Љ‹ЊЌЋЏђ‘’“”•–—\ufffd™љ›њќћџ\u00a0ЎўЈ¤Ґ¦§Ё©Є«¬\u00ad®Ї°±Ііґµ¶·ё№є»јЅѕїАБВГДЕЖЗИЙКЛМНОПРСТУФХЦЧШЩЪЫЬЭЮЯабвгдежзийклмнопрстуфхцчшщъыьэюя"),
+	"koi8-r":        codePageTable("─│┌┐└┘├┤┬┴┼▀▄█▌▐░▒▓⌠■∙√≈≤≥\u00a0⌡°²·÷═║╒ё╓╔╕╖╗╘╙╚╛╜╝╞╟╠╡Ё╢╣╤╥╦╧╨╩╪╫╬©юабцдефгхийклмнопярстужвьызшэщчъЮАБЦДЕФГХИЙКЛМНОПЯРСТУЖВЬЫЗШЭЩЧЪ"),
+	"cp866":         codePageTable("АБВГДЕЖЗИЙКЛМНОПРСТУФХЦЧШЩЪЫЬЭЮЯабвгдежзийклмноп░▒▓│┤╡╢╖╕╣║╗╝╜╛┐└┴┬├─┼╞╟╚╔╩╦╠═╬╧╨╤╥╙╘╒╓╫╪┘┌█▄▌▐▀рстуфхцчшщъыьэюяЁёЄєЇїЎў°∙·√№¤■\u00a0"),
+	"windows-1252":  codePageTable("€\ufffd‚ƒ„…†‡ˆ‰Š‹Œ\ufffdŽ\ufffd\ufffd‘’“”•–—˜™š›œ\ufffdžŸ\u00a0¡¢£¤¥¦§¨©ª«¬\u00ad®¯°±²³´µ¶·¸¹º»¼½¾¿ÀÁÂÃÄÅÆÇÈÉÊËÌÍÎÏÐÑÒÓÔÕÖ×ØÙÚÛÜÝÞßàáâãäåæçèéêëìíîïðñòóôõö÷øùúûüýþÿ"),
+	"iscii-bengali": isciiTable(0x0980),
+}
+
+// aliases of the code page names
+var codePageAliases = map[string]string{
+	"cp1251":        "windows-1251",
+	"cp1252":        "windows-1252",
+	"koi8r":         "koi8-r",
+	"ibm866":        "cp866",
+	"iscii-bn":      "iscii-bengali",
+	"x-iscii-be":    "iscii-bengali",
+	"iso-8859-1":    "",
+	"latin1":        "",
+	"latin-1":       "",
+	"iso8859-1":     "",
+	"iso_8859-1":    "",
+	"windows-28591": "",
+}
+
+func codePageTable(s string) *[128]rune {
+	var table [128]rune
+	if utf8.RuneCountInString(s) != len(table) {
+		panic(fmt.Sprintf("invalid code page table: %d characters", utf8.RuneCountInString(s)))
+	}
+	i := 0
+	for _, r := range s {
+		table[i] = r
+		i++
+	}
+	return &table
+}
+
+// ISCII (IS 13194:1991) characters $A1 to $FA as offsets in the Devanagari
+// block, the Unicode blocks of the Indic scripts have the ISCII layout.
+var isciiOffsets = [...]rune{
+	0x01, 0x02, 0x03, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0A, 0x0B, 0x0E, 0x0F, 0x10, 0x0D, 0x12, // $A1
+	0x13, 0x14, 0x11, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1A, 0x1B, 0x1C, 0x1D, 0x1E, 0x1F, 0x20, 0x21, // $B0
+	0x22, 0x23, 0x24, 0x25, 0x26, 0x27, 0x28, 0x29, 0x2A, 0x2B, 0x2C, 0x2D, 0x2E, 0x2F, 0x5F, 0x30, // $C0
+	0x31, 0x32, 0x33, 0x34, 0x35, 0x36, 0x37, 0x38, 0x39, 0x00, 0x3E, 0x3F, 0x40, 0x41, 0x42, 0x43, // $D0
+	0x46, 0x47, 0x48, 0x45, 0x4A, 0x4B, 0x4C, 0x49, 0x4D, 0x3C, 0x64, -1, -1, -1, -1, -1, // $E0
+	-1, 0x66, 0x67, 0x68, 0x69, 0x6A, 0x6B, 0x6C, 0x6D, 0x6E, 0x6F, // $F0
+}
+
+// isciiTable returns the ISCII table of the script whose Unicode block
+// starts at block.
+func isciiTable(block rune) *[128]rune {
+	var table [128]rune
+	for i := range table {
+		table[i] = '\ufffd'
+	}
+
+	for i, offset := range isciiOffsets {
+		switch {
+		case offset < 0:
+			// undefined
+		case offset == 0:
+			table[0x21+i] = 0 // invisible consonant
+		case offset == 0x64:
+			table[0x21+i] = 0x0964 // the danda is shared by the scripts
+		default:
+			table[0x21+i] = block + offset
+		}
+	}
+	return &table
+}
+
+// CodePages returns the names of the code pages known by NewTextDecoder.
+func CodePages() []string {
+	var names []string
+	for name := range codePages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// normalizeCodePage returns the name of the code page, "" for ISO-8859-1.
+func normalizeCodePage(codePage string) (string, error) {
+	name := strings.ToLower(strings.TrimSpace(codePage))
+	if alias, ok := codePageAliases[name]; ok {
+		name = alias
+	}
+	if _, ok := codePages[name]; !ok && name != "" {
+		return "", fmt.Errorf("unknown code page %q, expected one of %s", codePage, strings.Join(CodePages(), ", "))
+	}
+	return name, nil
+}
+
+// NewTextDecoder returns the decoder of the text stored as ISO-8859-1.
+// The text is decoded with the code page, "" keeps ISO-8859-1. With detect
+// the text which is valid UTF-8 is decoded as UTF-8, and without code page
+// the Bengali ISCII and the Cyrillic text (windows-1251 or KOI8-R) are
+// recognised.
+func NewTextDecoder(codePage string, detect bool) (TextDecoder, error) {
+	name, err := normalizeCodePage(codePage)
+	if err != nil {
+		return nil, err
+	}
+	table := codePages[name]
+
+	return func(b []byte) string {
+		if isASCII(b) {
+			return string(b)
+		}
+
+		if detect && utf8.Valid(b) {
+			return string(b)
+		}
+
+		if table != nil {
+			return decodeCodePage(b, table)
+		}
+
+		if detect {
+			if iscii := detectISCII(b); iscii != nil {
+				return decodeCodePage(b, iscii)
+			}
+			if cyrillic := detectCyrillic(b); cyrillic != nil {
+				return decodeCodePage(b, cyrillic)
+			}
+		}
+		return decodeISO8859(b)
+	}, nil
+}
+
+func isASCII(b []byte) bool {
+	for _, x := range b {
+		if x >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+func decodeCodePage(b []byte, table *[128]rune) string {
+	var sb strings.Builder
+	for _, x := range b {
+		switch {
+		case x < 0x80:
+			sb.WriteByte(x)
+		case table[x-0x80] != 0:
+			sb.WriteRune(table[x-0x80])
+		}
+	}
+	return sb.String()
+}
+
+// isISCIISign reports whether x is an ISCII sign ($A1 to $A3, $DA to $E9),
+// the vowel signs, the halant and the nukta follow a consonant and the
+// candrabindu, anusvara and visarga follow a letter.
+func isISCIISign(x byte) bool {
+	return (x >= 0xA1 && x <= 0xA3) || (x >= 0xDA && x <= 0xE9)
+}
+
+// detectISCII returns the code page of a Bengali ISCII text, nil if the text
+// is not ISCII. All the bytes must be defined in ISCII, and the signs must
+// follow a letter and be frequent (the vowels following a consonant are
+// written with a sign). The Cyrillic words use the undefined bytes ($EB to
+// $EF, $FB to $FF) or don't start with a letter sign, and the ISO-8859-1
+// accented letters follow ASCII letters.
+func detectISCII(b []byte) *[128]rune {
+	table := codePages["iscii-bengali"]
+
+	var letters, signs int
+	for i, x := range b {
+		if x < 0x80 {
+			continue
+		}
+		if table[x-0x80] == '\ufffd' {
+			return nil
+		}
+		letters++
+		if isISCIISign(x) {
+			if i == 0 || b[i-1] < 0xA1 {
+				return nil
+			}
+			signs++
+		}
+	}
+
+	if letters < 3 || signs*4 < letters {
+		return nil
+	}
+	return table
+}
+
+// detectCyrillic returns the code page of a Cyrillic text, nil if the text
+// looks like ISO-8859-1. The letters of both code pages are $C0 to $FF and
+// the words are runs of them, while the ISO-8859-1 accented letters are
+// mostly isolated between ASCII letters. The lowercase letters are $E0 to
+// $FF in windows-1251 and $C0 to $DF in KOI8-R.
+func detectCyrillic(b []byte) *[128]rune {
+	var letters, runs, upperHalf int
+	for i, x := range b {
+		if x < 0xC0 {
+			continue
+		}
+		letters++
+		if x >= 0xE0 {
+			upperHalf++
+		}
+		if i > 0 && b[i-1] >= 0xC0 {
+			runs++
+		}
+	}
+
+	if letters < 3 || runs*2 < letters {
+		return nil
+	}
+	if upperHalf*2 >= letters {
+		return codePages["windows-1251"]
+	}
+	return codePages["koi8-r"]
+}
+
+// recodeLatin1 decodes again a text decoded as ISO-8859-1, every character
+// of the text is a byte of the original text.
+func recodeLatin1(s string, decode TextDecoder) string {
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		if r > 0xFF {
+			return s
+		}
+		b = append(b, byte(r))
+	}
+	return decode(b)
+}
+
+// latin1Recoder is implemented by the tags holding ISO-8859-1 text, the
+// text is decoded again with the TextDecoder given to ReadFrom.
+type latin1Recoder interface {
+	recodeLatin1(decode TextDecoder) Metadata
+}
+
+func recodeMetadata(m Metadata, decode TextDecoder) Metadata {
+	if r, ok := m.(latin1Recoder); ok {
+		return r.recodeLatin1(decode)
+	}
+	return m
+}
+
+func (m ID3v1Metadata) recodeLatin1(decode TextDecoder) Metadata {
+	recoded := make(ID3v1Metadata, len(m))
+	for k, v := range m {
+		if s, ok := v.(string); ok && k != "genre" {
+			v = recodeLatin1(s, decode)
+		}
+		recoded[k] = v
+	}
+	return recoded
+}
+
+func (m ID3v2Metadata) recodeLatin1(decode TextDecoder) Metadata {
+	frames := make(map[string]any, len(m.frames))
+	for k, v := range m.frames {
+		if m.latin1[k] {
+			v = recodeLatin1Frame(v, decode)
+		}
+		frames[k] = v
+	}
+	return ID3v2Metadata{header: m.header, frames: frames, latin1: m.latin1}
+}
+
+// recodeLatin1Frame decodes the text of a frame read by readID3v2Frames
+func recodeLatin1Frame(v any, decode TextDecoder) any {
+	switch v := v.(type) {
+	case string:
+		return recodeLatin1(v, decode)
+
+	case []string:
+		values := make([]string, len(v))
+		for i, s := range v {
+			values[i] = recodeLatin1(s, decode)
+		}
+		return values
+
+	case *Comm:
+		c := *v
+		c.Description = recodeLatin1(c.Description, decode)
+		c.Text = recodeLatin1(c.Text, decode)
+		return &c
+
+	case []LyricLine:
+		lines := make([]LyricLine, len(v))
+		for i, l := range v {
+			lines[i] = LyricLine{Time: l.Time, Text: recodeLatin1(l.Text, decode)}
+		}
+		return lines
+	}
+	return v
+}
+
+func (m MultiMetadata) recodeLatin1(decode TextDecoder) Metadata {
+	recoded := make(MultiMetadata, len(m))
+	for i, t := range m {
+		recoded[i] = recodeMetadata(t, decode)
+	}
+	return recoded
+}
+
+func (m fileMetadata) recodeLatin1(decode TextDecoder) Metadata {
+	return fileMetadata{Metadata: recodeMetadata(m.Metadata, decode), fileType: m.fileType}
+}
+
+func (m RIFFMetadata) recodeLatin1(decode TextDecoder) Metadata {
+	if m.id3 != nil {
+		m.id3 = recodeMetadata(m.id3, decode)
+	}
+	return m
+}
+
+func (m DSFMetadata) recodeLatin1(decode TextDecoder) Metadata {
+	m.Metadata = recodeMetadata(m.Metadata, decode)
+	return m
+}
@@ -0,0 +1,112 @@
+package musictag
+
+import (
+	"bytes"
+	"testing"
+)
+
+// "Привет" in windows-1251 and KOI8-R
+const (
+	cp1251Text = "\xcf\xf0\xe8\xe2\xe5\xf2"
+	koi8rText  = "\xf0\xd2\xc9\xd7\xc5\xd4"
+)
+
+func TestNewTextDecoder(t *testing.T) {
+	tests := []struct {
+		name     string
+		codePage string
+		detect   bool
+		in       string
+		want     string
+	}{
+		{"ASCII", "windows-1251", true, "Hello", "Hello"},
+		{"ISO-8859-1", "", false, cp1251Text, "Ïðèâåò"},
+		{"ISO-8859-1 alias", "Latin1", false, "Caf\xe9", "Café"},
+		{"windows-1251", "windows-1251", false, cp1251Text, "Привет"},
+		{"windows-1251 alias", " CP1251 ", false, cp1251Text, "Привет"},
+		{"KOI8-R", "koi8-r", false, koi8rText, "Привет"},
+		{"detect windows-1251", "", true, "Song " + cp1251Text, "Song Привет"},
+		{"detect KOI8-R", "", true, koi8rText, "Привет"},
+		{"detect ISO-8859-1", "", true, "Caf\xe9 del Mar", "Café del Mar"},
+		{"detect UTF-8", "", true, "Привет, café", "Привет, café"},
+		{"UTF-8 before the code page", "windows-1251", true, "Привет", "Привет"},
+		{"UTF-8 without detect", "", false, "caf\xc3\xa9", "cafÃ©"},
+		{"undefined byte", "windows-1252", false, "\x81\x80", "�€"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decode, err := NewTextDecoder(tt.codePage, tt.detect)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := decode([]byte(tt.in)); got != tt.want {
+				t.Errorf("decode(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewTextDecoder("shift-jis", false); err == nil {
+		t.Error("no error for an unknown code page")
+	}
+}
+
+// id3v1Tag returns an ID3v1 tag with the title and the artist.
+func id3v1Tag(title, artist string) []byte {
+	b := make([]byte, 128)
+	copy(b, "TAG")
+	copy(b[3:33], title)
+	copy(b[33:63], artist)
+	b[127] = 0xFF
+	return b
+}
+
+func TestTextDecoderID3v1(t *testing.T) {
+	m, err := ReadID3v1Tags(bytes.NewReader(id3v1Tag(cp1251Text, "Caf\xe9")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decode, err := NewTextDecoder("", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m = recodeMetadata(m, decode)
+	if got, want := m.GetTitle(), "Привет"; got != want {
+		t.Errorf("title = %q, want %q", got, want)
+	}
+	if got, want := m.GetArtist(), "Café"; got != want {
+		t.Errorf("artist = %q, want %q", got, want)
+	}
+}
+
+func TestTextDecoderID3v2(t *testing.T) {
+	decode, err := NewTextDecoder("windows-1251", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"ISO-8859-1", "\x00" + cp1251Text, "Привет"},
+		{"ISO-8859-1 holding UTF-8", "\x00Привет", "Привет"},
+		{"UTF-8", "\x03Ïðèâåò", "Ïðèâåò"},
+		{"UTF-16", "\x01\xff\xfe\xcf\x00\xf0\x00", "Ïð"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// followed by a MPEG frame header and room for an ID3v1 tag
+			b := append(id3v2Tag(3, "TIT2", 0, []byte(tt.body)), 0xFF, 0xFB, 0x90, 0x00)
+			b = append(b, make([]byte, 256)...)
+			m, err := ReadFrom(bytes.NewReader(b), WithTextDecoder(decode))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := m.GetTitle(); got != tt.want {
+				t.Errorf("title = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,250 @@
+package musictag
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// VorbisMetadata is the implementation of Metadata used for vorbis comments
+// (FLAC, Ogg Vorbis and Opus).
+type VorbisMetadata struct {
+	fileType FileType
+	vendor   string
+	comments map[string][]string // all the values of every field
+	pictures []*Picture
+}
+
+func (m VorbisMetadata) getString(keys ...string) string {
+	for _, k := range keys {
+		if v, ok := m.comments[k]; ok && len(v) > 0 {
+			return v[0]
+		}
+	}
+	return ""
+}
+
+func (VorbisMetadata) GetTagFormat() TagFormat   { return VorbisComment }
+func (m VorbisMetadata) GetFileType() FileType   { return m.fileType }
+func (m VorbisMetadata) GetTitle() string        { return m.getString("title") }
+func (m VorbisMetadata) GetArtist() string       { return m.getString("artist") }
+func (m VorbisMetadata) GetAlbum() string        { return m.getString("album") }
+func (m VorbisMetadata) GetGenre() string        { return m.getString("genre") }
+func (m VorbisMetadata) GetAlbumArt() *Picture   { return frontCover(m.pictures) }
+func (m VorbisMetadata) GetPictures() []*Picture { return m.pictures }
+func (m VorbisMetadata) GetVendor() string       { return m.vendor }
+func (m VorbisMetadata) GetComment() string      { return m.getString("comment", "description") }
+func (m VorbisMetadata) GetComposer() string     { return m.getString("composer") }
+func (m VorbisMetadata) GetBPM() int             { return parseBPM(m.getString("bpm")) }
+func (m VorbisMetadata) GetLyrics() string       { return m.getString("lyrics", "unsyncedlyrics") }
+
+func (m VorbisMetadata) GetSyncedLyrics() []LyricLine { return parseEmbeddedLRC(m.GetLyrics()) }
+func (m VorbisMetadata) GetRating() int               { return parseFMPSRating(m.GetCustom(fmpsRating)) }
+func (m VorbisMetadata) GetPlayCount() int            { return parseFMPSPlayCount(m.GetCustom(fmpsPlayCount)) }
+func (m VorbisMetadata) GetChapters() []Chapter       { return readVorbisChapters(m.comments) }
+
+// GetCustom returns the first value of the field key, or of the first field
+// (in alphabetical order) matching key.
+func (m VorbisMetadata) GetCustom(key string) string {
+	// field names are case insensitive, they are stored in lower case
+	if v := m.comments[strings.ToLower(key)]; len(v) > 0 {
+		return v[0]
+	}
+
+	for _, k := range slices.Sorted(maps.Keys(m.comments)) {
+		if v := m.comments[k]; matchCustomKey(k, key) && len(v) > 0 {
+			return v[0]
+		}
+	}
+	return ""
+}
+
+// GetArtists returns the values of the ARTISTS field, or of the ARTIST
+// field which can be repeated for every artist.
+func (m VorbisMetadata) GetArtists() []string {
+	if v := m.comments["artists"]; len(v) > 0 {
+		return v
+	}
+	return m.comments["artist"]
+}
+
+// TRACKNUMBER can be "n" or "n/total", the total can also be in TRACKTOTAL or TOTALTRACKS
+func (m VorbisMetadata) GetTrack() (int, int) {
+	n, total := parseNumberPair(m.getString("tracknumber"))
+	if total == 0 {
+		total, _ = strconv.Atoi(m.getString("tracktotal", "totaltracks"))
+	}
+	return n, total
+}
+
+func (m VorbisMetadata) GetDisc() (int, int) {
+	n, total := parseNumberPair(m.getString("discnumber"))
+	if total == 0 {
+		total, _ = strconv.Atoi(m.getString("disctotal", "totaldiscs"))
+	}
+	return n, total
+}
+func (m VorbisMetadata) GetAlbumArtist() string {
+	return m.getString("albumartist", "album artist", "album_artist")
+}
+
+func (m VorbisMetadata) GetYear() int {
+	date := m.getString("date", "year")
+	if len(date) > 4 {
+		// DATE is usualy a ISO 8601 date like 2000-01-01
+		date = date[:4]
+	}
+
+	year, err := strconv.Atoi(date)
+	if err != nil {
+		return 0
+	}
+	return year
+}
+
+// readVorbisComment reads a vorbis comment header (without framing bit).
+// see https://xiph.org/vorbis/doc/v-comment.html
+//
+// Vendor length       [uint32 little endian]
+// Vendor string       [UTF-8 string]
+// Comments count      [uint32 little endian]
+// For every comment:
+// Comment length      [uint32 little endian]
+// Comment             [UTF-8 string as "KEY=value"]
+//
+// METADATA_BLOCK_PICTURE comments hold a base64 encoded picture block and
+// are returned as pictures.
+func readVorbisComment(r io.Reader) (vendor string, comments map[string][]string, pictures []*Picture, err error) {
+	vendorLen, err := readUintLittleEndian(r, 4)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	vendor, err = readString(r, vendorLen)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	commentsLen, err := readUintLittleEndian(r, 4)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	comments = make(map[string][]string)
+	for i := uint(0); i < commentsLen; i++ {
+		l, err := readUintLittleEndian(r, 4)
+		if err != nil {
+			return "", nil, nil, err
+		}
+
+		s, err := readString(r, l)
+		if err != nil {
+			return "", nil, nil, err
+		}
+
+		k, v, ok := strings.Cut(s, "=")
+		if !ok {
+			continue
+		}
+
+		// field names are case insensitive, a malformed picture is skipped
+		// and the other comments are kept
+		k = strings.ToLower(k)
+		if k == "metadata_block_picture" {
+			b, err := base64.StdEncoding.DecodeString(v)
+			if err != nil {
+				continue
+			}
+
+			p, err := readPictureBlock(bytes.NewReader(b))
+			if err != nil {
+				continue
+			}
+			pictures = append(pictures, p)
+			continue
+		}
+
+		// fields can be repeated, e.g. one ARTIST field for every artist
+		comments[k] = append(comments[k], v)
+	}
+
+	return vendor, comments, pictures, nil
+}
+
+var errInvalidPictureBlock = errors.New("invalid METADATA_BLOCK_PICTURE")
+
+// readPictureBlock reads a FLAC METADATA_BLOCK_PICTURE, the same layout is
+// used base64 encoded in vorbis comments.
+// see https://xiph.org/flac/format.html#metadata_block_picture
+//
+// Picture type        [uint32]
+// MIME type length    [uint32]
+// MIME type           [ASCII string]
+// Description length  [uint32]
+// Description         [UTF-8 string]
+// Width, height, color depth, number of colors [4 * uint32]
+// Picture data length [uint32]
+// Picture data        [binary data]
+func readPictureBlock(r io.Reader) (*Picture, error) {
+	picType, err := readUint(r, 4)
+	if err != nil {
+		return nil, err
+	}
+
+	mimeLen, err := readUint(r, 4)
+	if err != nil {
+		return nil, err
+	}
+
+	mimeType, err := readString(r, mimeLen)
+	if err != nil {
+		return nil, err
+	}
+
+	descLen, err := readUint(r, 4)
+	if err != nil {
+		return nil, err
+	}
+
+	desc, err := readString(r, descLen)
+	if err != nil {
+		return nil, err
+	}
+
+	// skip width, height, color depth and number of colors
+	if _, err = readBytes(r, 16); err != nil {
+		return nil, err
+	}
+
+	dataLen, err := readUint(r, 4)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := readBytes(r, dataLen)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidPictureBlock, err)
+	}
+
+	var ext string
+	switch mimeType {
+	case "image/jpeg":
+		ext = "jpg"
+	case "image/png":
+		ext = "png"
+	}
+
+	return &Picture{
+		Ext:         ext,
+		MIMEType:    mimeType,
+		Type:        pictureTypes[byte(picType)],
+		Description: desc,
+		Data:        data,
+	}, nil
+}
@@ -0,0 +1,89 @@
+package musictag
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"testing"
+)
+
+// vorbisComment returns a Vorbis comment of the comments, without the
+// framing bit of the Ogg Vorbis comment header
+func vorbisComment(comments ...string) []byte {
+	b := binary.LittleEndian.AppendUint32(nil, uint32(len("test vendor")))
+	b = append(b, "test vendor"...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(comments)))
+	for _, c := range comments {
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(c)))
+		b = append(b, c...)
+	}
+	return b
+}
+
+// pictureBlock returns a METADATA_BLOCK_PICTURE of a front cover
+func pictureBlock(mimeType string, data []byte) []byte {
+	b := binary.BigEndian.AppendUint32(nil, 3)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(mimeType)))
+	b = append(b, mimeType...)
+	b = binary.BigEndian.AppendUint32(b, 0) // description
+	for _, v := range []uint32{1, 1, 24, 0} {
+		b = binary.BigEndian.AppendUint32(b, v)
+	}
+	b = binary.BigEndian.AppendUint32(b, uint32(len(data)))
+	return append(b, data...)
+}
+
+func TestReadVorbisComment(t *testing.T) {
+	picture := base64.StdEncoding.EncodeToString(pictureBlock("image/png", []byte("png data")))
+	truncated := base64.StdEncoding.EncodeToString(pictureBlock("image/png", []byte("png data"))[:20])
+
+	tests := []struct {
+		name     string
+		comments []string
+		pictures int
+	}{
+		{"picture", []string{"METADATA_BLOCK_PICTURE=" + picture}, 1},
+		{"lower case picture", []string{"metadata_block_picture=" + picture}, 1},
+		{"malformed base64", []string{"METADATA_BLOCK_PICTURE=not base64!"}, 0},
+		{"truncated picture block", []string{"METADATA_BLOCK_PICTURE=" + truncated}, 0},
+		{"malformed and valid pictures", []string{"METADATA_BLOCK_PICTURE=%%%", "METADATA_BLOCK_PICTURE=" + picture}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comments := append([]string{"TITLE=Test Title", "ARTIST=First", "no separator"}, tt.comments...)
+			comments = append(comments, "artist=Second")
+
+			vendor, fields, pictures, err := readVorbisComment(bytes.NewReader(vorbisComment(comments...)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if vendor != "test vendor" {
+				t.Errorf("vendor = %q, want %q", vendor, "test vendor")
+			}
+			if got := fields["title"]; len(got) != 1 || got[0] != "Test Title" {
+				t.Errorf("title = %q, want %q", got, "Test Title")
+			}
+			if got := fields["artist"]; len(got) != 2 || got[0] != "First" || got[1] != "Second" {
+				t.Errorf("artist = %q, want [First Second]", got)
+			}
+
+			if len(pictures) != tt.pictures {
+				t.Fatalf("%d pictures, want %d", len(pictures), tt.pictures)
+			}
+			for _, p := range pictures {
+				if p.MIMEType != "image/png" || string(p.Data) != "png data" {
+					t.Errorf("picture = %q %q", p.MIMEType, p.Data)
+				}
+			}
+		})
+	}
+}
+
+func TestReadVorbisCommentTruncated(t *testing.T) {
+	b := vorbisComment("TITLE=Test Title", "ARTIST=Test Artist")
+	for n := 0; n < len(b); n++ {
+		if _, _, _, err := readVorbisComment(bytes.NewReader(b[:n])); err == nil {
+			t.Errorf("%d of %d bytes: no error", n, len(b))
+		}
+	}
+}
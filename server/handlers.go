@@ -1,12 +1,16 @@
 package server
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"mime"
 	"music-go/database"
 	"music-go/musictag"
+	"music-go/utils"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -24,6 +28,16 @@ func (s *httpServer) checkGET(w http.ResponseWriter, r *http.Request) bool {
 	return true
 }
 
+func (s *httpServer) checkPOST(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodPost {
+		http.Error(w, fmt.Sprintf("ERROR: for \"%s\" Method not allowed, Only \"POST\" is allowed.", r.URL.String()), http.StatusBadRequest)
+		s.logger.Printf("ERROR: for \"%s\" Method not allowed, Only \"POST\" is allowed.", r.URL.String())
+		return false
+	}
+
+	return true
+}
+
 func (s *httpServer) handleIndex(w http.ResponseWriter, r *http.Request) {
 	if !s.checkGET(w, r) {
 		return
@@ -185,19 +199,46 @@ func (s *httpServer) handleSongsByAlbum(w http.ResponseWriter, r *http.Request)
 		s.logger.Printf("ERROR: Wrong get request: path should be /songs/by-album/{album name}")
 		return
 	}
-	songs, err := s.db.GetMusicsByAlbumName(albumName)
+	// albums tagged with a MusicBrainz id are identified by it instead of the name
+	albumID := r.URL.Query().Get("mbid")
+
+	var songs []database.Music
+	var err error
+	if albumID != "" {
+		songs, err = s.db.GetMusicsByAlbumID(albumID)
+	} else {
+		songs, err = s.db.GetMusicsByAlbumName(albumName)
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		s.logger.Printf("ERROR: could not get songs from album(%s) : %s\n", albumName, err.Error())
 		return
 	}
 
+	if len(songs) == 0 {
+		http.Error(w, "No songs found for album "+albumName, http.StatusNotFound)
+		s.logger.Printf("ERROR: no songs found for album(%s)\n", albumName)
+		return
+	}
+
+	// hide the lower quality copies of the songs stored in several formats
+	if s.configs.Fingerprint.PreferBestCopy {
+		best, err := s.db.PreferBestCopies(songs, s.configs.Fingerprint.Threshold)
+		if err != nil {
+			s.logger.Printf("ERROR: could not filter the copies of the songs of album(%s): %s\n", albumName, err.Error())
+		} else {
+			songs = best
+		}
+	}
+
 	paylod := struct {
 		AlbumName    string
+		AlbumID      string
 		AlbumArtPath string
 		Songs        []database.Music
 	}{
 		AlbumName:    albumName,
+		AlbumID:      albumID,
 		AlbumArtPath: songs[0].Path,
 		Songs:        songs,
 	}
@@ -308,8 +349,25 @@ func (s *httpServer) handleDisplayAlbumArt(w http.ResponseWriter, r *http.Reques
 		s.logger.Printf("ERROR: Could not read tag for %s: %v\n", songPath, err)
 		return
 	}
+	// index of the picture from /album-arts, by default the front cover
 	albumArt := tag.GetAlbumArt()
-	//TODOOOOO: handle for empty albumart
+	if indexStr := r.URL.Query().Get("index"); indexStr != "" {
+		pictures := tag.GetPictures()
+		index, err := strconv.Atoi(indexStr)
+		if err != nil || index < 0 || index >= len(pictures) {
+			http.Error(w, fmt.Sprintf("No picture at index %s for %s", indexStr, songPath), http.StatusNotFound)
+			s.logger.Printf("ERROR: No picture at index %s for %s\n", indexStr, songPath)
+			return
+		}
+		albumArt = pictures[index]
+	}
+
+	if albumArt == nil {
+		http.Error(w, fmt.Sprintf("No album art found for %s", songPath), http.StatusNotFound)
+		s.logger.Printf("ERROR: No album art found for %s\n", songPath)
+		return
+	}
+
 	w.Header().Set("Content-Type", albumArt.MIMEType)
 	w.Header().Set("Accept-Ranges", "bytes")                               // Enable range requests for seeking
 	w.Header().Set("Cache-Control", "no-store, no-cache, must-revalidate") // Minimize browser RAM
@@ -318,6 +376,149 @@ func (s *httpServer) handleDisplayAlbumArt(w http.ResponseWriter, r *http.Reques
 	s.logger.Printf("INFO: album art for \"%s\" sucessfuly served.", songPath)
 }
 
+// list all the embedded pictures of a song, the pictures are served by /albumArt?index={index}
+func (s *httpServer) handleAlbumArts(w http.ResponseWriter, r *http.Request) {
+	if !s.checkGET(w, r) {
+		return
+	}
+
+	songPath := r.URL.Query().Get("music-path")
+	songFile, err := os.Open(songPath)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Could not open %s: %v", songPath, err), http.StatusBadRequest)
+		s.logger.Printf("ERROR: Could not open %s: %v\n", songPath, err)
+		return
+	}
+	defer songFile.Close()
+
+	tag, err := musictag.ReadFrom(songFile)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Could not read tag for %s: %v", songPath, err), http.StatusBadRequest)
+		s.logger.Printf("ERROR: Could not read tag for %s: %v\n", songPath, err)
+		return
+	}
+
+	payload := make([]map[string]any, 0)
+	for i, p := range tag.GetPictures() {
+		payload = append(payload, map[string]any{
+			"index":       i,
+			"type":        p.Type,
+			"description": p.Description,
+			"mimeType":    p.MIMEType,
+			"url":         fmt.Sprintf("/albumArt?music-path=%s&index=%d", url.QueryEscape(songPath), i),
+		})
+	}
+
+	payloadJson, err := json.Marshal(payload)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		s.logger.Printf("ERROR: In handleAlbumArts(): %s\n", err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payloadJson)
+	s.logger.Printf("INFO: album arts list for \"%s\" sucessfuly served.", songPath)
+}
+
+// lyrics of a song, the synchronised lines have the start time in milliseconds
+func (s *httpServer) handleLyrics(w http.ResponseWriter, r *http.Request) {
+	if !s.checkGET(w, r) {
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	songId, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("url should be /lyrics?id={id}, can't convert %q to int", id), http.StatusBadRequest)
+		s.logger.Printf("ERROR: url should be /lyrics?id={id}, can't convert %q to int\n", id)
+		return
+	}
+
+	lyrics, syncedLyrics, err := s.db.GetLyricsByID(songId)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Song not found", http.StatusNotFound)
+		s.logger.Printf("ERROR: Song %d not found\n", songId)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		s.logger.Printf("ERROR: could't query lyrics for song id %d: %s\n", songId, err.Error())
+		return
+	}
+
+	lines := make([]map[string]any, len(syncedLyrics))
+	for i, l := range syncedLyrics {
+		lines[i] = map[string]any{
+			"time": l.Time.Milliseconds(),
+			"text": l.Text,
+		}
+	}
+
+	payload := map[string]any{
+		"id":     songId,
+		"lyrics": lyrics,
+		"synced": lines,
+	}
+
+	payloadJson, err := json.Marshal(payload)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		s.logger.Printf("ERROR: In handleLyrics(): %s\n", err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payloadJson)
+	s.logger.Printf("INFO: lyrics for song id %d sucessfuly served.", songId)
+}
+
+func (s *httpServer) handleChapters(w http.ResponseWriter, r *http.Request) {
+	if !s.checkGET(w, r) {
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	songId, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("url should be /chapters?id={id}, can't convert %q to int", id), http.StatusBadRequest)
+		s.logger.Printf("ERROR: url should be /chapters?id={id}, can't convert %q to int\n", id)
+		return
+	}
+
+	chapters, err := s.db.GetChaptersByID(songId)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Song not found", http.StatusNotFound)
+		s.logger.Printf("ERROR: Song %d not found\n", songId)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		s.logger.Printf("ERROR: could't query chapters for song id %d: %s\n", songId, err.Error())
+		return
+	}
+
+	list := make([]map[string]any, len(chapters))
+	for i, c := range chapters {
+		list[i] = map[string]any{
+			"title": c.Title,
+			"start": c.Start.Milliseconds(),
+			"end":   c.End.Milliseconds(),
+		}
+	}
+
+	payload := map[string]any{
+		"id":       songId,
+		"chapters": list,
+	}
+
+	payloadJson, err := json.Marshal(payload)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		s.logger.Printf("ERROR: In handleChapters(): %s\n", err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payloadJson)
+	s.logger.Printf("INFO: chapters for song id %d sucessfuly served.", songId)
+}
+
 func (s *httpServer) handleGetNextSong(w http.ResponseWriter, r *http.Request) {
 	if !s.checkGET(w, r) {
 		return
@@ -334,6 +535,7 @@ func (s *httpServer) handleGetNextSong(w http.ResponseWriter, r *http.Request) {
 	if err == nil {
 		song, dberr = s.db.GetMusicBYID(songId)
 	} else if err == ErrEmptyQueue {
+		s.playingAlbum.Store(false)
 		song, dberr = s.db.GetRandomMusic()
 	} else {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -355,8 +557,9 @@ func (s *httpServer) handleGetNextSong(w http.ResponseWriter, r *http.Request) {
 
 	// Prepare the payload
 	payload := map[string]any{
-		"id":   song.Id,
-		"path": song.Path,
+		"id":         song.Id,
+		"path":       song.Path,
+		"replayGain": s.replayGainPayload(song),
 	}
 
 	// Marshal the payload to JSON
@@ -392,8 +595,7 @@ func (s *httpServer) handlePreviousSong(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	var songPath string
-	err = s.db.DB.QueryRow("SELECT music_location FROM musics WHERE id = ?", songId).Scan(&songPath)
+	song, err := s.db.GetMusicBYID(songId)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		s.logger.Printf("ERROR: could't found music_location for song id %d: %s\n", songId, err.Error())
@@ -401,8 +603,9 @@ func (s *httpServer) handlePreviousSong(w http.ResponseWriter, r *http.Request)
 	}
 
 	payload := map[string]any{
-		"id":   songId,
-		"path": songPath,
+		"id":         songId,
+		"path":       song.Path,
+		"replayGain": s.replayGainPayload(song),
 	}
 
 	payloadJson, err := json.Marshal(payload)
@@ -438,7 +641,12 @@ func (s *httpServer) handlePlayAll(w http.ResponseWriter, r *http.Request) {
 	case "album":
 		albumName := quaryValue
 		songs, err = s.db.GetMusicsByAlbumName(albumName)
+		s.playingAlbum.Store(true)
+	case "album-id":
+		songs, err = s.db.GetMusicsByAlbumID(quaryValue)
+		s.playingAlbum.Store(true)
 	case "artist":
+		s.playingAlbum.Store(false)
 		var artistId int64
 		artistId, err = strconv.ParseInt(quaryValue, 10, 64)
 		if err != nil {
@@ -481,8 +689,9 @@ func (s *httpServer) handlePlayAll(w http.ResponseWriter, r *http.Request) {
 	}
 
 	payload := map[string]any{
-		"id":   song.Id,
-		"path": song.Path,
+		"id":         song.Id,
+		"path":       song.Path,
+		"replayGain": s.replayGainPayload(song),
 	}
 
 	payloadJson, err := json.Marshal(payload)
@@ -495,3 +704,362 @@ func (s *httpServer) handlePlayAll(w http.ResponseWriter, r *http.Request) {
 	w.Write(payloadJson)
 	s.logger.Printf("INFO: playall data served sucessfuly %v", string(payloadJson))
 }
+
+// replay gain of a song played directly from a list
+func (s *httpServer) handleReplayGain(w http.ResponseWriter, r *http.Request) {
+	if !s.checkGET(w, r) {
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	songId, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("url should be /replay-gain?id={id}, can't convert %q to int", id), http.StatusBadRequest)
+		s.logger.Printf("ERROR: url should be /replay-gain?id={id}, can't convert %q to int\n", id)
+		return
+	}
+
+	song, err := s.db.GetMusicBYID(songId)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		s.logger.Printf("ERROR: could't query song for song id %d: %s\n", songId, err.Error())
+		return
+	}
+
+	payloadJson, err := json.Marshal(s.replayGainPayload(song))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		s.logger.Printf("ERROR: In handleReplayGain(): %s\n", err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payloadJson)
+}
+
+// set the rating of a song, written back to the file when enabled in the config
+func (s *httpServer) handleRating(w http.ResponseWriter, r *http.Request) {
+	if !s.checkPOST(w, r) {
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	songId, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("url should be /rating?id={id}&rating={0-5}, can't convert %q to int", id), http.StatusBadRequest)
+		s.logger.Printf("ERROR: url should be /rating?id={id}&rating={0-5}, can't convert %q to int\n", id)
+		return
+	}
+
+	rating, err := strconv.Atoi(r.URL.Query().Get("rating"))
+	if err != nil || rating < 0 || rating > 5 {
+		http.Error(w, fmt.Sprintf("rating should be 0 to 5, got %q", r.URL.Query().Get("rating")), http.StatusBadRequest)
+		s.logger.Printf("ERROR: rating should be 0 to 5, got %q\n", r.URL.Query().Get("rating"))
+		return
+	}
+
+	err = s.db.SetRating(songId, rating)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Song not found", http.StatusNotFound)
+		s.logger.Printf("ERROR: Song %d not found\n", songId)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		s.logger.Printf("ERROR: could't set the rating of song id %d: %s\n", songId, err.Error())
+		return
+	}
+
+	// the rating is kept in the database even if the file can't be written,
+	// the reason is shown to the user
+	written := false
+	writeError := ""
+	if s.configs.Ratings.WriteBack {
+		song, err := s.db.GetMusicBYID(songId)
+		if err == nil {
+			err = musictag.WriteID3v2Rating(song.Path, s.configs.Ratings.Email, rating)
+		}
+		if err != nil {
+			s.logger.Printf("ERROR: could't write the rating of song id %d to the file: %s\n", songId, err.Error())
+			writeError = err.Error()
+		}
+		written = err == nil
+	}
+
+	payload := map[string]any{
+		"id":         songId,
+		"rating":     rating,
+		"written":    written,
+		"writeError": writeError,
+	}
+
+	payloadJson, err := json.Marshal(payload)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		s.logger.Printf("ERROR: In handleRating(): %s\n", err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payloadJson)
+	s.logger.Printf("INFO: rating of song id %d set to %d.", songId, rating)
+}
+
+// set the code page of the ISO-8859-1 text of the tags of a song, the tags
+// are read again. An empty encoding restores the configured decoding.
+func (s *httpServer) handleTextEncoding(w http.ResponseWriter, r *http.Request) {
+	if !s.checkPOST(w, r) {
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	songId, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("url should be /text-encoding?id={id}&encoding={code page}, can't convert %q to int", id), http.StatusBadRequest)
+		s.logger.Printf("ERROR: url should be /text-encoding?id={id}&encoding={code page}, can't convert %q to int\n", id)
+		return
+	}
+
+	encoding := r.URL.Query().Get("encoding")
+	if _, err := musictag.NewTextDecoder(encoding, false); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		s.logger.Printf("ERROR: %s\n", err.Error())
+		return
+	}
+
+	err = s.db.SetTextEncoding(songId, encoding)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Song not found", http.StatusNotFound)
+		s.logger.Printf("ERROR: Song %d not found\n", songId)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		s.logger.Printf("ERROR: could't set the text encoding of song id %d: %s\n", songId, err.Error())
+		return
+	}
+
+	song, err := s.db.GetMusicBYID(songId)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		s.logger.Printf("ERROR: could't get song id %d: %s\n", songId, err.Error())
+		return
+	}
+
+	payload := map[string]any{
+		"id":       songId,
+		"encoding": encoding,
+		"title":    song.Title,
+		"artists":  song.Artists,
+		"album":    song.Album,
+	}
+
+	payloadJson, err := json.Marshal(payload)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		s.logger.Printf("ERROR: In handleTextEncoding(): %s\n", err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payloadJson)
+	s.logger.Printf("INFO: text encoding of song id %d set to %q.", songId, encoding)
+}
+
+// start the loudness analysis of the library in the background, only the
+// MP3 files are analyzed: the others are reported as skipped by the progress
+func (s *httpServer) handleLoudnessAnalyze(w http.ResponseWriter, r *http.Request) {
+	if !s.checkPOST(w, r) {
+		return
+	}
+
+	err := s.loudnessJob.Start()
+	if err == database.ErrJobRunning {
+		http.Error(w, err.Error(), http.StatusConflict)
+		s.logger.Printf("ERROR: %s\n", err.Error())
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		s.logger.Printf("ERROR: could not start the loudness analysis: %s\n", err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	s.logger.Printf("INFO: loudness analysis started.")
+}
+
+// progress of the loudness analysis
+func (s *httpServer) handleLoudnessProgress(w http.ResponseWriter, r *http.Request) {
+	if !s.checkGET(w, r) {
+		return
+	}
+
+	payloadJson, err := json.Marshal(s.loudnessJob.Progress())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		s.logger.Printf("ERROR: In handleLoudnessProgress(): %s\n", err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payloadJson)
+}
+
+// songs with the same audio data, grouped by audio hash
+func (s *httpServer) handleDuplicates(w http.ResponseWriter, r *http.Request) {
+	if !s.checkGET(w, r) {
+		return
+	}
+
+	groups, err := s.db.GetDuplicates()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		s.logger.Printf("ERROR: could't query the duplicates: %s\n", err.Error())
+		return
+	}
+
+	list := make([]map[string]any, len(groups))
+	for i, g := range groups {
+		songs := make([]map[string]any, len(g.Songs))
+		for j, song := range g.Songs {
+			songs[j] = map[string]any{
+				"id":      song.Id,
+				"title":   song.Title,
+				"artists": song.Artists,
+				"album":   song.Album,
+				"path":    song.Path,
+			}
+		}
+		list[i] = map[string]any{
+			"audio_hash": g.AudioHash,
+			"songs":      songs,
+		}
+	}
+
+	payloadJson, err := json.Marshal(map[string]any{"duplicates": list})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		s.logger.Printf("ERROR: In handleDuplicates(): %s\n", err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payloadJson)
+	s.logger.Printf("INFO: %d groups of duplicates sucessfuly served.", len(groups))
+}
+
+// start the fingerprinting of the library in the background
+func (s *httpServer) handleFingerprintAnalyze(w http.ResponseWriter, r *http.Request) {
+	if !s.checkPOST(w, r) {
+		return
+	}
+
+	err := s.fingerprintJob.Start()
+	if err == database.ErrFingerprintRunning {
+		http.Error(w, err.Error(), http.StatusConflict)
+		s.logger.Printf("ERROR: %s\n", err.Error())
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		s.logger.Printf("ERROR: could not start the fingerprinting: %s\n", err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	s.logger.Printf("INFO: fingerprinting started.")
+}
+
+// progress of the fingerprinting
+func (s *httpServer) handleFingerprintProgress(w http.ResponseWriter, r *http.Request) {
+	if !s.checkGET(w, r) {
+		return
+	}
+
+	payloadJson, err := json.Marshal(s.fingerprintJob.Progress())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		s.logger.Printf("ERROR: In handleFingerprintProgress(): %s\n", err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payloadJson)
+}
+
+// pairs of songs with the same recording encoded differently, the best
+// quality copy first. The threshold can be raised above the configured one,
+// not lowered: the pairs are found with the configured threshold.
+func (s *httpServer) handleNearDuplicates(w http.ResponseWriter, r *http.Request) {
+	if !s.checkGET(w, r) {
+		return
+	}
+
+	threshold := s.configs.Fingerprint.Threshold
+	if t := r.URL.Query().Get("threshold"); t != "" {
+		var err error
+		threshold, err = strconv.ParseFloat(t, 64)
+		if err != nil || threshold < 0 || threshold > 1 {
+			http.Error(w, fmt.Sprintf("url should be /near-duplicates?threshold={0 to 1}, invalid threshold %q", t), http.StatusBadRequest)
+			s.logger.Printf("ERROR: url should be /near-duplicates?threshold={0 to 1}, invalid threshold %q\n", t)
+			return
+		}
+	}
+
+	pairs, err := s.db.GetNearDuplicates(threshold)
+	if errors.Is(err, database.ErrThresholdTooLow) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		s.logger.Printf("ERROR: %s\n", err.Error())
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		s.logger.Printf("ERROR: could't query the near duplicates: %s\n", err.Error())
+		return
+	}
+
+	list := make([]map[string]any, len(pairs))
+	for i, p := range pairs {
+		songs := make([]map[string]any, len(p.Songs))
+		for j, song := range p.Songs {
+			songs[j] = map[string]any{
+				"id":      song.Id,
+				"title":   song.Title,
+				"artists": song.Artists,
+				"album":   song.Album,
+				"path":    song.Path,
+			}
+		}
+		list[i] = map[string]any{
+			"score": p.Score,
+			"songs": songs,
+		}
+	}
+
+	payloadJson, err := json.Marshal(map[string]any{"near_duplicates": list})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		s.logger.Printf("ERROR: In handleNearDuplicates(): %s\n", err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payloadJson)
+	s.logger.Printf("INFO: %d near duplicates sucessfuly served.", len(pairs))
+}
+
+// replayGainPayload returns the gain (in dB) and the peak the player should
+// apply to the song for the configured replay gain mode, the peak is 0 if unknown.
+func (s *httpServer) replayGainPayload(song *database.Music) map[string]any {
+	cfg := s.configs.ReplayGain
+	rg := song.ReplayGain
+
+	useAlbum := cfg.Mode == utils.ReplayGainAlbum || (cfg.Mode == utils.ReplayGainAuto && s.playingAlbum.Load())
+
+	var gain, peak float64
+	switch {
+	case cfg.Mode == utils.ReplayGainOff:
+	case useAlbum && rg.HasAlbum:
+		gain, peak = rg.AlbumGain+cfg.PreAmp, rg.AlbumPeak
+	case rg.HasTrack:
+		gain, peak = rg.TrackGain+cfg.PreAmp, rg.TrackPeak
+	case rg.HasAlbum:
+		gain, peak = rg.AlbumGain+cfg.PreAmp, rg.AlbumPeak
+	default:
+		gain = cfg.FallbackPreAmp
+	}
+
+	return map[string]any{
+		"gain": gain,
+		"peak": peak,
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"music-go/database"
 	"music-go/utils"
 	"net/http"
+	"sync/atomic"
 )
 
 type httpServer struct {
@@ -16,6 +17,13 @@ type httpServer struct {
 	songsStack Stack
 	songQueue  Queue
 	logger     utils.CLogger
+
+	// the queue holds a whole album, used by the "auto" replay gain mode, set
+	// and read by concurrent requests
+	playingAlbum atomic.Bool
+
+	loudnessJob    *database.LoudnessJob
+	fingerprintJob *database.FingerprintJob
 }
 
 func NewServer(config utils.Config, db *database.DataBase, logger utils.CLogger) (*httpServer, error) {
@@ -26,6 +34,8 @@ func NewServer(config utils.Config, db *database.DataBase, logger utils.CLogger)
 		songQueue:  *NewQueue(),
 		logger:     logger,
 	}
+	server.loudnessJob = db.NewLoudnessJob()
+	server.fingerprintJob = db.NewFingerprintJob()
 
 	if err := server.loadTemplates(); err != nil {
 		return nil, err
@@ -55,6 +65,18 @@ func (s *httpServer) Serve() error {
 
 	mux.HandleFunc("/song/details", s.handleSongDetails)
 	mux.HandleFunc("/albumArt", s.handleDisplayAlbumArt)
+	mux.HandleFunc("/album-arts", s.handleAlbumArts)
+	mux.HandleFunc("/lyrics", s.handleLyrics)
+	mux.HandleFunc("/chapters", s.handleChapters)
+	mux.HandleFunc("/rating", s.handleRating)
+	mux.HandleFunc("/text-encoding", s.handleTextEncoding)
+	mux.HandleFunc("/duplicates", s.handleDuplicates)
+	mux.HandleFunc("/near-duplicates", s.handleNearDuplicates)
+	mux.HandleFunc("/replay-gain", s.handleReplayGain)
+	mux.HandleFunc("/loudness/analyze", s.handleLoudnessAnalyze)
+	mux.HandleFunc("/loudness/progress", s.handleLoudnessProgress)
+	mux.HandleFunc("/fingerprint/analyze", s.handleFingerprintAnalyze)
+	mux.HandleFunc("/fingerprint/progress", s.handleFingerprintProgress)
 	mux.HandleFunc("/play", s.handleSongPlay)
 	mux.HandleFunc("/get-next-song", s.handleGetNextSong)
 	mux.HandleFunc("/previous-song", s.handlePreviousSong)
@@ -1,6 +1,12 @@
 // global variables
 var songVolume = 1.0;
 var currentPlayingSongId = 0;
+var syncedLyrics = []; // [{time: ms, text: string}]
+var currentLyricIndex = -1;
+var chapters = []; // [{title: string, start: ms, end: ms}]
+var currentChapterIndex = -1;
+var audioContext = null;
+var replayGainNode = null;
 
 function formatTime(totalSec) {
   var minutes = Math.floor(totalSec / 60);
@@ -18,10 +24,46 @@ function onAudioLoadMetadata(audio) {
   currentTime.innerHTML = `${formatTime(audio.currentTime)}`;
 }
 
-function playSong(musicPath, id) {
+// apply the replay gain {gain: dB, peak: linear} of the song, the gain is
+// limited by the peak to avoid clipping
+function applyReplayGain(replayGain) {
+  const audio = document.getElementById("audio");
+
+  let gain = Math.pow(10, replayGain["gain"] / 20);
+  if (replayGain["peak"] > 0) {
+    gain = Math.min(gain, 1 / replayGain["peak"]);
+  }
+
+  // audio.volume can't amplify, route the audio through a gain node
+  if (!audioContext) {
+    audioContext = new AudioContext();
+    replayGainNode = audioContext.createGain();
+    audioContext
+      .createMediaElementSource(audio)
+      .connect(replayGainNode)
+      .connect(audioContext.destination);
+  }
+  if (audioContext.state === "suspended") {
+    audioContext.resume();
+  }
+  replayGainNode.gain.value = gain;
+}
+
+function playSong(musicPath, id, replayGain) {
   const audio = document.getElementById("audio");
   const source = document.getElementById("source");
 
+  if (replayGain) {
+    applyReplayGain(replayGain);
+  } else if (id) {
+    fetch(`/replay-gain?id=${id}`)
+      .then((response) => response.json())
+      .then((data) => applyReplayGain(data))
+      .catch((err) => {
+        console.error("ERROR: fetching replay gain:", err);
+      });
+  }
+
   if (musicPath) {
     // Stop current playback
     source.src = `/play?music-path=${encodeURIComponent(musicPath)}`;
@@ -30,6 +72,8 @@ function playSong(musicPath, id) {
   }
   if (id) {
     currentPlayingSongId = id;
+    loadLyrics(id);
+    loadChapters(id);
   }
 
   // Wait for the audio to be ready before playing
@@ -63,6 +107,149 @@ function playSong(musicPath, id) {
   }
 }
 
+function loadLyrics(id) {
+  const lyricsDiv = document.getElementById("lyrics");
+  syncedLyrics = [];
+  currentLyricIndex = -1;
+  lyricsDiv.innerHTML = "";
+  lyricsDiv.style.display = "none";
+
+  fetch(`/lyrics?id=${id}`)
+    .then((response) => response.json())
+    .then((data) => {
+      if (data["id"] != currentPlayingSongId) {
+        return;
+      }
+      syncedLyrics = data["synced"];
+      if (syncedLyrics.length == 0) {
+        return;
+      }
+
+      for (const line of syncedLyrics) {
+        const lineDiv = document.createElement("div");
+        lineDiv.className = "lyric-line";
+        lineDiv.textContent = line["text"];
+        lyricsDiv.appendChild(lineDiv);
+      }
+      lyricsDiv.style.display = "block";
+    })
+    .catch((err) => {
+      console.error("ERROR: fetching lyrics:", err);
+    });
+}
+
+// highlight the line of the synchronised lyrics at time (in seconds)
+function updateLyrics(time) {
+  const ms = time * 1000;
+  let index = -1;
+  while (index + 1 < syncedLyrics.length && syncedLyrics[index + 1]["time"] <= ms) {
+    index++;
+  }
+
+  if (index == currentLyricIndex) {
+    return;
+  }
+
+  const lines = document.querySelectorAll("#lyrics .lyric-line");
+  if (currentLyricIndex >= 0 && currentLyricIndex < lines.length) {
+    lines[currentLyricIndex].classList.remove("active");
+  }
+  if (index >= 0 && index < lines.length) {
+    lines[index].classList.add("active");
+    const lyricsDiv = document.getElementById("lyrics");
+    lyricsDiv.scrollTop =
+      lines[index].offsetTop - (lyricsDiv.clientHeight - lines[index].clientHeight) / 2;
+  }
+  currentLyricIndex = index;
+}
+
+function loadChapters(id) {
+  const chaptersDiv = document.getElementById("chapters");
+  chapters = [];
+  currentChapterIndex = -1;
+  chaptersDiv.innerHTML = "";
+  chaptersDiv.style.display = "none";
+
+  fetch(`/chapters?id=${id}`)
+    .then((response) => response.json())
+    .then((data) => {
+      if (data["id"] != currentPlayingSongId) {
+        return;
+      }
+      chapters = data["chapters"];
+      if (chapters.length == 0) {
+        return;
+      }
+
+      for (const chapter of chapters) {
+        const chapterBtn = document.createElement("button");
+        chapterBtn.className = "chapter";
+        chapterBtn.textContent = `${formatTime(chapter["start"] / 1000)} ${chapter["title"]}`;
+        chapterBtn.addEventListener("click", () => {
+          const audio = document.getElementById("audio");
+          audio.currentTime = chapter["start"] / 1000;
+        });
+        chaptersDiv.appendChild(chapterBtn);
+      }
+      chaptersDiv.style.display = "flex";
+    })
+    .catch((err) => {
+      console.error("ERROR: fetching chapters:", err);
+    });
+}
+
+// highlight the chapter playing at time (in seconds)
+function updateChapters(time) {
+  const ms = time * 1000;
+  let index = -1;
+  while (index + 1 < chapters.length && chapters[index + 1]["start"] <= ms) {
+    index++;
+  }
+
+  if (index == currentChapterIndex) {
+    return;
+  }
+
+  const buttons = document.querySelectorAll("#chapters .chapter");
+  if (currentChapterIndex >= 0 && currentChapterIndex < buttons.length) {
+    buttons[currentChapterIndex].classList.remove("active");
+  }
+  if (index >= 0 && index < buttons.length) {
+    buttons[index].classList.add("active");
+  }
+  currentChapterIndex = index;
+}
+
+// set the rating of the song, clicking the current rating removes it
+function setRating(id, rating) {
+  const stars = document.querySelectorAll("#music-details .song-rating .star");
+  const current = document.querySelectorAll("#music-details .song-rating .star.filled").length;
+  if (rating == current) {
+    rating = 0;
+  }
+
+  fetch(`/rating?id=${id}&rating=${rating}`, { method: "POST" })
+    .then((response) => {
+      if (!response.ok) {
+        throw new Error(`status ${response.status}`);
+      }
+      return response.json();
+    })
+    .then((data) => {
+      stars.forEach((star, i) => {
+        star.classList.toggle("filled", i < data["rating"]);
+      });
+      // the rating is saved but could not be written to the file
+      const writeError = document.querySelector("#music-details .song-rating .rating-error");
+      if (writeError) {
+        writeError.textContent = data["writeError"] ? `not saved to the file: ${data["writeError"]}` : "";
+      }
+    })
+    .catch((err) => {
+      console.error("ERROR: setting rating:", err);
+    });
+}
+
 function playSongFromJsonResponce(data) {
   let nextSongId = data["id"];
   let nextSongPath = data["path"];
@@ -71,7 +258,7 @@ function playSongFromJsonResponce(data) {
     .then((response) => response.text())
     .then((html) => {
       document.getElementById("music-details").innerHTML = html;
-      playSong(nextSongPath, nextSongId);
+      playSong(nextSongPath, nextSongId, data["replayGain"]);
     })
     .catch((err) => {
       console.error("ERROR: fetching:", err);
@@ -102,12 +289,13 @@ function playPreviousSong() {
     .then((data) => {
       let prevSongId = data["id"];
       let prevSongPath = data["path"];
+      let prevSongReplayGain = data["replayGain"];
 
       fetch(`/song/details?id=${prevSongId}&toPlay=true`)
         .then((response) => response.text())
         .then((html) => {
           document.getElementById("music-details").innerHTML = html;
-          playSong(prevSongPath, prevSongId);
+          playSong(prevSongPath, prevSongId, prevSongReplayGain);
         })
         .catch((err) => {
           console.error("ERROR: fetching:", err);
@@ -49,6 +49,51 @@ func (ld *LogDestination) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+type ReplayGainMode int
+
+const (
+	ReplayGainOff   ReplayGainMode = iota
+	ReplayGainTrack                // always use the track gain
+	ReplayGainAlbum                // always use the album gain
+	ReplayGainAuto                 // album gain when playing a whole album, track gain otherwise
+)
+
+func (m ReplayGainMode) MarshalJSON() ([]byte, error) {
+	switch m {
+	case ReplayGainOff:
+		return json.Marshal("off")
+	case ReplayGainTrack:
+		return json.Marshal("track")
+	case ReplayGainAlbum:
+		return json.Marshal("album")
+	case ReplayGainAuto:
+		return json.Marshal("auto")
+	default:
+		return nil, fmt.Errorf("Invalid ReplayGainMode: %d", m)
+	}
+}
+
+func (m *ReplayGainMode) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	switch s {
+	case "off":
+		*m = ReplayGainOff
+	case "track":
+		*m = ReplayGainTrack
+	case "album":
+		*m = ReplayGainAlbum
+	case "auto":
+		*m = ReplayGainAuto
+	default:
+		return fmt.Errorf("invalid replay gain mode: %s", s)
+	}
+	return nil
+}
+
 type Config struct {
 	MusicDir string `json:"music_dir"`
 	Database struct {
@@ -61,6 +106,27 @@ type Config struct {
 		Enable      bool           `json:"enable"`
 		Destination LogDestination `json:"destination"` // 0 -> console, 1 -> log file, 2 -> both
 	}
+	ReplayGain struct {
+		Mode           ReplayGainMode `json:"mode"`            // off, track, album or auto
+		PreAmp         float64        `json:"preamp"`          // dB added to the gain of songs with ReplayGain tags
+		FallbackPreAmp float64        `json:"fallback_preamp"` // dB applied to songs without ReplayGain tags
+	} `json:"replay_gain"`
+	Artists struct {
+		Separators string   `json:"separators"` // regular expression splitting the artist when the tag has a single value
+		Exceptions []string `json:"exceptions"` // artist names which are never split, e.g. "AC/DC"
+	} `json:"artists"`
+	Ratings struct {
+		WriteBack bool   `json:"write_back"` // write the ratings changed in the web UI to the ID3v2 tag of the file
+		Email     string `json:"email"`      // user of the POPM frame added when the file has none
+	} `json:"ratings"`
+	TextEncoding struct {
+		CodePage string `json:"code_page"` // code page of the ISO-8859-1 text of the ID3 tags, e.g. "windows-1251", "" for ISO-8859-1
+		Detect   bool   `json:"detect"`    // detect the UTF-8, Cyrillic and Bengali ISCII text written as ISO-8859-1
+	} `json:"text_encoding"`
+	Fingerprint struct {
+		Threshold      float64 `json:"threshold"`        // similarity (0 to 1) from which two songs are copies of the same recording
+		PreferBestCopy bool    `json:"prefer_best_copy"` // show only the best quality copy of a song in the album views
+	} `json:"fingerprint"`
 }
 
 func newDefaultConfig() *Config {
@@ -70,6 +136,13 @@ func newDefaultConfig() *Config {
 	defaultConfig.Server.Port = 6969
 	defaultConfig.Log.Enable = true
 	defaultConfig.Log.Destination = LogToBoth
+	defaultConfig.ReplayGain.Mode = ReplayGainAuto
+	defaultConfig.Artists.Separators = `\s*(?:/|&|,)\s*`
+	defaultConfig.Artists.Exceptions = []string{"AC/DC", "Simon & Garfunkel", "Earth, Wind & Fire"}
+	defaultConfig.Ratings.Email = "music-go"
+	defaultConfig.TextEncoding.Detect = true
+	defaultConfig.Fingerprint.Threshold = 0.6
+	defaultConfig.Fingerprint.PreferBestCopy = true
 
 	return defaultConfig
 }